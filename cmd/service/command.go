@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"jf/adservice/models"
+)
+
+//commandUsage lists the maintenance commands run instead of the service
+const commandUsage = `usage: adservice [flags] migrate up|down|status|to VERSION
+       adservice [flags] seed FIXTURE`
+
+//errUsage is returned for malformed command lines
+var errUsage = errors.New(commandUsage)
+
+//runCommand runs the maintenance command in args against the configured
+//MySQL database and returns the exit code
+func runCommand(cfg Config, args []string, stdout, stderr io.Writer) int {
+	run, err := parseCommand(args)
+	if err == nil && cfg.Store != "mysql" {
+		err = fmt.Errorf("%s: needs store mysql, not %s", args[0], cfg.Store)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	db, err := models.OpenMySQL(cfg.DB.DSN, models.PoolConfig{MaxOpenConns: 2, MaxIdleConns: 1})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Close()
+	env := commandEnv{migrator: models.NewMigrator(db), store: models.NewMySQLStore(db), out: stdout}
+	if err := run(context.Background(), env); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+//commandEnv is what a command runs against
+type commandEnv struct {
+	migrator *models.Migrator
+	store    models.AdminStore
+	out      io.Writer
+}
+
+//parseCommand checks args and returns the command they name
+func parseCommand(args []string) (func(context.Context, commandEnv) error, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "migrate" && len(rest) == 1 && rest[0] == "up":
+		return func(ctx context.Context, env commandEnv) error {
+			steps, err := env.migrator.Up(ctx)
+			return printSteps(env.out, steps, err)
+		}, nil
+	case cmd == "migrate" && len(rest) == 1 && rest[0] == "down":
+		return func(ctx context.Context, env commandEnv) error {
+			steps, err := env.migrator.Down(ctx)
+			return printSteps(env.out, steps, err)
+		}, nil
+	case cmd == "migrate" && len(rest) == 1 && rest[0] == "status":
+		return func(ctx context.Context, env commandEnv) error {
+			status, err := env.migrator.Status(ctx)
+			if err != nil {
+				return err
+			}
+			return printStatus(env.out, status)
+		}, nil
+	case cmd == "migrate" && len(rest) == 2 && rest[0] == "to":
+		version, err := strconv.Atoi(rest[1])
+		if err != nil || version < 0 {
+			return nil, fmt.Errorf("migrate to: %q is not a version", rest[1])
+		}
+		return func(ctx context.Context, env commandEnv) error {
+			steps, err := env.migrator.To(ctx, version)
+			return printSteps(env.out, steps, err)
+		}, nil
+	case cmd == "seed" && len(rest) == 1:
+		path := rest[0]
+		return func(ctx context.Context, env commandEnv) error {
+			return seed(ctx, env, path)
+		}, nil
+	}
+	return nil, errUsage
+}
+
+//printSteps reports the migrations run, including those run before err
+func printSteps(w io.Writer, steps []models.MigrationStep, err error) error {
+	for _, s := range steps {
+		action := "applied"
+		if s.Revert {
+			action = "reverted"
+		}
+		fmt.Fprintf(w, "%s %d_%s\n", action, s.Version, s.Name)
+	}
+	if err == nil && len(steps) == 0 {
+		fmt.Fprintln(w, "nothing to migrate")
+	}
+	return err
+}
+
+//printStatus writes a table of the migrations and when they were applied
+func printStatus(w io.Writer, status []models.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, s := range status {
+		applied := "pending"
+		if s.Applied != 0 {
+			applied = time.Unix(s.Applied, 0).UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return tw.Flush()
+}
+
+//seed loads the JSON fixture at path into the store
+func seed(ctx context.Context, env commandEnv, path string) error {
+	f, err := loadFixture(path)
+	if err != nil {
+		return err
+	}
+	seeded, err := models.Seed(ctx, env.store, f)
+	fmt.Fprintf(env.out, "seeded %d groups, %d campaigns, %d clients, %d banners\n",
+		len(seeded.Groups), len(seeded.Campaigns), len(seeded.Clients), len(seeded.Banners))
+	return err
+}
+
+//loadFixture reads a JSON fixture, unknown fields are an error
+func loadFixture(path string) (models.Fixture, error) {
+	var f models.Fixture
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, fmt.Errorf("seed: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return f, fmt.Errorf("seed: %s: %v", path, err)
+	}
+	return f, nil
+}
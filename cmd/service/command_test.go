@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"jf/adservice/models"
+)
+
+func TestParseCommand(t *testing.T) {
+	for _, args := range [][]string{
+		{"migrate", "up"},
+		{"migrate", "down"},
+		{"migrate", "status"},
+		{"migrate", "to", "3"},
+		{"seed", "fixture.json"},
+	} {
+		if _, err := parseCommand(args); err != nil {
+			t.Errorf("%v: %v", args, err)
+		}
+	}
+	for _, args := range [][]string{
+		nil,
+		{"serve"},
+		{"migrate"},
+		{"migrate", "sideways"},
+		{"migrate", "to", "-1"},
+		{"migrate", "to", "latest"},
+		{"seed"},
+	} {
+		if _, err := parseCommand(args); err == nil {
+			t.Errorf("%v: want error", args)
+		}
+	}
+}
+
+func TestRunCommandNeedsMySQL(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Store = "memory"
+	var stderr bytes.Buffer
+	if code := runCommand(cfg, []string{"migrate", "up"}, &stderr, &stderr); code != 2 {
+		t.Errorf("want exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "store mysql") {
+		t.Errorf("unexpected message %q", stderr.String())
+	}
+}
+
+func TestSeedExampleFixture(t *testing.T) {
+	run, err := parseCommand([]string{"seed", "seed.example.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := models.NewMemoryStore()
+	var out bytes.Buffer
+	if err := run(context.Background(), commandEnv{store: store, out: &out}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "seeded 2 groups, 1 campaigns, 2 clients, 5 banners\n"; out.String() != want {
+		t.Errorf("want %q, got %q", want, out.String())
+	}
+	banners, err := store.GetBanners(context.Background(), "300*250", 1)
+	if err != nil || len(banners) != 2 {
+		t.Errorf("want 2 active home rectangles, got %d (%v)", len(banners), err)
+	}
+}
@@ -0,0 +1,657 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	yaml "gopkg.in/yaml.v2"
+
+	"jf/adservice/pkg/myservice"
+)
+
+//Config is the complete runtime configuration of the ad service.
+//Values are layered: defaults, then the config file, then environment
+//variables, then command line flags.
+type Config struct {
+	Listen      ListenConfig      `json:"listen" yaml:"listen"`
+	Store       string            `json:"store" yaml:"store"`
+	DB          DBConfig          `json:"db" yaml:"db"`
+	Cache       CacheConfig       `json:"cache" yaml:"cache"`
+	Impressions ImpressionsConfig `json:"impressions" yaml:"impressions"`
+	Click       ClickConfig       `json:"click" yaml:"click"`
+	Viewability ViewabilityConfig `json:"viewability" yaml:"viewability"`
+	AdTag       AdTagConfig       `json:"ad_tag" yaml:"ad_tag"`
+	Targeting   TargetingConfig   `json:"targeting" yaml:"targeting"`
+	Languages   LanguagesConfig   `json:"languages" yaml:"languages"`
+	Tags        TagsConfig        `json:"tags" yaml:"tags"`
+	Frequency   FrequencyConfig   `json:"frequency" yaml:"frequency"`
+	Pacing      PacingConfig      `json:"pacing" yaml:"pacing"`
+	Admin       AdminConfig       `json:"admin" yaml:"admin"`
+	Log         LogConfig         `json:"log" yaml:"log"`
+}
+
+//ListenConfig holds the listen address of every transport.
+//An empty address disables the transport.
+type ListenConfig struct {
+	HTTP   string `json:"http" yaml:"http"`
+	GRPC   string `json:"grpc" yaml:"grpc"`
+	Thrift string `json:"thrift" yaml:"thrift"`
+	Debug  string `json:"debug" yaml:"debug"`
+}
+
+//DBConfig configures the MySQL banner store
+type DBConfig struct {
+	DSN             string   `json:"dsn" yaml:"dsn"`
+	MaxOpenConns    int      `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+}
+
+//CacheConfig holds cache lifetimes
+type CacheConfig struct {
+	//BannerTTL is how long cached banners, client and website groups and
+	//campaigns are fresh, 0 disables the cache
+	BannerTTL Duration `json:"banner_ttl" yaml:"banner_ttl"`
+	StaleTTL  Duration `json:"stale_ttl" yaml:"stale_ttl"`
+	//RefreshInterval is how often cached lookups are reloaded in the background
+	RefreshInterval Duration `json:"refresh_interval" yaml:"refresh_interval"`
+}
+
+//ImpressionsConfig sizes the asynchronous impression writer and campaign counter
+type ImpressionsConfig struct {
+	QueueSize     int      `json:"queue_size" yaml:"queue_size"`
+	BatchSize     int      `json:"batch_size" yaml:"batch_size"`
+	FlushInterval Duration `json:"flush_interval" yaml:"flush_interval"`
+	//ShutdownTimeout bounds how long shutdown waits for queued impressions and campaign counts
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+//ClickConfig configures signed click tracking URLs
+type ClickConfig struct {
+	//Secret is the HMAC key of click tokens, a random one is used when empty
+	Secret string `json:"secret" yaml:"secret"`
+	//TTL is how long a tracking URL stays valid
+	TTL Duration `json:"ttl" yaml:"ttl"`
+	//BaseURL prefixes tracking URLs, they are relative when empty
+	BaseURL string `json:"base_url" yaml:"base_url"`
+}
+
+//ViewabilityConfig tunes heartbeat aggregation
+type ViewabilityConfig struct {
+	HeartbeatInterval Duration `json:"heartbeat_interval" yaml:"heartbeat_interval"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	ViewableThreshold Duration `json:"viewable_threshold" yaml:"viewable_threshold"`
+	MaxSessions       int      `json:"max_sessions" yaml:"max_sessions"`
+}
+
+//AdTagConfig tunes the JavaScript ad tag served under /v1/tag.js, it sends
+//heartbeats every viewability.heartbeat_interval
+type AdTagConfig struct {
+	//RotateInterval is how long a visible slot shows its banners before they
+	//are replaced, 0 disables rotation
+	RotateInterval Duration `json:"rotate_interval" yaml:"rotate_interval"`
+	//MaxAge is how long browsers and proxies may cache the tag
+	MaxAge Duration `json:"max_age" yaml:"max_age"`
+}
+
+//TargetingConfig controls how requests are matched to banner groups
+type TargetingConfig struct {
+	//DefaultGroup is served on unregistered websites, 0 rejects them
+	DefaultGroup int `json:"default_group" yaml:"default_group"`
+}
+
+//LanguagesConfig sets how a missing language falls back to others
+type LanguagesConfig struct {
+	//Default ends every fallback chain, empty serves any language when none is asked for
+	Default string `json:"default" yaml:"default"`
+	//Fallbacks maps a language tag to the tags tried when it has no banner,
+	//tags without an entry fall back to their parent tag
+	Fallbacks map[string][]string `json:"fallbacks" yaml:"fallbacks"`
+}
+
+//TagsConfig tunes visitor tag profiles and tag targeting
+type TagsConfig struct {
+	//Mode is the targeting mode of requests that do not ask for one: off, boost or require
+	Mode string `json:"mode" yaml:"mode"`
+	//Boost multiplies the weight of a banner fully matching the visitor profile by 1+Boost
+	Boost float64 `json:"boost" yaml:"boost"`
+	//HalfLife is how long it takes a tag score to halve
+	HalfLife         Duration `json:"half_life" yaml:"half_life"`
+	MaxTags          int      `json:"max_tags" yaml:"max_tags"`
+	ImpressionWeight float64  `json:"impression_weight" yaml:"impression_weight"`
+	ClickWeight      float64  `json:"click_weight" yaml:"click_weight"`
+	QueueSize        int      `json:"queue_size" yaml:"queue_size"`
+}
+
+//FrequencyConfig caps the impressions of one visitor, 0 disables a cap
+type FrequencyConfig struct {
+	BannerPerHour   int `json:"banner_per_hour" yaml:"banner_per_hour"`
+	BannerPerDay    int `json:"banner_per_day" yaml:"banner_per_day"`
+	CampaignPerHour int `json:"campaign_per_hour" yaml:"campaign_per_hour"`
+	CampaignPerDay  int `json:"campaign_per_day" yaml:"campaign_per_day"`
+}
+
+//PacingConfig spreads daily banner targets over the UTC day
+type PacingConfig struct {
+	//Curve is the traffic weight of each of the 24 UTC hours, empty paces evenly
+	Curve []float64 `json:"curve" yaml:"curve"`
+	//Interval is how often serving probabilities are recomputed
+	Interval Duration `json:"interval" yaml:"interval"`
+}
+
+//AdminConfig guards the admin API
+type AdminConfig struct {
+	//Tokens maps the name of every admin to the bearer token they use, the
+	//name is recorded in the audit log. Without tokens the admin API is closed.
+	Tokens map[string]string `json:"tokens" yaml:"tokens"`
+}
+
+//LogConfig selects the log output format: logfmt or json
+type LogConfig struct {
+	Format string `json:"format" yaml:"format"`
+}
+
+//Duration is a time.Duration written as "30s" in config files
+type Duration time.Duration
+
+//Std returns d as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+//Set parses s with time.ParseDuration
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+//MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+//UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %s", b)
+	}
+	return d.Set(s)
+}
+
+//MarshalYAML implements yaml.Marshaler
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+//UnmarshalYAML implements yaml.Unmarshaler
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
+
+//DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() Config {
+	return Config{
+		Listen: ListenConfig{
+			HTTP: ":" + defaultPort,
+		},
+		Store: "mysql",
+		DB: DBConfig{
+			MaxOpenConns: 10,
+			MaxIdleConns: 3,
+		},
+		Cache: CacheConfig{
+			BannerTTL:       Duration(30 * time.Second),
+			StaleTTL:        Duration(5 * time.Minute),
+			RefreshInterval: Duration(15 * time.Second),
+		},
+		Impressions: ImpressionsConfig{
+			QueueSize:       10000,
+			BatchSize:       100,
+			FlushInterval:   Duration(time.Second),
+			ShutdownTimeout: Duration(10 * time.Second),
+		},
+		Click: ClickConfig{
+			TTL: Duration(24 * time.Hour),
+		},
+		Viewability: ViewabilityConfig{
+			HeartbeatInterval: Duration(3 * time.Second),
+			IdleTimeout:       Duration(30 * time.Second),
+			ViewableThreshold: Duration(time.Second),
+			MaxSessions:       100000,
+		},
+		AdTag: AdTagConfig{
+			RotateInterval: Duration(30 * time.Second),
+			MaxAge:         Duration(5 * time.Minute),
+		},
+		Tags: TagsConfig{
+			Mode:             "off",
+			Boost:            1,
+			HalfLife:         Duration(7 * 24 * time.Hour),
+			MaxTags:          50,
+			ImpressionWeight: 0.1,
+			ClickWeight:      1,
+			QueueSize:        10000,
+		},
+		Pacing: PacingConfig{
+			Interval: Duration(time.Minute),
+		},
+		Log: LogConfig{
+			Format: "logfmt",
+		},
+	}
+}
+
+//setting binds one config field to an environment variable and a flag
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value func(c *Config) flag.Value
+}
+
+//settings lists every field that can be overridden outside the config file
+var settings = []setting{
+	{"http.addr", "ADV_HTTP_ADDR", "HTTP listen address", func(c *Config) flag.Value { return (*stringValue)(&c.Listen.HTTP) }},
+	{"grpc.addr", "ADV_GRPC_ADDR", "gRPC listen address", func(c *Config) flag.Value { return (*stringValue)(&c.Listen.GRPC) }},
+	{"thrift.addr", "ADV_THRIFT_ADDR", "Thrift listen address, framed binary protocol", func(c *Config) flag.Value { return (*stringValue)(&c.Listen.Thrift) }},
+	{"debug.addr", "ADV_DEBUG_ADDR", "Debug and metrics listen address", func(c *Config) flag.Value { return (*stringValue)(&c.Listen.Debug) }},
+	{"store", "ADV_STORE", "Banner store: mysql or memory", func(c *Config) flag.Value { return (*stringValue)(&c.Store) }},
+	{"db.dsn", "ADV_DSN", "MySQL DSN, required by the mysql store", func(c *Config) flag.Value { return (*stringValue)(&c.DB.DSN) }},
+	{"db.max-open-conns", "ADV_DB_MAX_OPEN_CONNS", "Maximum open MySQL connections", func(c *Config) flag.Value { return (*intValue)(&c.DB.MaxOpenConns) }},
+	{"db.max-idle-conns", "ADV_DB_MAX_IDLE_CONNS", "Maximum idle MySQL connections", func(c *Config) flag.Value { return (*intValue)(&c.DB.MaxIdleConns) }},
+	{"db.conn-max-lifetime", "ADV_DB_CONN_MAX_LIFETIME", "Maximum lifetime of a MySQL connection, 0 for unlimited", func(c *Config) flag.Value { return &c.DB.ConnMaxLifetime }},
+	{"cache.banner-ttl", "ADV_CACHE_BANNER_TTL", "How long cached banners are fresh, 0 disables the cache", func(c *Config) flag.Value { return &c.Cache.BannerTTL }},
+	{"cache.stale-ttl", "ADV_CACHE_STALE_TTL", "How long stale banners are served while the store is down", func(c *Config) flag.Value { return &c.Cache.StaleTTL }},
+	{"cache.refresh-interval", "ADV_CACHE_REFRESH_INTERVAL", "How often cached banners are reloaded in the background", func(c *Config) flag.Value { return &c.Cache.RefreshInterval }},
+	{"impressions.queue-size", "ADV_IMPRESSIONS_QUEUE_SIZE", "Impressions buffered before new ones are dropped", func(c *Config) flag.Value { return (*intValue)(&c.Impressions.QueueSize) }},
+	{"impressions.batch-size", "ADV_IMPRESSIONS_BATCH_SIZE", "Impressions written per insert", func(c *Config) flag.Value { return (*intValue)(&c.Impressions.BatchSize) }},
+	{"impressions.flush-interval", "ADV_IMPRESSIONS_FLUSH_INTERVAL", "Longest time an impression waits before being written", func(c *Config) flag.Value { return &c.Impressions.FlushInterval }},
+	{"click.secret", "ADV_CLICK_SECRET", "HMAC key signing click tokens", func(c *Config) flag.Value { return (*stringValue)(&c.Click.Secret) }},
+	{"click.ttl", "ADV_CLICK_TTL", "How long click tracking URLs stay valid", func(c *Config) flag.Value { return &c.Click.TTL }},
+	{"click.base-url", "ADV_CLICK_BASE_URL", "Public URL prefixed to click tracking links", func(c *Config) flag.Value { return (*stringValue)(&c.Click.BaseURL) }},
+	{"ad-tag.rotate-interval", "ADV_AD_TAG_ROTATE_INTERVAL", "How long the ad tag shows banners before replacing them, 0 disables rotation", func(c *Config) flag.Value { return &c.AdTag.RotateInterval }},
+	{"ad-tag.max-age", "ADV_AD_TAG_MAX_AGE", "How long browsers may cache the ad tag", func(c *Config) flag.Value { return &c.AdTag.MaxAge }},
+	{"targeting.default-group", "ADV_DEFAULT_GROUP", "Banner group served on unregistered websites, 0 to reject them", func(c *Config) flag.Value { return (*intValue)(&c.Targeting.DefaultGroup) }},
+	{"languages.default", "ADV_DEFAULT_LANGUAGE", "Language ending every fallback chain", func(c *Config) flag.Value { return (*stringValue)(&c.Languages.Default) }},
+	{"tags.mode", "ADV_TAGS_MODE", "Default tag targeting mode: off, boost or require", func(c *Config) flag.Value { return (*stringValue)(&c.Tags.Mode) }},
+	{"tags.boost", "ADV_TAGS_BOOST", "Extra weight of banners fully matching the visitor profile", func(c *Config) flag.Value { return (*floatValue)(&c.Tags.Boost) }},
+	{"tags.half-life", "ADV_TAGS_HALF_LIFE", "How long it takes a visitor tag score to halve", func(c *Config) flag.Value { return &c.Tags.HalfLife }},
+	{"frequency.banner-per-hour", "ADV_FREQ_BANNER_PER_HOUR", "Impressions of a banner per visitor per hour, 0 for unlimited", func(c *Config) flag.Value { return (*intValue)(&c.Frequency.BannerPerHour) }},
+	{"frequency.banner-per-day", "ADV_FREQ_BANNER_PER_DAY", "Impressions of a banner per visitor per day, 0 for unlimited", func(c *Config) flag.Value { return (*intValue)(&c.Frequency.BannerPerDay) }},
+	{"frequency.campaign-per-hour", "ADV_FREQ_CAMPAIGN_PER_HOUR", "Impressions of a campaign per visitor per hour, 0 for unlimited", func(c *Config) flag.Value { return (*intValue)(&c.Frequency.CampaignPerHour) }},
+	{"frequency.campaign-per-day", "ADV_FREQ_CAMPAIGN_PER_DAY", "Impressions of a campaign per visitor per day, 0 for unlimited", func(c *Config) flag.Value { return (*intValue)(&c.Frequency.CampaignPerDay) }},
+	{"pacing.interval", "ADV_PACING_INTERVAL", "How often pacing probabilities are recomputed", func(c *Config) flag.Value { return &c.Pacing.Interval }},
+	{"admin.tokens", "ADV_ADMIN_TOKENS", "Comma separated name=token pairs of admins", func(c *Config) flag.Value { return tokensValue{c} }},
+	{"log.format", "ADV_LOG_FORMAT", "Log format: logfmt or json", func(c *Config) flag.Value { return (*stringValue)(&c.Log.Format) }},
+}
+
+type stringValue string
+
+func (v *stringValue) String() string     { return string(*v) }
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("not an integer: %q", s)
+	}
+	*v = intValue(i)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("not a number: %q", s)
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+//tokensValue parses name=token pairs into the admin tokens, never printing a token
+type tokensValue struct{ c *Config }
+
+func (v tokensValue) String() string {
+	names := make([]string, 0, len(v.c.Admin.Tokens))
+	for name := range v.c.Admin.Tokens {
+		names = append(names, name+"="+redacted)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (v tokensValue) Set(s string) error {
+	if v.c.Admin.Tokens == nil {
+		v.c.Admin.Tokens = map[string]string{}
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("%q is not a name=token pair", pair)
+		}
+		v.c.Admin.Tokens[pair[:i]] = pair[i+1:]
+	}
+	return nil
+}
+
+//flagSetting is a setting given on the command line with its argument
+type flagSetting struct {
+	setting
+	arg string
+}
+
+//flagValue checks a flag argument on the usage config and records it, so
+//it is applied as given on top of the file and the environment
+type flagValue struct {
+	flag.Value
+	setting setting
+	set     *[]flagSetting
+}
+
+func (v *flagValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+func (v *flagValue) Set(s string) error {
+	if err := v.Value.Set(s); err != nil {
+		return err
+	}
+	*v.set = append(*v.set, flagSetting{v.setting, s})
+	return nil
+}
+
+//options are the command line switches that are not part of Config
+type options struct {
+	configPath  string
+	printConfig bool
+	//args are the arguments after the flags, a command like migrate or seed
+	args []string
+}
+
+//loadConfig builds the Config from defaults, the config file, the environment
+//and args, in increasing order of precedence, and validates the result.
+func loadConfig(args []string, getenv func(string) string) (Config, options, error) {
+	var opts options
+	fs := flag.NewFlagSet("adservice", flag.ContinueOnError)
+	fs.StringVar(&opts.configPath, "config", getenv("ADV_CONFIG"), "Path to a YAML or JSON config file")
+	fs.BoolVar(&opts.printConfig, "print-config", false, "Print the effective configuration with secrets redacted and exit")
+	usage := DefaultConfig()
+	var flags []flagSetting
+	for _, s := range settings {
+		fs.Var(&flagValue{Value: s.value(&usage), setting: s, set: &flags}, s.flag, s.usage+" (env "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, opts, err
+	}
+	opts.args = fs.Args()
+
+	cfg := DefaultConfig()
+	if opts.configPath != "" {
+		if err := loadConfigFile(opts.configPath, &cfg); err != nil {
+			return Config{}, opts, err
+		}
+	}
+	//PORT predates the config subsystem and is kept for compatibility
+	if port := getenv("PORT"); port != "" {
+		cfg.Listen.HTTP = ":" + port
+	}
+	for _, s := range settings {
+		if e := getenv(s.env); e != "" {
+			if err := s.value(&cfg).Set(e); err != nil {
+				return Config{}, opts, fmt.Errorf("config: env %s: %v", s.env, err)
+			}
+		}
+	}
+	for _, f := range flags {
+		if err := f.value(&cfg).Set(f.arg); err != nil {
+			return Config{}, opts, fmt.Errorf("config: flag -%s: %v", f.flag, err)
+		}
+	}
+	return cfg, opts, cfg.Validate()
+}
+
+//loadConfigFile merges the file at path into cfg. The format is picked by
+//extension: .json is JSON, anything else is YAML.
+func loadConfigFile(path string, cfg *Config) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, cfg)
+	default:
+		err = yaml.UnmarshalStrict(b, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %v", path, err)
+	}
+	return nil
+}
+
+//Validate checks cfg and reports every problem found in a single error
+func (c Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	checkAddr := func(name, addr string, required bool) {
+		if addr == "" {
+			if required {
+				add("%s: must be set", name)
+			}
+			return
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			add("%s: %q is not a host:port address", name, addr)
+		}
+	}
+	checkAddr("listen.http", c.Listen.HTTP, true)
+	checkAddr("listen.grpc", c.Listen.GRPC, false)
+	checkAddr("listen.thrift", c.Listen.Thrift, false)
+	checkAddr("listen.debug", c.Listen.Debug, false)
+
+	switch c.Store {
+	case "mysql":
+		if c.DB.DSN == "" {
+			add("db.dsn: must be set when store is mysql")
+		} else if _, err := mysql.ParseDSN(c.DB.DSN); err != nil {
+			add("db.dsn: %v", err)
+		}
+	case "memory":
+	default:
+		add("store: %q is not one of mysql, memory", c.Store)
+	}
+	if c.DB.MaxOpenConns <= 0 {
+		add("db.max_open_conns: must be positive, got %d", c.DB.MaxOpenConns)
+	}
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		add("db.max_idle_conns: must be between 0 and max_open_conns, got %d", c.DB.MaxIdleConns)
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		add("db.conn_max_lifetime: must not be negative")
+	}
+	if c.Cache.BannerTTL < 0 {
+		add("cache.banner_ttl: must not be negative")
+	}
+	if c.Cache.StaleTTL < 0 {
+		add("cache.stale_ttl: must not be negative")
+	}
+	if c.Cache.BannerTTL > 0 && c.Cache.RefreshInterval <= 0 {
+		add("cache.refresh_interval: must be positive when the cache is enabled")
+	}
+	if c.Impressions.QueueSize <= 0 {
+		add("impressions.queue_size: must be positive, got %d", c.Impressions.QueueSize)
+	}
+	if c.Impressions.BatchSize <= 0 || c.Impressions.BatchSize > c.Impressions.QueueSize {
+		add("impressions.batch_size: must be between 1 and queue_size, got %d", c.Impressions.BatchSize)
+	}
+	if c.Impressions.FlushInterval <= 0 {
+		add("impressions.flush_interval: must be positive")
+	}
+	if c.Impressions.ShutdownTimeout <= 0 {
+		add("impressions.shutdown_timeout: must be positive")
+	}
+	if c.Click.Secret != "" && len(c.Click.Secret) < 16 {
+		add("click.secret: must be at least 16 bytes")
+	}
+	for name, token := range c.Admin.Tokens {
+		if len(token) < 16 {
+			add("admin.tokens.%s: must be at least 16 bytes", name)
+		}
+	}
+	if c.Click.TTL <= 0 {
+		add("click.ttl: must be positive")
+	}
+	if c.Click.BaseURL != "" {
+		if u, err := url.Parse(c.Click.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("click.base_url: %q is not an absolute URL", c.Click.BaseURL)
+		}
+	}
+	if c.Viewability.HeartbeatInterval <= 0 {
+		add("viewability.heartbeat_interval: must be positive")
+	}
+	if c.Viewability.IdleTimeout < c.Viewability.HeartbeatInterval {
+		add("viewability.idle_timeout: must not be shorter than heartbeat_interval")
+	}
+	if c.Viewability.ViewableThreshold < 0 {
+		add("viewability.viewable_threshold: must not be negative")
+	}
+	if c.Viewability.MaxSessions <= 0 {
+		add("viewability.max_sessions: must be positive, got %d", c.Viewability.MaxSessions)
+	}
+	if c.AdTag.RotateInterval < 0 || (c.AdTag.RotateInterval > 0 && c.AdTag.RotateInterval < c.Viewability.HeartbeatInterval) {
+		add("ad_tag.rotate_interval: must be 0 or at least viewability.heartbeat_interval")
+	}
+	if c.AdTag.MaxAge < 0 {
+		add("ad_tag.max_age: must not be negative")
+	}
+	if c.Targeting.DefaultGroup < 0 {
+		add("targeting.default_group: must not be negative")
+	}
+	for tag, chain := range c.Languages.Fallbacks {
+		if !myservice.ValidLanguageTag(tag) {
+			add("languages.fallbacks: %q is not a language tag", tag)
+		}
+		for _, f := range chain {
+			if !myservice.ValidLanguageTag(f) {
+				add("languages.fallbacks.%s: %q is not a language tag", tag, f)
+			}
+		}
+	}
+	if c.Languages.Default != "" && !myservice.ValidLanguageTag(c.Languages.Default) {
+		add("languages.default: %q is not a language tag", c.Languages.Default)
+	}
+	switch c.Tags.Mode {
+	case "off", "boost", "require":
+	default:
+		add("tags.mode: %q is not one of off, boost, require", c.Tags.Mode)
+	}
+	if c.Tags.Boost < 0 {
+		add("tags.boost: must not be negative")
+	}
+	if c.Tags.HalfLife <= 0 {
+		add("tags.half_life: must be positive")
+	}
+	if c.Tags.MaxTags <= 0 {
+		add("tags.max_tags: must be positive, got %d", c.Tags.MaxTags)
+	}
+	if c.Tags.ImpressionWeight < 0 || c.Tags.ClickWeight < 0 {
+		add("tags: impression_weight and click_weight must not be negative")
+	}
+	if c.Tags.QueueSize <= 0 {
+		add("tags.queue_size: must be positive, got %d", c.Tags.QueueSize)
+	}
+	if c.Frequency.BannerPerHour < 0 || c.Frequency.BannerPerDay < 0 || c.Frequency.CampaignPerHour < 0 || c.Frequency.CampaignPerDay < 0 {
+		add("frequency: caps must not be negative")
+	}
+	if n := len(c.Pacing.Curve); n != 0 {
+		sum := 0.0
+		for _, w := range c.Pacing.Curve {
+			if w < 0 {
+				add("pacing.curve: weights must not be negative")
+				break
+			}
+			sum += w
+		}
+		if n != 24 || sum <= 0 {
+			add("pacing.curve: must be empty or 24 hourly weights with a positive sum")
+		}
+	}
+	if c.Pacing.Interval <= 0 {
+		add("pacing.interval: must be positive")
+	}
+	switch c.Log.Format {
+	case "logfmt", "json":
+	default:
+		add("log.format: %q is not one of logfmt, json", c.Log.Format)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("config: invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+const redacted = "REDACTED"
+
+var dsnPassword = regexp.MustCompile(`^([^:@/]*):[^@]*@`)
+
+//Redacted returns a copy of c that is safe to print
+func (c Config) Redacted() Config {
+	c.DB.DSN = dsnPassword.ReplaceAllString(c.DB.DSN, "${1}:"+redacted+"@")
+	if c.Click.Secret != "" {
+		c.Click.Secret = redacted
+	}
+	if len(c.Admin.Tokens) > 0 {
+		tokens := make(map[string]string, len(c.Admin.Tokens))
+		for name := range c.Admin.Tokens {
+			tokens[name] = redacted
+		}
+		c.Admin.Tokens = tokens
+	}
+	return c
+}
+
+//printConfig renders the redacted configuration as YAML
+func printConfig(c Config) (string, error) {
+	b, err := yaml.Marshal(c.Redacted())
+	return string(b), err
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envMap(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "adservice-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	if _, _, err := loadConfig(nil, envMap(nil)); err == nil || !strings.Contains(err.Error(), "db.dsn") {
+		t.Errorf("want the mysql store to need a dsn, got %v", err)
+	}
+	cfg, _, err := loadConfig(nil, envMap(map[string]string{"ADV_DSN": "adv:secret@tcp(127.0.0.1:3306)/adv"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen.HTTP != ":80" || cfg.DB.MaxOpenConns != 10 || cfg.DB.MaxIdleConns != 3 {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadConfigLayering(t *testing.T) {
+	path := writeFile(t, "adservice.yaml", `
+listen:
+  http: ":8000"
+store: memory
+db:
+  max_open_conns: 20
+cache:
+  banner_ttl: 1m
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+	env := envMap(map[string]string{
+		"ADV_CONFIG":            path,
+		"ADV_HTTP_ADDR":         ":8001",
+		"ADV_DB_MAX_OPEN_CONNS": "30",
+	})
+	cfg, _, err := loadConfig([]string{"-http.addr", ":8002"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen.HTTP != ":8002" {
+		t.Errorf("flag should win: got %q", cfg.Listen.HTTP)
+	}
+	if cfg.DB.MaxOpenConns != 30 {
+		t.Errorf("env should override file: got %d", cfg.DB.MaxOpenConns)
+	}
+	if cfg.Store != "memory" || cfg.Cache.BannerTTL.Std() != time.Minute {
+		t.Errorf("file values lost: %+v", cfg)
+	}
+}
+
+func TestLoadConfigAdminTokenFlags(t *testing.T) {
+	env := envMap(map[string]string{"ADV_STORE": "memory", "ADV_ADMIN_TOKENS": "bob=bbbbbbbbbbbbbbbb"})
+	cfg, _, err := loadConfig([]string{"-admin.tokens", "alice=0123456789abcdef0123456789abcdef", "-admin.tokens", "carol=cccccccccccccccc"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"alice": "0123456789abcdef0123456789abcdef",
+		"bob":   "bbbbbbbbbbbbbbbb",
+		"carol": "cccccccccccccccc",
+	}
+	if len(cfg.Admin.Tokens) != len(want) {
+		t.Fatalf("want %d tokens, got %d", len(want), len(cfg.Admin.Tokens))
+	}
+	for name, token := range want {
+		if cfg.Admin.Tokens[name] != token {
+			t.Errorf("token of %s not taken from the flag as given", name)
+		}
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeFile(t, "adservice.json", `{"store": "memory", "log": {"format": "json"}, "cache": {"stale_ttl": "10s"}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	cfg, _, err := loadConfig([]string{"-config", path}, envMap(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.Format != "json" || cfg.Cache.StaleTTL.Std() != 10*time.Second {
+		t.Errorf("json file not applied: %+v", cfg)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	env := envMap(map[string]string{"ADV_STORE": "redis", "ADV_LOG_FORMAT": "xml", "ADV_DEFAULT_LANGUAGE": "e n"})
+	_, _, err := loadConfig([]string{"-http.addr", "nope"}, env)
+	if err == nil {
+		t.Fatal("want validation error")
+	}
+	for _, want := range []string{"listen.http", "store", "log.format", "languages.default"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if _, _, err := loadConfig(nil, envMap(map[string]string{"ADV_DB_MAX_OPEN_CONNS": "many"})); err == nil {
+		t.Error("want error for non numeric env")
+	}
+}
+
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DB.DSN = "root:db-password@tcp(127.0.0.1:3306)/adv?charset=utf8"
+	cfg.Click.Secret = "0123456789abcdef-secret"
+	cfg.Admin.Tokens = map[string]string{"ops": "0123456789abcdef-token"}
+	out, err := printConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "db-password") || strings.Contains(out, "abcdef-secret") || strings.Contains(out, "abcdef-token") {
+		t.Errorf("password leaked:\n%s", out)
+	}
+	if !strings.Contains(out, "root:"+redacted+"@tcp(127.0.0.1:3306)") {
+		t.Errorf("dsn not redacted as expected:\n%s", out)
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	if _, _, err := loadConfig([]string{"-config", "adservice.example.yaml"}, envMap(nil)); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,34 +1,355 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"flag"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/go-kit/kit/log"
-)
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
-const (
-	defaultPort = "80"
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myservice"
+	"jf/adservice/pkg/mytransport"
+	"jf/adservice/pkg/pb"
+	adthrift "jf/adservice/pkg/thrift/gen-go/adservice"
 )
 
+const defaultPort = "80"
+
 func main() {
-	var (
-		port     = envString("PORT", defaultPort)
-		httpAddr = flag.String("http.addr", ":"+port, "HTTP listen Ports")
-	)
-	flag.Parse()
+	cfg, opts, err := loadConfig(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.printConfig {
+		out, err := printConfig(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		return
+	}
+	if len(opts.args) > 0 {
+		os.Exit(runCommand(cfg, opts.args, os.Stdout, os.Stderr))
+	}
+
 	var logger log.Logger
 	{
-		logger = log.NewLogfmtLogger(os.Stderr)
+		if cfg.Log.Format == "json" {
+			logger = log.NewJSONLogger(os.Stderr)
+		} else {
+			logger = log.NewLogfmtLogger(os.Stderr)
+		}
 		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
 	}
 
-}
+	var store models.Store
+	switch cfg.Store {
+	case "mysql":
+		db, err := models.OpenMySQL(cfg.DB.DSN, models.PoolConfig{
+			MaxOpenConns:    cfg.DB.MaxOpenConns,
+			MaxIdleConns:    cfg.DB.MaxIdleConns,
+			ConnMaxLifetime: cfg.DB.ConnMaxLifetime.Std(),
+		})
+		if err != nil {
+			logger.Log("store", "mysql", "err", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		store = models.NewMySQLStore(db)
+	case "memory":
+		store = models.NewMemoryStore()
+	}
 
-func envString(env, fallback string) string {
-	e := os.Getenv(env)
-	if e == "" {
-		return fallback
+	var duration metrics.Histogram
+	{
+		// Endpoint-level metrics.
+		duration = kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+			Namespace: "adservice",
+			Subsystem: "endpoint",
+			Name:      "request_duration_seconds",
+			Help:      "Request duration in seconds.",
+		}, []string{"method", "success"})
+	}
+
+	// Lookups of the service go through the cache, and so do campaign
+	// counters to keep cached campaigns current. Logs go straight to the
+	// store.
+	bannerStore := store
+	var cache *myservice.BannerCache
+	if cfg.Cache.BannerTTL > 0 {
+		cache = myservice.NewBannerCache(store, log.With(logger, "component", "cache"), myservice.CacheConfig{
+			TTL:             cfg.Cache.BannerTTL.Std(),
+			StaleTTL:        cfg.Cache.StaleTTL.Std(),
+			RefreshInterval: cfg.Cache.RefreshInterval.Std(),
+		}, myservice.CacheMetrics{
+			Hits: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "cache",
+				Name:      "hits_total",
+				Help:      "Store lookups answered from the cache.",
+			}, []string{}),
+			Misses: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "cache",
+				Name:      "misses_total",
+				Help:      "Store lookups read through to the store.",
+			}, []string{}),
+			Stale: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "cache",
+				Name:      "stale_total",
+				Help:      "Store lookups answered past their TTL.",
+			}, []string{}),
+			Errors: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "cache",
+				Name:      "errors_total",
+				Help:      "Failed cache loads.",
+			}, []string{}),
+		})
+		bannerStore = cache
+	}
+
+	var impressions *myservice.ImpressionLogger
+	{
+		impressions = myservice.NewImpressionLogger(store, log.With(logger, "component", "impressions"), myservice.ImpressionConfig{
+			QueueSize:     cfg.Impressions.QueueSize,
+			BatchSize:     cfg.Impressions.BatchSize,
+			FlushInterval: cfg.Impressions.FlushInterval.Std(),
+		}, myservice.ImpressionMetrics{
+			Queued: kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+				Namespace: "adservice",
+				Subsystem: "impressions",
+				Name:      "queued",
+				Help:      "Impressions waiting to be written.",
+			}, []string{}),
+			Dropped: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "impressions",
+				Name:      "dropped_total",
+				Help:      "Impressions dropped because the queue was full.",
+			}, []string{}),
+			Written: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "impressions",
+				Name:      "written_total",
+				Help:      "Impressions written to the store.",
+			}, []string{}),
+			Failed: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+				Namespace: "adservice",
+				Subsystem: "impressions",
+				Name:      "failed_total",
+				Help:      "Impressions lost because the store rejected the batch.",
+			}, []string{}),
+		})
+	}
+
+	campaigns := myservice.NewCampaignCounter(bannerStore, log.With(logger, "component", "campaigns"), myservice.CampaignCounterConfig{
+		QueueSize:     cfg.Impressions.QueueSize,
+		FlushInterval: cfg.Impressions.FlushInterval.Std(),
+	}, myservice.CampaignMetrics{
+		Dropped: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "adservice",
+			Subsystem: "campaigns",
+			Name:      "dropped_total",
+			Help:      "Campaign impressions not counted because the queue was full.",
+		}, []string{}),
+		Failed: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "adservice",
+			Subsystem: "campaigns",
+			Name:      "failed_total",
+			Help:      "Campaign impressions lost because the store rejected the update.",
+		}, []string{}),
+	})
+
+	viewability := myservice.NewViewabilityAggregator(store, log.With(logger, "component", "viewability"), myservice.ViewabilityConfig{
+		HeartbeatInterval: cfg.Viewability.HeartbeatInterval.Std(),
+		IdleTimeout:       cfg.Viewability.IdleTimeout.Std(),
+		ViewableThreshold: cfg.Viewability.ViewableThreshold.Std(),
+		MaxSessions:       cfg.Viewability.MaxSessions,
+	})
+
+	profiles := myservice.NewTagProfiles(store, log.With(logger, "component", "profiles"), myservice.TagConfig{
+		HalfLife:         cfg.Tags.HalfLife.Std(),
+		MaxTags:          cfg.Tags.MaxTags,
+		ImpressionWeight: cfg.Tags.ImpressionWeight,
+		ClickWeight:      cfg.Tags.ClickWeight,
+		QueueSize:        cfg.Tags.QueueSize,
+	})
+
+	curve, err := myservice.NewPacingCurve(cfg.Pacing.Curve)
+	if err != nil {
+		logger.Log("component", "pacing", "err", err)
+		os.Exit(1)
+	}
+
+	capper, err := myservice.NewFrequencyCapper(myservice.NewMemoryCounters(),
+		myservice.FrequencyCap{Scope: myservice.CapBanner, Window: time.Hour, Max: cfg.Frequency.BannerPerHour},
+		myservice.FrequencyCap{Scope: myservice.CapBanner, Window: 24 * time.Hour, Max: cfg.Frequency.BannerPerDay},
+		myservice.FrequencyCap{Scope: myservice.CapCampaign, Window: time.Hour, Max: cfg.Frequency.CampaignPerHour},
+		myservice.FrequencyCap{Scope: myservice.CapCampaign, Window: 24 * time.Hour, Max: cfg.Frequency.CampaignPerDay},
+	)
+	if err != nil {
+		logger.Log("component", "frequency", "err", err)
+		os.Exit(1)
+	}
+
+	var clickSigner *myservice.ClickSigner
+	{
+		key := []byte(cfg.Click.Secret)
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				logger.Log("component", "click", "err", err)
+				os.Exit(1)
+			}
+			logger.Log("component", "click", "warning", "no click.secret configured, tracking URLs will not survive a restart")
+		}
+		clickSigner = myservice.NewClickSigner(key, cfg.Click.TTL.Std())
+	}
+
+	var service myservice.AdService
+	{
+		service = myservice.NewService(bannerStore,
+			myservice.WithImpressions(impressions),
+			myservice.WithCampaignCounter(campaigns),
+			myservice.WithClickTracking(clickSigner, cfg.Click.BaseURL),
+			myservice.WithHeartbeats(viewability),
+			myservice.WithDefaultGroup(cfg.Targeting.DefaultGroup),
+			myservice.WithLanguages(myservice.NewLanguages(cfg.Languages.Fallbacks, cfg.Languages.Default)),
+			myservice.WithTagTargeting(profiles, cfg.Tags.Mode, cfg.Tags.Boost),
+			myservice.WithFrequencyCaps(capper),
+			myservice.WithLogger(log.With(logger, "component", "service")),
+			myservice.WithPacing(myservice.NewPacer(myservice.PacingConfig{Curve: curve, Interval: cfg.Pacing.Interval.Std()})),
+			myservice.WithAdTag(myservice.AdTagOptions{
+				HeartbeatInterval: cfg.Viewability.HeartbeatInterval.Std(),
+				RotateInterval:    cfg.AdTag.RotateInterval.Std(),
+				MaxAge:            cfg.AdTag.MaxAge.Std(),
+			}),
+		)
+		service = myservice.LoggingMiddleware(logger)(service)
+	}
+	// Admin changes go straight to the store and invalidate the cache.
+	auth := myservice.NewAuthenticator(cfg.Admin.Tokens)
+	if len(cfg.Admin.Tokens) == 0 {
+		logger.Log("component", "admin", "warning", "no admin.tokens configured, the admin API rejects every request")
+	}
+	var adminService myservice.AdminService
+	{
+		var adminOptions []myservice.AdminOption
+		if cache != nil {
+			adminOptions = append(adminOptions, myservice.WithInvalidator(cache))
+		}
+		adminService = myservice.NewAdminService(store, adminOptions...)
+		adminService = myservice.AdminLoggingMiddleware(logger)(adminService)
+	}
+	var (
+		endpoints      = myendpoint.New(service, logger, duration)
+		adminEndpoints = myendpoint.NewAdmin(adminService, auth, logger, duration)
+	)
+	endpoints.PacingEndpoint = myendpoint.AuthMiddleware(auth)(endpoints.PacingEndpoint)
+	httpHandler := mytransport.NewAdminHTTPHandler(adminEndpoints, mytransport.NewHTTPHandler(endpoints, logger), logger)
+
+	errs := make(chan error, 5)
+	if cfg.Listen.Debug != "" {
+		debugMux := http.NewServeMux()
+		debugMux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			logger.Log("transport", "debug/HTTP", "addr", cfg.Listen.Debug)
+			errs <- http.ListenAndServe(cfg.Listen.Debug, debugMux)
+		}()
+	}
+	httpServer := &http.Server{Addr: cfg.Listen.HTTP, Handler: httpHandler}
+	go func() {
+		logger.Log("transport", "HTTP", "addr", cfg.Listen.HTTP, "store", cfg.Store)
+		errs <- httpServer.ListenAndServe()
+	}()
+	var grpcServer *grpc.Server
+	if cfg.Listen.GRPC != "" {
+		grpcListener, err := net.Listen("tcp", cfg.Listen.GRPC)
+		if err != nil {
+			logger.Log("transport", "gRPC", "during", "Listen", "err", err)
+			os.Exit(1)
+		}
+		grpcServer = grpc.NewServer()
+		pb.RegisterAdServiceServer(grpcServer, mytransport.NewGRPCServer(endpoints, logger))
+		go func() {
+			logger.Log("transport", "gRPC", "addr", cfg.Listen.GRPC)
+			errs <- grpcServer.Serve(grpcListener)
+		}()
+	}
+	var thriftServer *thrift.TSimpleServer
+	if cfg.Listen.Thrift != "" {
+		thriftSocket, err := thrift.NewTServerSocket(cfg.Listen.Thrift)
+		if err != nil {
+			logger.Log("transport", "Thrift", "during", "Listen", "err", err)
+			os.Exit(1)
+		}
+		thriftServer = thrift.NewTSimpleServer4(
+			adthrift.NewAdServiceProcessor(mytransport.NewThriftServer(endpoints)),
+			thriftSocket,
+			thrift.NewTFramedTransportFactory(thrift.NewTTransportFactory()),
+			thrift.NewTBinaryProtocolFactoryDefault(),
+		)
+		go func() {
+			logger.Log("transport", "Thrift", "addr", cfg.Listen.Thrift)
+			errs <- thriftServer.Serve()
+		}()
+	}
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		errs <- fmt.Errorf("%s", <-c)
+	}()
+	logger.Log("terminated", <-errs)
+
+	// Stop serving first so no impression is recorded after the flush.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Impressions.ShutdownTimeout.Std())
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Log("transport", "HTTP", "during", "Shutdown", "err", err)
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if thriftServer != nil {
+		thriftServer.Stop()
+	}
+	if err := impressions.Close(ctx); err != nil {
+		logger.Log("component", "impressions", "during", "Close", "err", err)
+	}
+	if err := campaigns.Close(ctx); err != nil {
+		logger.Log("component", "campaigns", "during", "Close", "err", err)
+	}
+	if err := viewability.Close(ctx); err != nil {
+		logger.Log("component", "viewability", "during", "Close", "err", err)
+	}
+	if err := profiles.Close(ctx); err != nil {
+		logger.Log("component", "profiles", "during", "Close", "err", err)
+	}
+	if cache != nil {
+		if err := cache.Close(ctx); err != nil {
+			logger.Log("component", "cache", "during", "Close", "err", err)
+		}
 	}
-	return e
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"errors"
+)
+
+//ErrVersionConflict is returned when a row was changed since the version an update names
+var ErrVersionConflict = errors.New("models: version conflict")
+
+//ErrGroupInUse is returned when removing a banner group that rows still refer to
+var ErrGroupInUse = errors.New("models: group in use")
+
+//AdminStore changes banners, banner groups, clients and campaigns. Inserts assign the
+//ID and version 1; updates and removals only apply to the version named and
+//bump it, returning ErrVersionConflict if the row moved on and ErrNotFound
+//if it is gone.
+type AdminStore interface {
+	InsertBanner(ctx context.Context, b *Banner) error
+	UpdateBanner(ctx context.Context, b *Banner) error
+	RemoveBanner(ctx context.Context, id, version int) error
+
+	GetBannerGroup(ctx context.Context, id int) (BannerGroup, error)
+	InsertBannerGroup(ctx context.Context, g *BannerGroup) error
+	UpdateBannerGroup(ctx context.Context, g *BannerGroup) error
+	RemoveBannerGroup(ctx context.Context, id, version int) error
+	//CountGroupReferences counts the banners of any status, the clients and
+	//the websites assigned to a group. In a transaction they stay locked
+	//until it ends.
+	CountGroupReferences(ctx context.Context, groupID int) (int, error)
+
+	GetClient(ctx context.Context, id int) (Client, error)
+	//InsertClient and UpdateClient also store the ClientBanner of c
+	InsertClient(ctx context.Context, c *Client) error
+	UpdateClient(ctx context.Context, c *Client) error
+	RemoveClient(ctx context.Context, id, version int) error
+
+	InsertCampaign(ctx context.Context, c *Campaign) error
+	//UpdateCampaign changes the name, flight, budgets and schedule of c,
+	//status and counters are left alone. c is set to the stored campaign.
+	UpdateCampaign(ctx context.Context, c *Campaign) error
+}
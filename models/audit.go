@@ -0,0 +1,115 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+//Audited entities
+const (
+	AuditBanner   = "banner"
+	AuditGroup    = "group"
+	AuditClient   = "client"
+	AuditCampaign = "campaign"
+)
+
+//AuditEntry records who changed what through the admin API. Entries are
+//only ever appended.
+type AuditEntry struct {
+	ID    int    `json:"id"`
+	Actor string `json:"actor"`
+	//Entity is the kind of row changed: banner, group, client or campaign
+	Entity   string `json:"entity"`
+	EntityID int    `json:"entity_id"`
+	//Action is create, update, delete, or a status change like activate
+	Action string `json:"action"`
+	//Version is the version of the row after the change
+	Version int `json:"version"`
+	//Changes are the fields that differ between the row before and after
+	Changes []FieldChange `json:"changes,omitempty"`
+	//Date is a unix time in seconds
+	Date int `json:"date"`
+}
+
+//FieldChange is one changed field in its JSON form. Before is empty for
+//created rows, After for deleted ones.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+//Diff returns the JSON fields that differ between before and after, ordered
+//by name. Either may be nil for created or deleted rows. The version field
+//is left out, entries carry it on their own.
+func Diff(before, after interface{}) ([]FieldChange, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range b {
+		names = append(names, name)
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var changes []FieldChange
+	for _, name := range names {
+		if name != "version" && !bytes.Equal(b[name], a[name]) {
+			changes = append(changes, FieldChange{Field: name, Before: b[name], After: a[name]})
+		}
+	}
+	return changes, nil
+}
+
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
+
+//AuditQuery selects audit entries, zero fields match everything
+type AuditQuery struct {
+	Entity   string
+	EntityID int
+	//From and To bound Date in unix seconds, both inclusive
+	From int
+	To   int
+	//Limit caps the entries returned, 0 for no cap
+	Limit int
+}
+
+//matches reports whether e is selected by q
+func (q AuditQuery) matches(e AuditEntry) bool {
+	return (q.Entity == "" || e.Entity == q.Entity) &&
+		(q.EntityID == 0 || e.EntityID == q.EntityID) &&
+		(q.From == 0 || e.Date >= q.From) &&
+		(q.To == 0 || e.Date <= q.To)
+}
+
+//AuditStore appends and queries audit entries
+type AuditStore interface {
+	InsertAuditEntry(ctx context.Context, e AuditEntry) error
+	//Audited runs fn and appends the entry it returns in one transaction,
+	//neither the writes of fn nor the entry are stored if either fails
+	Audited(ctx context.Context, fn func(Store) (AuditEntry, error)) error
+	//QueryAuditEntries returns the entries q selects, newest first
+	QueryAuditEntries(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := BannerGroup{ID: 1, Name: "home", Version: 1}
+	after := BannerGroup{ID: 1, Name: "landing", Version: 2}
+	changes, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Field != "name" || string(changes[0].Before) != `"home"` || string(changes[0].After) != `"landing"` {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	changes, err = Diff(nil, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Field != "id" || changes[0].Before != nil {
+		t.Errorf("want id and name created, got %+v", changes)
+	}
+}
+
+func TestMemoryStoreQueryAuditEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, e := range []AuditEntry{
+		{Entity: AuditBanner, EntityID: 1, Action: "create", Date: 100},
+		{Entity: AuditBanner, EntityID: 2, Action: "create", Date: 200},
+		{Entity: AuditGroup, EntityID: 1, Action: "update", Date: 300},
+		{Entity: AuditBanner, EntityID: 1, Action: "update", Date: 400},
+	} {
+		if err := store.InsertAuditEntry(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := store.QueryAuditEntries(ctx, AuditQuery{Entity: AuditBanner, EntityID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Date != 400 || entries[1].Date != 100 {
+		t.Errorf("want banner 1 newest first, got %+v", entries)
+	}
+	entries, _ = store.QueryAuditEntries(ctx, AuditQuery{From: 200, To: 300})
+	if len(entries) != 2 || entries[0].Date != 300 {
+		t.Errorf("want entries in range, got %+v", entries)
+	}
+	entries, _ = store.QueryAuditEntries(ctx, AuditQuery{Limit: 1})
+	if len(entries) != 1 || entries[0].Date != 400 {
+		t.Errorf("want newest entry, got %+v", entries)
+	}
+}
+
+//testAudited checks that Audited stores a write with its entry, and
+//neither when the write fails
+func testAudited(t *testing.T, store Store) {
+	ctx := context.Background()
+	var g BannerGroup
+	err := store.Audited(ctx, func(tx Store) (AuditEntry, error) {
+		g = BannerGroup{Name: "home"}
+		err := tx.InsertBannerGroup(ctx, &g)
+		return AuditEntry{Actor: "ops", Entity: AuditGroup, EntityID: g.ID, Action: "create", Version: g.Version, Date: 100}, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.QueryAuditEntries(ctx, AuditQuery{Entity: AuditGroup, EntityID: g.ID})
+	if err != nil || len(entries) != 1 || entries[0].Action != "create" {
+		t.Fatalf("want the create entry, got %+v (%v)", entries, err)
+	}
+
+	errWrite := errors.New("write failed")
+	err = store.Audited(ctx, func(tx Store) (AuditEntry, error) {
+		return AuditEntry{Actor: "ops", Entity: AuditGroup, EntityID: g.ID, Action: "delete", Version: g.Version, Date: 200}, errWrite
+	})
+	if err != errWrite {
+		t.Errorf("want the write error, got %v", err)
+	}
+	if entries, _ := store.QueryAuditEntries(ctx, AuditQuery{Entity: AuditGroup, EntityID: g.ID}); len(entries) != 1 {
+		t.Errorf("want no entry for the failed write, got %+v", entries)
+	}
+}
+
+func TestMemoryStoreAudited(t *testing.T) {
+	testAudited(t, NewMemoryStore())
+}
+
+func TestMySQLAudited(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := NewMigrator(db).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	store := NewMySQLStore(db)
+	testAudited(t, store)
+
+	//A failing entry rolls the write back
+	var g BannerGroup
+	err := store.Audited(ctx, func(tx Store) (AuditEntry, error) {
+		g = BannerGroup{Name: "landing"}
+		err := tx.InsertBannerGroup(ctx, &g)
+		return AuditEntry{Entity: "an entity name too long for its column", EntityID: g.ID, Action: "create"}, err
+	})
+	if err == nil {
+		t.Fatal("want the entry rejected")
+	}
+	if _, err := store.GetBannerGroup(ctx, g.ID); err != ErrNotFound {
+		t.Errorf("want the group rolled back, got %v", err)
+	}
+}
@@ -1,44 +1,55 @@
 package models
 
+import "strings"
+
+//StatusActive marks a banner that may be served
+const StatusActive = 1
+
 //Banner describe the banner
 type Banner struct {
-	ID       int
-	GroupID  int
-	Name     string
-	Language string
-	Size     string
-	URL      string
+	ID       int    `json:"id"`
+	GroupID  int    `json:"group_id"`
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Size     string `json:"size"`
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	//Weight is the relative share of impressions among banners of the same priority
+	Weight int `json:"weight"`
+	//Priority tiers are served highest first, lower tiers only fill remaining slots
+	Priority int `json:"priority"`
+	//Tags describe the banner, LandingTags the page it links to
+	Tags        []string `json:"tags,omitempty"`
+	LandingTags []string `json:"landing_tags,omitempty"`
+	//CampaignID is the campaign the banner belongs to, 0 for none
+	CampaignID int `json:"campaign_id,omitempty"`
+	//DailyTarget is the number of impressions to spread over a day, 0 serves as traffic comes
+	DailyTarget int `json:"daily_target,omitempty"`
+	//Schedule limits the hours the banner is served, nil for always
+	Schedule *Schedule `json:"schedule,omitempty"`
+	//Version is bumped by every change, updates must name the version they change
+	Version int `json:"version"`
 }
 
-//GetBannerByID 根据ID获取Banner
-func GetBannerByID(id int) (Banner, error) {
-	banner := Banner{}
-	rows, err := db.Query("SELECT * FROM gw_adv_banner WHERE id=? LIMIT 1", id)
-	defer rows.Close()
-	if err == nil {
-		return banner, err
-	}
-	rows.Scan(&banner)
-	return banner, nil
+//Active reports whether the banner may be served
+func (b Banner) Active() bool {
+	return b.Status == StatusActive
 }
 
-//GetBannersBySize Get Banners By Size
-func GetBanners(size string, groupId int) ([]*Banner, error) {
-	var banners []*Banner
-	rows, err := db.Query("SELECT id,group_id, name, size, url FROM gw_adv_banner  WHERE status=1 AND size=? AND group_id=?", size, groupId)
-	defer rows.Close()
-	if err != nil {
-		return banners, err
-	}
-	for rows.Next() {
-		b := new(Banner)
-
-		if err := rows.Scan(&b.ID, &b.GroupID, &b.Name, &b.Size, &b.URL); err != nil {
-			return banners, err
+//SplitTags parses a comma separated tag column, tags are trimmed and lower cased
+func SplitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			tags = append(tags, t)
 		}
-		banners = append(banners, b)
 	}
-	return banners, nil
+	return tags
+}
+
+//JoinTags formats tags for a tag column
+func JoinTags(tags []string) string {
+	return strings.Join(tags, ",")
 }
 
 //ClientBanner relationship
@@ -47,11 +58,18 @@ type ClientBanner struct {
 	GroupID  int
 }
 
-func GetBannerGroupByClient(id int) int {
-	return 1
+//Client is a consumer of banners, usually a website owner
+type Client struct {
+	ID         int    `json:"id"`
+	ClientName string `json:"client_name"`
+	//GroupID is the banner group of the client from its ClientBanner, 0 for none
+	GroupID int `json:"group_id"`
+	Version int `json:"version"`
 }
 
-type Client struct {
-	ID         int
-	ClientName string
+//BannerGroup is a set of banners served together to clients and websites
+type BannerGroup struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
 }
@@ -1,7 +1,23 @@
 package models
 
-//BannerLog is log struct for banner loading
+import "context"
+
+//BannerLog is log struct for banner loading, one row per served banner
 type BannerLog struct {
-	ID   int
+	ID           int
+	ImpressionID string
+	BannerID     int
+	ClientID     int
+	Size         string
+	Language     string
+	VisitorID    string
+	Transport    string
+	//Date is the unix time of the impression in seconds
 	Date int
 }
+
+//BannerLogStore persists impressions
+type BannerLogStore interface {
+	//InsertBannerLogs writes logs in a single batch
+	InsertBannerLogs(ctx context.Context, logs []BannerLog) error
+}
@@ -1,25 +1,36 @@
 package models
 
 import (
+	"context"
 	"strconv"
 	"testing"
 )
 
 func TestGetBannerByID(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	store := NewMySQLStore(db)
 	id := 1
-	banner, err := GetBannerByID(id)
+	banner, err := store.GetBannerByID(context.Background(), id)
+	if err == ErrNotFound {
+		t.Log("Nonexsitence Banner" + strconv.Itoa(id))
+		return
+	}
 	if err != nil {
 		t.Error(err)
 	}
-	if banner.ID == 0 {
-		t.Log("Nonexsitence Banner" + strconv.Itoa(id))
+	if banner.ID != id {
+		t.Errorf("want banner %d, got %d", id, banner.ID)
 	}
 }
 
 func TestGetBanners(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	store := NewMySQLStore(db)
 	size := "40*50"
 	groupId := 1
-	banners, err := GetBanners(size, groupId)
+	banners, err := store.GetBanners(context.Background(), size, groupId)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
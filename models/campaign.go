@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+//Campaign states
+const (
+	//CampaignDraft is being prepared and is never served
+	CampaignDraft = "draft"
+	//CampaignActive is served between Start and End while it has budget
+	CampaignActive = "active"
+	//CampaignPaused was stopped by hand and can be resumed
+	CampaignPaused = "paused"
+	//CampaignFinished is past its End or was ended by hand, it is final
+	CampaignFinished = "finished"
+	//CampaignExhausted ran out of budget, it becomes active again when a new
+	//day restores its daily budget or its budgets are raised
+	CampaignExhausted = "exhausted"
+)
+
+//ErrInvalidTransition is returned for campaign state changes the lifecycle does not allow
+var ErrInvalidTransition = errors.New("models: invalid campaign transition")
+
+//Campaign groups banners under a flight and an impression budget
+type Campaign struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	//Start and End bound the flight in unix seconds, 0 leaves that side open
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	//DailyBudget and TotalBudget cap impressions, 0 is unlimited
+	DailyBudget int `json:"daily_budget"`
+	TotalBudget int `json:"total_budget"`
+	//Served counts every impression, ServedToday only those on Day
+	Served      int `json:"served"`
+	ServedToday int `json:"served_today"`
+	//Day is the unix time of 00:00 UTC of the day ServedToday counts
+	Day int `json:"day"`
+	//Schedule limits the hours the campaign is served, nil for always
+	Schedule *Schedule `json:"schedule,omitempty"`
+	//Version counts changes to the settings above, not to counters or status
+	Version int `json:"version"`
+}
+
+//DayOf returns the unix time of 00:00 UTC of the day t is in
+func DayOf(t time.Time) int {
+	return int(t.Unix() / 86400 * 86400)
+}
+
+//servedOn returns the impressions counted on day
+func (c Campaign) servedOn(day int) int {
+	if c.Day != day {
+		return 0
+	}
+	return c.ServedToday
+}
+
+//outOfBudget reports whether a budget is spent at now
+func (c Campaign) outOfBudget(now time.Time) bool {
+	return (c.TotalBudget > 0 && c.Served >= c.TotalBudget) ||
+		(c.DailyBudget > 0 && c.servedOn(DayOf(now)) >= c.DailyBudget)
+}
+
+//Next returns the state the campaign moves to on its own at now: active and
+//exhausted campaigns follow their budget, and every campaign that is not a
+//draft finishes at End.
+func (c Campaign) Next(now time.Time) string {
+	switch c.Status {
+	case CampaignActive, CampaignPaused, CampaignExhausted:
+		if c.End > 0 && now.Unix() >= c.End {
+			return CampaignFinished
+		}
+	}
+	switch c.Status {
+	case CampaignActive:
+		if c.outOfBudget(now) {
+			return CampaignExhausted
+		}
+	case CampaignExhausted:
+		if !c.outOfBudget(now) {
+			return CampaignActive
+		}
+	}
+	return c.Status
+}
+
+//Servable reports whether banners of the campaign may be served at now
+func (c Campaign) Servable(now time.Time) bool {
+	if c.Next(now) != CampaignActive {
+		return false
+	}
+	return (c.Start == 0 || now.Unix() >= c.Start) && c.Schedule.Active(now)
+}
+
+//CanTransition reports whether a campaign may be moved from one state to
+//another by hand. Exhaustion is left to Next.
+func CanTransition(from, to string) bool {
+	switch to {
+	case CampaignActive:
+		return from == CampaignDraft || from == CampaignPaused
+	case CampaignPaused:
+		return from == CampaignActive || from == CampaignExhausted
+	case CampaignFinished:
+		return from != CampaignFinished
+	}
+	return false
+}
+
+//CampaignStore persists campaigns and their impression counters
+type CampaignStore interface {
+	//GetCampaign returns a single campaign, or ErrNotFound
+	GetCampaign(ctx context.Context, id int) (Campaign, error)
+	//GetCampaigns returns the campaigns with the given ids that exist
+	GetCampaigns(ctx context.Context, ids []int) ([]Campaign, error)
+	//AddCampaignImpressions counts n impressions on day and returns the
+	//updated campaign, or ErrNotFound
+	AddCampaignImpressions(ctx context.Context, id, day, n int) (Campaign, error)
+	//SetCampaignStatus moves the campaign from one state to another. It
+	//reports false without error if the campaign was no longer in from.
+	SetCampaignStatus(ctx context.Context, id int, from, to string) (bool, error)
+}
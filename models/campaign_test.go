@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCampaignNext(t *testing.T) {
+	now := time.Unix(86400*100+3600, 0)
+	today := DayOf(now)
+	cases := []struct {
+		name string
+		c    Campaign
+		want string
+	}{
+		{"draft stays", Campaign{Status: CampaignDraft, End: 1}, CampaignDraft},
+		{"active with budget", Campaign{Status: CampaignActive, TotalBudget: 10, Served: 9}, CampaignActive},
+		{"total spent", Campaign{Status: CampaignActive, TotalBudget: 10, Served: 10}, CampaignExhausted},
+		{"daily spent", Campaign{Status: CampaignActive, DailyBudget: 5, ServedToday: 5, Day: today}, CampaignExhausted},
+		{"new day", Campaign{Status: CampaignExhausted, DailyBudget: 5, ServedToday: 5, Day: today - 86400}, CampaignActive},
+		{"past end", Campaign{Status: CampaignPaused, End: now.Unix()}, CampaignFinished},
+		{"finished is final", Campaign{Status: CampaignFinished}, CampaignFinished},
+	}
+	for _, tc := range cases {
+		if got := tc.c.Next(now); got != tc.want {
+			t.Errorf("%s: want %s, got %s", tc.name, tc.want, got)
+		}
+	}
+	if (Campaign{Status: CampaignActive, Start: now.Unix() + 1}).Servable(now) {
+		t.Error("want a campaign before its start not servable")
+	}
+	if !(Campaign{Status: CampaignActive, Start: now.Unix()}).Servable(now) {
+		t.Error("want an active campaign in flight servable")
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	if !CanTransition(CampaignDraft, CampaignActive) || !CanTransition(CampaignPaused, CampaignActive) {
+		t.Error("want drafts and paused campaigns activatable")
+	}
+	if CanTransition(CampaignExhausted, CampaignActive) || CanTransition(CampaignFinished, CampaignActive) {
+		t.Error("want exhausted and finished campaigns not activatable by hand")
+	}
+	if CanTransition(CampaignActive, CampaignExhausted) {
+		t.Error("want exhaustion left to the budget")
+	}
+}
+
+func TestMemoryStoreCampaignCounters(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.PutCampaign(Campaign{ID: 1, Status: CampaignActive})
+	store.AddCampaignImpressions(ctx, 1, 86400, 2)
+	c, err := store.AddCampaignImpressions(ctx, 1, 2*86400, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Served != 3 || c.ServedToday != 1 || c.Day != 2*86400 {
+		t.Errorf("want 3 served, 1 today, got %+v", c)
+	}
+	if ok, _ := store.SetCampaignStatus(ctx, 1, CampaignPaused, CampaignFinished); ok {
+		t.Error("want the transition refused from a stale state")
+	}
+	if ok, _ := store.SetCampaignStatus(ctx, 1, CampaignActive, CampaignPaused); !ok {
+		t.Error("want the transition applied")
+	}
+	if _, err := store.AddCampaignImpressions(ctx, 2, 0, 1); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
+
+//testUpdateCampaign checks that UpdateCampaign returns the stored counters
+//and status rather than those of the stale copy it was given
+func testUpdateCampaign(t *testing.T, store interface {
+	AdminStore
+	CampaignStore
+}) {
+	ctx := context.Background()
+	c := Campaign{Name: "spring", Status: CampaignDraft, DailyBudget: 10}
+	if err := store.InsertCampaign(ctx, &c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddCampaignImpressions(ctx, c.ID, 86400, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SetCampaignStatus(ctx, c.ID, CampaignDraft, CampaignActive); err != nil {
+		t.Fatal(err)
+	}
+	c.DailyBudget = 20
+	if err := store.UpdateCampaign(ctx, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 || c.DailyBudget != 20 || c.Status != CampaignActive || c.Served != 3 || c.ServedToday != 3 {
+		t.Errorf("want the stored campaign at version 2, got %+v", c)
+	}
+}
+
+func TestUpdateCampaignReturnsStoredRow(t *testing.T) {
+	testUpdateCampaign(t, NewMemoryStore())
+}
+
+func TestMySQLUpdateCampaignReturnsStoredRow(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	if _, err := NewMigrator(db).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	testUpdateCampaign(t, NewMySQLStore(db))
+}
@@ -0,0 +1,19 @@
+package models
+
+import "context"
+
+//ClickLog is one click on a served banner
+type ClickLog struct {
+	ID           int
+	ImpressionID string
+	BannerID     int
+	ClientID     int
+	VisitorID    string
+	//Date is the unix time of the click in seconds
+	Date int
+}
+
+//ClickLogStore persists clicks
+type ClickLogStore interface {
+	InsertClickLog(ctx context.Context, click ClickLog) error
+}
@@ -2,16 +2,42 @@ package models
 
 import (
 	"database/sql"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql" //mysql driver for db connect
 )
 
-var db *sql.DB
+//Default pool limits used by OpenMySQL when PoolConfig leaves them zero
+const (
+	DefaultMaxOpenConns = 10
+	DefaultMaxIdleConns = 3
+)
+
+//PoolConfig sizes the MySQL connection pool
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
 
-func init() {
-	dbSourceName := "root:iao123456@tcp(10.0.75.1:3306)/adv?charset=utf8"
-	db, _ = sql.Open("mysql", dbSourceName)
-	db.SetMaxOpenConns(10)
-	db.SetMaxOpenConns(3)
-	//db.Ping() //trigger db connect
+//OpenMySQL opens a connection pool for dsn and verifies it with a ping
+func OpenMySQL(dsn string, pool PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if pool.MaxOpenConns == 0 {
+		pool.MaxOpenConns = DefaultMaxOpenConns
+	}
+	if pool.MaxIdleConns == 0 {
+		pool.MaxIdleConns = DefaultMaxIdleConns
+	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
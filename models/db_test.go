@@ -1,10 +1,27 @@
 package models
 
 import (
+	"database/sql"
+	"os"
 	"testing"
 )
 
+//testDB opens the database named by ADV_TEST_DSN and skips the test when it is unset
+func testDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("ADV_TEST_DSN")
+	if dsn == "" {
+		t.Skip("ADV_TEST_DSN not set, skipping MySQL test")
+	}
+	db, err := OpenMySQL(dsn, PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
 func TestPing(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
 	if err := db.Ping(); err != nil {
 		t.Error(err)
 	}
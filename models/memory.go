@@ -0,0 +1,548 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+//MemoryStore is an in-memory BannerStore. It is safe for concurrent use
+//and is meant for tests and for running the service without a database.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	banners      map[int]Banner
+	clientGroups map[int]int
+	bannerLogs   []BannerLog
+	clickLogs    []ClickLog
+	viewability  map[[2]int]BannerViewability
+	websites     map[string]Website
+	profiles     map[string]VisitorProfile
+	campaigns    map[int]Campaign
+	groups       map[int]BannerGroup
+	clients      map[int]Client
+	audit        []AuditEntry
+	lastID       int
+}
+
+//NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		banners:      make(map[int]Banner),
+		clientGroups: make(map[int]int),
+		viewability:  make(map[[2]int]BannerViewability),
+		websites:     make(map[string]Website),
+		profiles:     make(map[string]VisitorProfile),
+		campaigns:    make(map[int]Campaign),
+		groups:       make(map[int]BannerGroup),
+		clients:      make(map[int]Client),
+	}
+}
+
+//PutBanner inserts or replaces the banner with b.ID
+func (s *MemoryStore) PutBanner(b Banner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.banners[b.ID] = b
+}
+
+//DeleteBanner removes the banner with id, if any
+func (s *MemoryStore) DeleteBanner(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.banners, id)
+}
+
+//SetClientGroup assigns groupID to clientID
+func (s *MemoryStore) SetClientGroup(clientID, groupID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientGroups[clientID] = groupID
+}
+
+//PutWebsite inserts or replaces the website with w's domain
+func (s *MemoryStore) PutWebsite(w Website) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Domain = NormalizeDomain(w.Domain)
+	s.websites[w.Domain] = w
+}
+
+//PutCampaign inserts or replaces the campaign with c.ID
+func (s *MemoryStore) PutCampaign(c Campaign) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.campaigns[c.ID] = c
+}
+
+//GetBannerByID implements BannerStore
+func (s *MemoryStore) GetBannerByID(ctx context.Context, id int) (Banner, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.banners[id]
+	if !ok {
+		return Banner{}, ErrNotFound
+	}
+	return b, nil
+}
+
+//GetBanners implements BannerStore. Banners are returned ordered by ID,
+//matching the MySQL implementation.
+func (s *MemoryStore) GetBanners(ctx context.Context, size string, groupID int) ([]*Banner, error) {
+	return s.filterBanners(func(b Banner) bool { return b.Size == size && b.GroupID == groupID }), nil
+}
+
+//GetBannersByGroup implements BannerStore
+func (s *MemoryStore) GetBannersByGroup(ctx context.Context, groupID int) ([]*Banner, error) {
+	return s.filterBanners(func(b Banner) bool { return b.GroupID == groupID }), nil
+}
+
+//filterBanners returns the active banners matching keep ordered by ID
+func (s *MemoryStore) filterBanners(keep func(Banner) bool) []*Banner {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var banners []*Banner
+	for _, b := range s.banners {
+		if b.Active() && keep(b) {
+			b := b
+			banners = append(banners, &b)
+		}
+	}
+	sort.Slice(banners, func(i, j int) bool { return banners[i].ID < banners[j].ID })
+	return banners
+}
+
+//GetBannerGroupByClient implements BannerStore
+func (s *MemoryStore) GetBannerGroupByClient(ctx context.Context, clientID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groupID, ok := s.clientGroups[clientID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return groupID, nil
+}
+
+//InsertBannerLogs implements BannerLogStore, assigning IDs in insertion order
+func (s *MemoryStore) InsertBannerLogs(ctx context.Context, logs []BannerLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range logs {
+		l.ID = len(s.bannerLogs) + 1
+		s.bannerLogs = append(s.bannerLogs, l)
+	}
+	return nil
+}
+
+//BannerLogs returns a copy of every inserted BannerLog
+func (s *MemoryStore) BannerLogs() []BannerLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	logs := make([]BannerLog, len(s.bannerLogs))
+	copy(logs, s.bannerLogs)
+	return logs
+}
+
+//InsertClickLog implements ClickLogStore
+func (s *MemoryStore) InsertClickLog(ctx context.Context, click ClickLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	click.ID = len(s.clickLogs) + 1
+	s.clickLogs = append(s.clickLogs, click)
+	return nil
+}
+
+//ClickLogs returns a copy of every inserted ClickLog
+func (s *MemoryStore) ClickLogs() []ClickLog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clicks := make([]ClickLog, len(s.clickLogs))
+	copy(clicks, s.clickLogs)
+	return clicks
+}
+
+//AddViewability implements ViewabilityStore
+func (s *MemoryStore) AddViewability(ctx context.Context, records []BannerViewability) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range records {
+		key := [2]int{r.BannerID, r.Day}
+		total := s.viewability[key]
+		total.BannerID, total.Day = r.BannerID, r.Day
+		total.Sessions += r.Sessions
+		total.ViewableSessions += r.ViewableSessions
+		total.ViewableMillis += r.ViewableMillis
+		s.viewability[key] = total
+	}
+	return nil
+}
+
+//GetViewability implements ViewabilityStore
+func (s *MemoryStore) GetViewability(ctx context.Context, bannerID int) ([]BannerViewability, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []BannerViewability
+	for key, r := range s.viewability {
+		if key[0] == bannerID {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Day < records[j].Day })
+	return records, nil
+}
+
+//GetWebsiteByDomain implements WebsiteStore
+func (s *MemoryStore) GetWebsiteByDomain(ctx context.Context, domain string) (Website, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.websites[NormalizeDomain(domain)]
+	if !ok {
+		return Website{}, ErrNotFound
+	}
+	return w, nil
+}
+
+//GetVisitorProfile implements ProfileStore
+func (s *MemoryStore) GetVisitorProfile(ctx context.Context, visitorID string) (VisitorProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.profiles[visitorID]
+	if !ok {
+		return VisitorProfile{VisitorID: visitorID}, ErrNotFound
+	}
+	return p.clone(), nil
+}
+
+//SaveVisitorProfile implements ProfileStore
+func (s *MemoryStore) SaveVisitorProfile(ctx context.Context, p VisitorProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[p.VisitorID] = p.clone()
+	return nil
+}
+
+//GetCampaign implements CampaignStore
+func (s *MemoryStore) GetCampaign(ctx context.Context, id int) (Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.campaigns[id]
+	if !ok {
+		return Campaign{}, ErrNotFound
+	}
+	return c, nil
+}
+
+//GetCampaigns implements CampaignStore
+func (s *MemoryStore) GetCampaigns(ctx context.Context, ids []int) ([]Campaign, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var campaigns []Campaign
+	for _, id := range ids {
+		if c, ok := s.campaigns[id]; ok {
+			campaigns = append(campaigns, c)
+		}
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].ID < campaigns[j].ID })
+	return campaigns, nil
+}
+
+//AddCampaignImpressions implements CampaignStore
+func (s *MemoryStore) AddCampaignImpressions(ctx context.Context, id, day, n int) (Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.campaigns[id]
+	if !ok {
+		return Campaign{}, ErrNotFound
+	}
+	c.ServedToday = c.servedOn(day) + n
+	c.Served += n
+	c.Day = day
+	s.campaigns[id] = c
+	return c, nil
+}
+
+//SetCampaignStatus implements CampaignStore
+func (s *MemoryStore) SetCampaignStatus(ctx context.Context, id int, from, to string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.campaigns[id]
+	if !ok || c.Status != from {
+		return false, nil
+	}
+	c.Status = to
+	s.campaigns[id] = c
+	return true, nil
+}
+
+//nextID returns an ID above every ID in use, s.mu must be held
+func (s *MemoryStore) nextID(used int) int {
+	if used > s.lastID {
+		s.lastID = used
+	}
+	s.lastID++
+	return s.lastID
+}
+
+//InsertBanner implements AdminStore
+func (s *MemoryStore) InsertBanner(ctx context.Context, b *Banner) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	max := 0
+	for id := range s.banners {
+		if id > max {
+			max = id
+		}
+	}
+	b.ID, b.Version = s.nextID(max), 1
+	s.banners[b.ID] = *b
+	return nil
+}
+
+//UpdateBanner implements AdminStore
+func (s *MemoryStore) UpdateBanner(ctx context.Context, b *Banner) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.banners[b.ID]
+	if err := checkVersion(ok, old.Version, b.Version); err != nil {
+		return err
+	}
+	b.Version++
+	s.banners[b.ID] = *b
+	return nil
+}
+
+//RemoveBanner implements AdminStore
+func (s *MemoryStore) RemoveBanner(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.banners[id]
+	if err := checkVersion(ok, old.Version, version); err != nil {
+		return err
+	}
+	delete(s.banners, id)
+	return nil
+}
+
+//GetBannerGroup implements AdminStore
+func (s *MemoryStore) GetBannerGroup(ctx context.Context, id int) (BannerGroup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.groups[id]
+	if !ok {
+		return g, ErrNotFound
+	}
+	return g, nil
+}
+
+//InsertBannerGroup implements AdminStore
+func (s *MemoryStore) InsertBannerGroup(ctx context.Context, g *BannerGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	max := 0
+	for id := range s.groups {
+		if id > max {
+			max = id
+		}
+	}
+	g.ID, g.Version = s.nextID(max), 1
+	s.groups[g.ID] = *g
+	return nil
+}
+
+//UpdateBannerGroup implements AdminStore
+func (s *MemoryStore) UpdateBannerGroup(ctx context.Context, g *BannerGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.groups[g.ID]
+	if err := checkVersion(ok, old.Version, g.Version); err != nil {
+		return err
+	}
+	g.Version++
+	s.groups[g.ID] = *g
+	return nil
+}
+
+//RemoveBannerGroup implements AdminStore
+func (s *MemoryStore) RemoveBannerGroup(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.groups[id]
+	if err := checkVersion(ok, old.Version, version); err != nil {
+		return err
+	}
+	delete(s.groups, id)
+	return nil
+}
+
+//CountGroupReferences implements AdminStore
+func (s *MemoryStore) CountGroupReferences(ctx context.Context, groupID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, b := range s.banners {
+		if b.GroupID == groupID {
+			n++
+		}
+	}
+	for _, g := range s.clientGroups {
+		if g == groupID {
+			n++
+		}
+	}
+	for _, w := range s.websites {
+		if w.GroupID == groupID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+//GetClient implements AdminStore
+func (s *MemoryStore) GetClient(ctx context.Context, id int) (Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.clients[id]
+	if !ok {
+		return c, ErrNotFound
+	}
+	c.GroupID = s.clientGroups[id]
+	return c, nil
+}
+
+//InsertClient implements AdminStore
+func (s *MemoryStore) InsertClient(ctx context.Context, c *Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	max := 0
+	for id := range s.clients {
+		if id > max {
+			max = id
+		}
+	}
+	c.ID, c.Version = s.nextID(max), 1
+	s.putClient(*c)
+	return nil
+}
+
+//UpdateClient implements AdminStore
+func (s *MemoryStore) UpdateClient(ctx context.Context, c *Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.clients[c.ID]
+	if err := checkVersion(ok, old.Version, c.Version); err != nil {
+		return err
+	}
+	c.Version++
+	s.putClient(*c)
+	return nil
+}
+
+//RemoveClient implements AdminStore
+func (s *MemoryStore) RemoveClient(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.clients[id]
+	if err := checkVersion(ok, old.Version, version); err != nil {
+		return err
+	}
+	delete(s.clients, id)
+	delete(s.clientGroups, id)
+	return nil
+}
+
+//putClient stores c and its ClientBanner, s.mu must be held
+func (s *MemoryStore) putClient(c Client) {
+	s.clients[c.ID] = c
+	if c.GroupID == 0 {
+		delete(s.clientGroups, c.ID)
+	} else {
+		s.clientGroups[c.ID] = c.GroupID
+	}
+}
+
+//checkVersion implements the optimistic concurrency of AdminStore
+func checkVersion(exists bool, current, want int) error {
+	if !exists {
+		return ErrNotFound
+	}
+	if current != want {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+//InsertCampaign implements AdminStore
+func (s *MemoryStore) InsertCampaign(ctx context.Context, c *Campaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	max := 0
+	for id := range s.campaigns {
+		if id > max {
+			max = id
+		}
+	}
+	c.ID, c.Version = s.nextID(max), 1
+	c.Served, c.ServedToday, c.Day = 0, 0, 0
+	s.campaigns[c.ID] = *c
+	return nil
+}
+
+//UpdateCampaign implements AdminStore
+func (s *MemoryStore) UpdateCampaign(ctx context.Context, c *Campaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.campaigns[c.ID]
+	if err := checkVersion(ok, old.Version, c.Version); err != nil {
+		return err
+	}
+	old.Name, old.Start, old.End = c.Name, c.Start, c.End
+	old.DailyBudget, old.TotalBudget, old.Schedule = c.DailyBudget, c.TotalBudget, c.Schedule
+	old.Version++
+	s.campaigns[c.ID] = old
+	*c = old
+	return nil
+}
+
+//InsertAuditEntry implements AuditStore, assigning IDs in insertion order
+func (s *MemoryStore) InsertAuditEntry(ctx context.Context, e AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = len(s.audit) + 1
+	s.audit = append(s.audit, e)
+	return nil
+}
+
+//Audited implements AuditStore. MemoryStore cannot roll back, fn runs on s
+//itself and its entry is appended if it succeeds.
+func (s *MemoryStore) Audited(ctx context.Context, fn func(Store) (AuditEntry, error)) error {
+	e, err := fn(s)
+	if err != nil {
+		return err
+	}
+	return s.InsertAuditEntry(ctx, e)
+}
+
+//QueryAuditEntries implements AuditStore
+func (s *MemoryStore) QueryAuditEntries(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []AuditEntry
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		if q.matches(s.audit[i]) {
+			entries = append(entries, s.audit[i])
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date > entries[j].Date })
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
+
+//AuditEntries returns a copy of every inserted AuditEntry
+func (s *MemoryStore) AuditEntries() []AuditEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make([]AuditEntry, len(s.audit))
+	copy(entries, s.audit)
+	return entries
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryStoreGetBanners(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.PutBanner(Banner{ID: 3, GroupID: 1, Size: "40*50", Status: StatusActive})
+	store.PutBanner(Banner{ID: 1, GroupID: 1, Size: "40*50", Status: StatusActive})
+	store.PutBanner(Banner{ID: 2, GroupID: 1, Size: "40*50"})
+	store.PutBanner(Banner{ID: 4, GroupID: 2, Size: "40*50", Status: StatusActive})
+	store.PutBanner(Banner{ID: 5, GroupID: 1, Size: "300*250", Status: StatusActive})
+
+	banners, err := store.GetBanners(ctx, "40*50", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banners) != 2 || banners[0].ID != 1 || banners[1].ID != 3 {
+		t.Errorf("want banners [1 3], got %v", banners)
+	}
+}
+
+func TestMemoryStoreNotFound(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	if _, err := store.GetBannerByID(ctx, 1); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := store.GetBannerGroupByClient(ctx, 1); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	store.PutBanner(Banner{ID: 1})
+	store.SetClientGroup(1, 7)
+	if _, err := store.GetBannerByID(ctx, 1); err != nil {
+		t.Error(err)
+	}
+	if g, err := store.GetBannerGroupByClient(ctx, 1); err != nil || g != 7 {
+		t.Errorf("want group 7, got %d (%v)", g, err)
+	}
+	store.DeleteBanner(1)
+	if _, err := store.GetBannerByID(ctx, 1); err != ErrNotFound {
+		t.Errorf("want ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	tags := SplitTags(" Sports, ,cars,")
+	if len(tags) != 2 || tags[0] != "sports" || tags[1] != "cars" {
+		t.Errorf("want [sports cars], got %v", tags)
+	}
+	if JoinTags(tags) != "sports,cars" {
+		t.Errorf("want sports,cars, got %q", JoinTags(tags))
+	}
+	if SplitTags("") != nil {
+		t.Error("want no tags for an empty column")
+	}
+}
+
+func TestMemoryStoreVersions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.PutBanner(Banner{ID: 5})
+	b := Banner{GroupID: 1, Name: "new"}
+	if err := store.InsertBanner(ctx, &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.ID != 6 || b.Version != 1 {
+		t.Fatalf("want id 6 version 1, got %d %d", b.ID, b.Version)
+	}
+	stale := b
+	b.Name = "renamed"
+	if err := store.UpdateBanner(ctx, &b); err != nil || b.Version != 2 {
+		t.Fatalf("want version 2, got %d (%v)", b.Version, err)
+	}
+	if err := store.UpdateBanner(ctx, &stale); err != ErrVersionConflict {
+		t.Errorf("want ErrVersionConflict, got %v", err)
+	}
+	if err := store.RemoveBanner(ctx, b.ID, 1); err != ErrVersionConflict {
+		t.Errorf("want ErrVersionConflict, got %v", err)
+	}
+	if err := store.RemoveBanner(ctx, b.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RemoveBanner(ctx, b.ID, 2); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreClients(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	c := Client{ClientName: "acme", GroupID: 3}
+	if err := store.InsertClient(ctx, &c); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := store.GetBannerGroupByClient(ctx, c.ID); err != nil || g != 3 {
+		t.Errorf("want group 3, got %d (%v)", g, err)
+	}
+	c.GroupID = 0
+	if err := store.UpdateClient(ctx, &c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetBannerGroupByClient(ctx, c.ID); err != ErrNotFound {
+		t.Errorf("want mapping removed, got %v", err)
+	}
+	got, err := store.GetClient(ctx, c.ID)
+	if err != nil || got != c {
+		t.Errorf("want %+v, got %+v (%v)", c, got, err)
+	}
+}
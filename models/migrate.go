@@ -0,0 +1,425 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//migrationsTable records the applied migrations, it doubles as the lock name
+const migrationsTable = "gw_adv_schema_migrations"
+
+//ErrMigrationLocked is returned when another process is migrating the database
+var ErrMigrationLocked = errors.New("models: database is being migrated by another process")
+
+//ErrIrreversible is returned when reverting a migration that has no Down
+var ErrIrreversible = errors.New("models: migration cannot be reverted")
+
+//Migration is a versioned change to the adv schema. Up and Down hold one
+//statement each, a migration without Down cannot be reverted. MySQL commits
+//DDL implicitly, so a migration that fails half way is not rolled back and
+//stays pending.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+//Migrations are the changes to the adv schema in version order.
+//Migration 1 is the schema the service ran on before it had migrations:
+//databases that already have gw_adv_banner adopt it as is, new ones get it
+//created. Later migrations only alter it. It cannot be reverted, as the
+//table may hold data from before the migrations.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS gw_adv_banner (
+				id INT NOT NULL AUTO_INCREMENT,
+				group_id INT NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				language VARCHAR(35) NOT NULL DEFAULT '',
+				size VARCHAR(20) NOT NULL,
+				url VARCHAR(2048) NOT NULL,
+				status TINYINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "banners_and_clients",
+		Up: []string{
+			`ALTER TABLE gw_adv_banner
+				ADD COLUMN campaign_id INT NOT NULL DEFAULT 0 AFTER group_id,
+				ADD COLUMN weight INT NOT NULL DEFAULT 1,
+				ADD COLUMN priority INT NOT NULL DEFAULT 0,
+				ADD COLUMN tags VARCHAR(1024) NOT NULL DEFAULT '',
+				ADD COLUMN landing_tags VARCHAR(1024) NOT NULL DEFAULT '',
+				ADD COLUMN daily_target INT NOT NULL DEFAULT 0,
+				ADD COLUMN schedule TEXT NULL,
+				ADD COLUMN version INT NOT NULL DEFAULT 1,
+				ADD KEY idx_group_status_size (group_id, status, size)`,
+			`CREATE TABLE gw_adv_banner_group (
+				id INT NOT NULL AUTO_INCREMENT,
+				name VARCHAR(255) NOT NULL,
+				version INT NOT NULL DEFAULT 1,
+				PRIMARY KEY (id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE gw_adv_client (
+				id INT NOT NULL AUTO_INCREMENT,
+				client_name VARCHAR(255) NOT NULL,
+				version INT NOT NULL DEFAULT 1,
+				PRIMARY KEY (id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE gw_adv_client_banner (
+				client_id INT NOT NULL,
+				group_id INT NOT NULL,
+				PRIMARY KEY (client_id),
+				KEY idx_group (group_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE gw_adv_website (
+				id INT NOT NULL AUTO_INCREMENT,
+				domain VARCHAR(255) NOT NULL,
+				client_id INT NOT NULL,
+				group_id INT NOT NULL DEFAULT 0,
+				PRIMARY KEY (id),
+				UNIQUE KEY uniq_domain (domain)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+		Down: []string{
+			"DROP TABLE gw_adv_website",
+			"DROP TABLE gw_adv_client_banner",
+			"DROP TABLE gw_adv_client",
+			"DROP TABLE gw_adv_banner_group",
+			`ALTER TABLE gw_adv_banner
+				DROP KEY idx_group_status_size,
+				DROP COLUMN version,
+				DROP COLUMN schedule,
+				DROP COLUMN daily_target,
+				DROP COLUMN landing_tags,
+				DROP COLUMN tags,
+				DROP COLUMN priority,
+				DROP COLUMN weight,
+				DROP COLUMN campaign_id`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "impression_logs",
+		Up: []string{
+			`CREATE TABLE gw_adv_banner_log (
+				id BIGINT NOT NULL AUTO_INCREMENT,
+				impression_id VARCHAR(64) NOT NULL,
+				banner_id INT NOT NULL,
+				client_id INT NOT NULL,
+				size VARCHAR(20) NOT NULL,
+				language VARCHAR(35) NOT NULL DEFAULT '',
+				visitor_id VARCHAR(64) NOT NULL DEFAULT '',
+				transport VARCHAR(16) NOT NULL DEFAULT '',
+				date INT NOT NULL,
+				PRIMARY KEY (id),
+				KEY idx_banner_date (banner_id, date),
+				KEY idx_impression (impression_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE gw_adv_click_log (
+				id BIGINT NOT NULL AUTO_INCREMENT,
+				impression_id VARCHAR(64) NOT NULL,
+				banner_id INT NOT NULL,
+				client_id INT NOT NULL,
+				visitor_id VARCHAR(64) NOT NULL DEFAULT '',
+				date INT NOT NULL,
+				PRIMARY KEY (id),
+				KEY idx_banner_date (banner_id, date),
+				KEY idx_impression (impression_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE gw_adv_banner_viewability (
+				banner_id INT NOT NULL,
+				day INT NOT NULL,
+				sessions INT NOT NULL DEFAULT 0,
+				viewable_sessions INT NOT NULL DEFAULT 0,
+				viewable_millis BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (banner_id, day)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+		Down: []string{
+			"DROP TABLE gw_adv_banner_viewability",
+			"DROP TABLE gw_adv_click_log",
+			"DROP TABLE gw_adv_banner_log",
+		},
+	},
+	{
+		Version: 4,
+		Name:    "visitor_profiles",
+		Up: []string{
+			`CREATE TABLE gw_adv_visitor_profile (
+				visitor_id VARCHAR(64) NOT NULL,
+				scores TEXT NOT NULL,
+				updated BIGINT NOT NULL,
+				PRIMARY KEY (visitor_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+		Down: []string{
+			"DROP TABLE gw_adv_visitor_profile",
+		},
+	},
+	{
+		Version: 5,
+		Name:    "campaigns",
+		Up: []string{
+			`CREATE TABLE gw_adv_campaign (
+				id INT NOT NULL AUTO_INCREMENT,
+				name VARCHAR(255) NOT NULL,
+				status VARCHAR(16) NOT NULL DEFAULT 'draft',
+				start BIGINT NOT NULL DEFAULT 0,
+				end BIGINT NOT NULL DEFAULT 0,
+				daily_budget INT NOT NULL DEFAULT 0,
+				total_budget INT NOT NULL DEFAULT 0,
+				served INT NOT NULL DEFAULT 0,
+				served_today INT NOT NULL DEFAULT 0,
+				day INT NOT NULL DEFAULT 0,
+				schedule TEXT NULL,
+				version INT NOT NULL DEFAULT 1,
+				PRIMARY KEY (id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+		Down: []string{
+			"DROP TABLE gw_adv_campaign",
+		},
+	},
+	{
+		Version: 6,
+		Name:    "audit_log",
+		Up: []string{
+			`CREATE TABLE gw_adv_audit_log (
+				id BIGINT NOT NULL AUTO_INCREMENT,
+				actor VARCHAR(64) NOT NULL,
+				entity VARCHAR(16) NOT NULL,
+				entity_id INT NOT NULL,
+				action VARCHAR(16) NOT NULL,
+				version INT NOT NULL,
+				changes MEDIUMTEXT NULL,
+				date INT NOT NULL,
+				PRIMARY KEY (id),
+				KEY idx_entity_date (entity, entity_id, date),
+				KEY idx_date (date)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+		Down: []string{
+			"DROP TABLE gw_adv_audit_log",
+		},
+	},
+}
+
+//MigrationStatus is a migration and when it was applied
+type MigrationStatus struct {
+	Migration
+	//Applied is the unix time the migration was applied, 0 while it is pending
+	Applied int64
+}
+
+//MigrationStep is a migration run by Migrator, Revert if it was reverted
+type MigrationStep struct {
+	Migration
+	Revert bool
+}
+
+//Migrator moves the adv database between schema versions
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	now        func() time.Time
+}
+
+//NewMigrator returns a Migrator applying Migrations to db
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db, migrations: Migrations, now: time.Now}
+}
+
+//Latest returns the newest version known to the binary
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+//Status lists every known migration with the time it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.createTable(ctx, m.db); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		status[i] = MigrationStatus{Migration: mig, Applied: applied[mig.Version]}
+	}
+	return status, nil
+}
+
+//Version returns the newest applied version, 0 for an empty database
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.createTable(ctx, m.db); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+//Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) ([]MigrationStep, error) {
+	return m.To(ctx, m.Latest())
+}
+
+//Down reverts the newest applied migration
+func (m *Migrator) Down(ctx context.Context) ([]MigrationStep, error) {
+	version, err := m.Version(ctx)
+	if err != nil || version == 0 {
+		return nil, err
+	}
+	previous := 0
+	for _, mig := range m.migrations {
+		if mig.Version < version {
+			previous = mig.Version
+		}
+	}
+	return m.To(ctx, previous)
+}
+
+//To applies and reverts migrations until exactly those up to version are
+//applied and returns the steps run. Migrations are run on one connection
+//holding a named lock, so concurrent runs fail with ErrMigrationLocked.
+func (m *Migrator) To(ctx context.Context, version int) ([]MigrationStep, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", migrationsTable).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		return nil, ErrMigrationLocked
+	}
+	defer conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", migrationsTable)
+
+	if err := m.createTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := m.plan(applied, version)
+	if err != nil {
+		return nil, err
+	}
+	var done []MigrationStep
+	for _, step := range plan {
+		statements := step.Up
+		if step.Revert {
+			statements = step.Down
+		}
+		for i, stmt := range statements {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return done, fmt.Errorf("migration %d_%s: statement %d: %v", step.Version, step.Name, i+1, err)
+			}
+		}
+		if step.Revert {
+			_, err = conn.ExecContext(ctx, "DELETE FROM "+migrationsTable+" WHERE version=?", step.Version)
+		} else {
+			_, err = conn.ExecContext(ctx, "INSERT INTO "+migrationsTable+" (version, name, applied) VALUES (?, ?, ?)", step.Version, step.Name, m.now().Unix())
+		}
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %v", step.Version, step.Name, err)
+		}
+		done = append(done, step)
+	}
+	return done, nil
+}
+
+//plan returns the steps taking the database from applied to version: newer
+//migrations are reverted newest first, missing older ones applied oldest first.
+//It returns ErrIrreversible if one of the reverted migrations has no Down.
+func (m *Migrator) plan(applied map[int]int64, version int) ([]MigrationStep, error) {
+	known := map[int]bool{0: true}
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+	}
+	if !known[version] {
+		return nil, fmt.Errorf("models: unknown migration version %d", version)
+	}
+	for v := range applied {
+		if !known[v] {
+			return nil, fmt.Errorf("models: database has migration %d unknown to this binary", v)
+		}
+	}
+	var steps []MigrationStep
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if mig := m.migrations[i]; mig.Version > version && applied[mig.Version] != 0 {
+			if len(mig.Down) == 0 {
+				return nil, ErrIrreversible
+			}
+			steps = append(steps, MigrationStep{Migration: mig, Revert: true})
+		}
+	}
+	for _, mig := range m.migrations {
+		if mig.Version <= version && applied[mig.Version] == 0 {
+			steps = append(steps, MigrationStep{Migration: mig})
+		}
+	}
+	return steps, nil
+}
+
+//execer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (m *Migrator) createTable(ctx context.Context, db execer) error {
+	_, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+migrationsTable+` (
+		version INT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		applied BIGINT NOT NULL,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+//applied maps the applied versions to the time they were applied
+func (m *Migrator) applied(ctx context.Context, db execer) (map[int]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, applied FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]int64{}
+	for rows.Next() {
+		var version int
+		var at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
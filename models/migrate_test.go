@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrationsOrdered(t *testing.T) {
+	names := map[string]bool{}
+	for i, m := range Migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %s: want version %d, got %d", m.Name, i+1, m.Version)
+		}
+		if names[m.Name] {
+			t.Errorf("migration %d: duplicate name %s", m.Version, m.Name)
+		}
+		names[m.Name] = true
+		if len(m.Up) == 0 || (len(m.Down) == 0) != (m.Version == 1) {
+			t.Errorf("migration %d: want up statements and down statements after the baseline", m.Version)
+		}
+	}
+}
+
+func TestMigratorPlan(t *testing.T) {
+	m := &Migrator{migrations: Migrations[:3]}
+	for _, c := range []struct {
+		applied []int
+		version int
+		want    []int
+	}{
+		{nil, 3, []int{1, 2, 3}},
+		{[]int{1, 2, 3}, 3, nil},
+		{[]int{1, 2, 3}, 1, []int{-3, -2}},
+		{[]int{1, 3}, 3, []int{2}},
+		{[]int{1, 3}, 1, []int{-3}},
+	} {
+		applied := map[int]int64{}
+		for _, v := range c.applied {
+			applied[v] = 1000
+		}
+		steps, err := m.plan(applied, c.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, s := range steps {
+			if s.Revert {
+				got = append(got, -s.Version)
+			} else {
+				got = append(got, s.Version)
+			}
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%v to %d: want %v, got %v", c.applied, c.version, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%v to %d: want %v, got %v", c.applied, c.version, c.want, got)
+				break
+			}
+		}
+	}
+	if _, err := m.plan(nil, 4); err == nil {
+		t.Error("want error for unknown target version")
+	}
+	if _, err := m.plan(map[int]int64{4: 1000}, 3); err == nil {
+		t.Error("want error for unknown applied version")
+	}
+	if _, err := m.plan(map[int]int64{1: 1000, 2: 1000}, 0); err != ErrIrreversible {
+		t.Errorf("want ErrIrreversible reverting the baseline, got %v", err)
+	}
+	if _, err := m.plan(map[int]int64{1: 1000}, 0); err != ErrIrreversible {
+		t.Errorf("want ErrIrreversible reverting the baseline, got %v", err)
+	}
+}
+
+func TestMigrateMySQL(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	m := NewMigrator(db)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Version(ctx); err != nil || v != m.Latest() {
+		t.Fatalf("want version %d, got %d (%v)", m.Latest(), v, err)
+	}
+	steps, err := m.Down(ctx)
+	if err != nil || len(steps) != 1 || !steps[0].Revert || steps[0].Version != m.Latest() {
+		t.Fatalf("down: %+v (%v)", steps, err)
+	}
+	if steps, err = m.Up(ctx); err != nil || len(steps) != 1 {
+		t.Fatalf("up: %+v (%v)", steps, err)
+	}
+
+	store := NewMySQLStore(db)
+	seeded, err := Seed(ctx, store, Fixture{
+		Groups:  []BannerGroup{{ID: 1, Name: "home"}},
+		Clients: []Client{{ID: 1, ClientName: "acme", GroupID: 1}},
+		Banners: []Banner{{ID: 1, GroupID: 1, Name: "a", Size: "40*50", URL: "http://a.example", Status: StatusActive}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := store.GetBannerByID(ctx, seeded.Banners[0].ID)
+	if err != nil || b.GroupID != seeded.Groups[0].ID || b.Version != 1 {
+		t.Errorf("unexpected banner %+v (%v)", b, err)
+	}
+}
+
+func TestMigrateLegacyMySQL(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	m := NewMigrator(db)
+	if _, err := m.To(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	//The banner table as the service used it before migrations
+	for _, stmt := range []string{
+		"DELETE FROM " + migrationsTable,
+		"DROP TABLE IF EXISTS gw_adv_banner",
+		`CREATE TABLE gw_adv_banner (
+			id INT NOT NULL AUTO_INCREMENT,
+			group_id INT NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			language VARCHAR(35) NOT NULL,
+			size VARCHAR(20) NOT NULL,
+			url VARCHAR(255) NOT NULL,
+			status TINYINT NOT NULL,
+			PRIMARY KEY (id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`,
+		"INSERT INTO gw_adv_banner (id, group_id, name, language, size, url, status) VALUES (7, 1, 'legacy', 'zh-TW', '40*50', 'http://a.example', 1)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewMySQLStore(db).GetBannerByID(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Name != "legacy" || b.Language != "zh-TW" || b.Weight != 1 || b.Version != 1 {
+		t.Errorf("want the legacy banner with default weight and version, got %+v", b)
+	}
+	if _, err := m.To(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMySQLStore(db).GetBannerByID(ctx, 7); err == nil {
+		t.Error("want the new columns gone at the baseline")
+	}
+	if _, err := m.Down(ctx); err != ErrIrreversible {
+		t.Errorf("want ErrIrreversible, got %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,582 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+//bannerColumns is the column list scanned by scanBanner
+const bannerColumns = "id, group_id, campaign_id, name, language, size, url, status, weight, priority, tags, landing_tags, daily_target, schedule, version"
+
+//MySQLStore is a BannerStore backed by the adv MySQL database
+type MySQLStore struct {
+	db *sql.DB
+	//tx is the transaction of the store Audited hands to its callback
+	tx *sql.Tx
+}
+
+//NewMySQLStore returns a MySQLStore using db
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+//scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBanner(row scanner, b *Banner) error {
+	var tags, landingTags string
+	var schedule sql.NullString
+	if err := row.Scan(&b.ID, &b.GroupID, &b.CampaignID, &b.Name, &b.Language, &b.Size, &b.URL, &b.Status, &b.Weight, &b.Priority, &tags, &landingTags, &b.DailyTarget, &schedule, &b.Version); err != nil {
+		return err
+	}
+	b.Tags = SplitTags(tags)
+	b.LandingTags = SplitTags(landingTags)
+	return unmarshalSchedule(schedule, &b.Schedule)
+}
+
+//unmarshalSchedule decodes a JSON schedule column, NULL or empty is no schedule
+func unmarshalSchedule(column sql.NullString, s **Schedule) error {
+	*s = nil
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	*s = new(Schedule)
+	return json.Unmarshal([]byte(column.String), *s)
+}
+
+//marshalSchedule encodes s for a schedule column, nil becomes NULL
+func marshalSchedule(s *Schedule) (sql.NullString, error) {
+	if s == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(s)
+	return sql.NullString{String: string(b), Valid: true}, err
+}
+
+//GetBannerByID 根据ID获取Banner
+func (s *MySQLStore) GetBannerByID(ctx context.Context, id int) (Banner, error) {
+	banner := Banner{}
+	row := s.conn().QueryRowContext(ctx, "SELECT "+bannerColumns+" FROM gw_adv_banner WHERE id=? LIMIT 1", id)
+	err := scanBanner(row, &banner)
+	if err == sql.ErrNoRows {
+		return banner, ErrNotFound
+	}
+	return banner, err
+}
+
+//GetBanners Get active Banners By Size and group
+func (s *MySQLStore) GetBanners(ctx context.Context, size string, groupID int) ([]*Banner, error) {
+	return s.queryBanners(ctx, "SELECT "+bannerColumns+" FROM gw_adv_banner WHERE status=? AND size=? AND group_id=? ORDER BY id", StatusActive, size, groupID)
+}
+
+//GetBannersByGroup Get active Banners of a group in every size
+func (s *MySQLStore) GetBannersByGroup(ctx context.Context, groupID int) ([]*Banner, error) {
+	return s.queryBanners(ctx, "SELECT "+bannerColumns+" FROM gw_adv_banner WHERE status=? AND group_id=? ORDER BY id", StatusActive, groupID)
+}
+
+func (s *MySQLStore) queryBanners(ctx context.Context, query string, args ...interface{}) ([]*Banner, error) {
+	var banners []*Banner
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return banners, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		b := new(Banner)
+		if err := scanBanner(rows, b); err != nil {
+			return banners, err
+		}
+		banners = append(banners, b)
+	}
+	return banners, rows.Err()
+}
+
+//GetBannerGroupByClient looks up the banner group of a client in gw_adv_client_banner
+func (s *MySQLStore) GetBannerGroupByClient(ctx context.Context, clientID int) (int, error) {
+	var groupID int
+	row := s.conn().QueryRowContext(ctx, "SELECT group_id FROM gw_adv_client_banner WHERE client_id=? LIMIT 1", clientID)
+	err := row.Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return groupID, err
+}
+
+//InsertBannerLogs writes logs to gw_adv_banner_log with one multi-row INSERT
+func (s *MySQLStore) InsertBannerLogs(ctx context.Context, logs []BannerLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(logs))
+	args := make([]interface{}, 0, len(logs)*8)
+	for _, l := range logs {
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, l.ImpressionID, l.BannerID, l.ClientID, l.Size, l.Language, l.VisitorID, l.Transport, l.Date)
+	}
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO gw_adv_banner_log (impression_id, banner_id, client_id, size, language, visitor_id, transport, date) VALUES "+strings.Join(values, ", "), args...)
+	return err
+}
+
+//InsertClickLog writes click to gw_adv_click_log
+func (s *MySQLStore) InsertClickLog(ctx context.Context, click ClickLog) error {
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO gw_adv_click_log (impression_id, banner_id, client_id, visitor_id, date) VALUES (?, ?, ?, ?, ?)",
+		click.ImpressionID, click.BannerID, click.ClientID, click.VisitorID, click.Date)
+	return err
+}
+
+//AddViewability upserts records into gw_adv_banner_viewability
+func (s *MySQLStore) AddViewability(ctx context.Context, records []BannerViewability) error {
+	if len(records) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(records))
+	args := make([]interface{}, 0, len(records)*5)
+	for _, r := range records {
+		values = append(values, "(?, ?, ?, ?, ?)")
+		args = append(args, r.BannerID, r.Day, r.Sessions, r.ViewableSessions, r.ViewableMillis)
+	}
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO gw_adv_banner_viewability (banner_id, day, sessions, viewable_sessions, viewable_millis) VALUES "+strings.Join(values, ", ")+
+		" ON DUPLICATE KEY UPDATE sessions=sessions+VALUES(sessions), viewable_sessions=viewable_sessions+VALUES(viewable_sessions), viewable_millis=viewable_millis+VALUES(viewable_millis)", args...)
+	return err
+}
+
+//GetViewability reads the daily totals of a banner from gw_adv_banner_viewability
+func (s *MySQLStore) GetViewability(ctx context.Context, bannerID int) ([]BannerViewability, error) {
+	var records []BannerViewability
+	rows, err := s.conn().QueryContext(ctx, "SELECT banner_id, day, sessions, viewable_sessions, viewable_millis FROM gw_adv_banner_viewability WHERE banner_id=? ORDER BY day", bannerID)
+	if err != nil {
+		return records, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r BannerViewability
+		if err := rows.Scan(&r.BannerID, &r.Day, &r.Sessions, &r.ViewableSessions, &r.ViewableMillis); err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+//GetWebsiteByDomain looks up a website in gw_adv_website
+func (s *MySQLStore) GetWebsiteByDomain(ctx context.Context, domain string) (Website, error) {
+	var w Website
+	row := s.conn().QueryRowContext(ctx, "SELECT id, domain, client_id, group_id FROM gw_adv_website WHERE domain=? LIMIT 1", NormalizeDomain(domain))
+	err := row.Scan(&w.ID, &w.Domain, &w.ClientID, &w.GroupID)
+	if err == sql.ErrNoRows {
+		return w, ErrNotFound
+	}
+	return w, err
+}
+
+//GetVisitorProfile reads a profile from gw_adv_visitor_profile
+func (s *MySQLStore) GetVisitorProfile(ctx context.Context, visitorID string) (VisitorProfile, error) {
+	p := VisitorProfile{VisitorID: visitorID}
+	var scores []byte
+	row := s.conn().QueryRowContext(ctx, "SELECT scores, updated FROM gw_adv_visitor_profile WHERE visitor_id=? LIMIT 1", visitorID)
+	err := row.Scan(&scores, &p.Updated)
+	if err == sql.ErrNoRows {
+		return p, ErrNotFound
+	}
+	if err != nil {
+		return p, err
+	}
+	return p, json.Unmarshal(scores, &p.Scores)
+}
+
+//SaveVisitorProfile replaces a profile in gw_adv_visitor_profile
+func (s *MySQLStore) SaveVisitorProfile(ctx context.Context, p VisitorProfile) error {
+	scores, err := json.Marshal(p.Scores)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn().ExecContext(ctx, "REPLACE INTO gw_adv_visitor_profile (visitor_id, scores, updated) VALUES (?, ?, ?)", p.VisitorID, scores, p.Updated)
+	return err
+}
+
+//campaignColumns is the column list scanned by scanCampaign
+const campaignColumns = "id, name, status, start, end, daily_budget, total_budget, served, served_today, day, schedule, version"
+
+func scanCampaign(row scanner, c *Campaign) error {
+	var schedule sql.NullString
+	if err := row.Scan(&c.ID, &c.Name, &c.Status, &c.Start, &c.End, &c.DailyBudget, &c.TotalBudget, &c.Served, &c.ServedToday, &c.Day, &schedule, &c.Version); err != nil {
+		return err
+	}
+	return unmarshalSchedule(schedule, &c.Schedule)
+}
+
+//GetCampaign reads a campaign from gw_adv_campaign
+func (s *MySQLStore) GetCampaign(ctx context.Context, id int) (Campaign, error) {
+	var c Campaign
+	row := s.conn().QueryRowContext(ctx, "SELECT "+campaignColumns+" FROM gw_adv_campaign WHERE id=? LIMIT 1", id)
+	err := scanCampaign(row, &c)
+	if err == sql.ErrNoRows {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+//GetCampaigns reads the campaigns with ids from gw_adv_campaign
+func (s *MySQLStore) GetCampaigns(ctx context.Context, ids []int) ([]Campaign, error) {
+	var campaigns []Campaign
+	if len(ids) == 0 {
+		return campaigns, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.conn().QueryContext(ctx, "SELECT "+campaignColumns+" FROM gw_adv_campaign WHERE id IN (?"+strings.Repeat(", ?", len(ids)-1)+") ORDER BY id", args...)
+	if err != nil {
+		return campaigns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Campaign
+		if err := scanCampaign(rows, &c); err != nil {
+			return campaigns, err
+		}
+		campaigns = append(campaigns, c)
+	}
+	return campaigns, rows.Err()
+}
+
+//AddCampaignImpressions increments the counters in gw_adv_campaign,
+//restarting served_today when day changes
+func (s *MySQLStore) AddCampaignImpressions(ctx context.Context, id, day, n int) (Campaign, error) {
+	res, err := s.conn().ExecContext(ctx, "UPDATE gw_adv_campaign SET served=served+?, served_today=IF(day=?, served_today+?, ?), day=? WHERE id=?", n, day, n, n, day, id)
+	if err != nil {
+		return Campaign{}, err
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return Campaign{}, ErrNotFound
+	}
+	return s.GetCampaign(ctx, id)
+}
+
+//SetCampaignStatus updates the status in gw_adv_campaign if it is still from
+func (s *MySQLStore) SetCampaignStatus(ctx context.Context, id int, from, to string) (bool, error) {
+	res, err := s.conn().ExecContext(ctx, "UPDATE gw_adv_campaign SET status=? WHERE id=? AND status=?", to, id, from)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+//bannerWriteColumns are the columns InsertBanner and UpdateBanner set
+const bannerWriteColumns = "group_id, campaign_id, name, language, size, url, status, weight, priority, tags, landing_tags, daily_target, schedule"
+
+func bannerValues(b *Banner) ([]interface{}, error) {
+	schedule, err := marshalSchedule(b.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{b.GroupID, b.CampaignID, b.Name, b.Language, b.Size, b.URL, b.Status, b.Weight, b.Priority,
+		JoinTags(b.Tags), JoinTags(b.LandingTags), b.DailyTarget, schedule}, nil
+}
+
+//InsertBanner adds b to gw_adv_banner
+func (s *MySQLStore) InsertBanner(ctx context.Context, b *Banner) error {
+	values, err := bannerValues(b)
+	if err != nil {
+		return err
+	}
+	res, err := s.conn().ExecContext(ctx, "INSERT INTO gw_adv_banner ("+bannerWriteColumns+", version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)", values...)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	b.ID, b.Version = int(id), 1
+	return err
+}
+
+//UpdateBanner replaces b in gw_adv_banner if it is still at b.Version
+func (s *MySQLStore) UpdateBanner(ctx context.Context, b *Banner) error {
+	values, err := bannerValues(b)
+	if err != nil {
+		return err
+	}
+	set := strings.Replace(bannerWriteColumns, ",", "=?,", -1) + "=?"
+	res, err := s.conn().ExecContext(ctx, "UPDATE gw_adv_banner SET "+set+", version=version+1 WHERE id=? AND version=?", append(values, b.ID, b.Version)...)
+	if err := s.checkVersioned(ctx, "gw_adv_banner", b.ID, res, err); err != nil {
+		return err
+	}
+	b.Version++
+	return nil
+}
+
+//RemoveBanner deletes a banner from gw_adv_banner if it is still at version
+func (s *MySQLStore) RemoveBanner(ctx context.Context, id, version int) error {
+	res, err := s.conn().ExecContext(ctx, "DELETE FROM gw_adv_banner WHERE id=? AND version=?", id, version)
+	return s.checkVersioned(ctx, "gw_adv_banner", id, res, err)
+}
+
+//GetBannerGroup reads a group from gw_adv_banner_group
+func (s *MySQLStore) GetBannerGroup(ctx context.Context, id int) (BannerGroup, error) {
+	var g BannerGroup
+	row := s.conn().QueryRowContext(ctx, "SELECT id, name, version FROM gw_adv_banner_group WHERE id=? LIMIT 1", id)
+	err := row.Scan(&g.ID, &g.Name, &g.Version)
+	if err == sql.ErrNoRows {
+		return g, ErrNotFound
+	}
+	return g, err
+}
+
+//InsertBannerGroup adds g to gw_adv_banner_group
+func (s *MySQLStore) InsertBannerGroup(ctx context.Context, g *BannerGroup) error {
+	res, err := s.conn().ExecContext(ctx, "INSERT INTO gw_adv_banner_group (name, version) VALUES (?, 1)", g.Name)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	g.ID, g.Version = int(id), 1
+	return err
+}
+
+//UpdateBannerGroup renames g in gw_adv_banner_group if it is still at g.Version
+func (s *MySQLStore) UpdateBannerGroup(ctx context.Context, g *BannerGroup) error {
+	res, err := s.conn().ExecContext(ctx, "UPDATE gw_adv_banner_group SET name=?, version=version+1 WHERE id=? AND version=?", g.Name, g.ID, g.Version)
+	if err := s.checkVersioned(ctx, "gw_adv_banner_group", g.ID, res, err); err != nil {
+		return err
+	}
+	g.Version++
+	return nil
+}
+
+//RemoveBannerGroup deletes a group from gw_adv_banner_group if it is still at version
+func (s *MySQLStore) RemoveBannerGroup(ctx context.Context, id, version int) error {
+	res, err := s.conn().ExecContext(ctx, "DELETE FROM gw_adv_banner_group WHERE id=? AND version=?", id, version)
+	return s.checkVersioned(ctx, "gw_adv_banner_group", id, res, err)
+}
+
+//CountGroupReferences counts the rows of gw_adv_banner, gw_adv_client_banner
+//and gw_adv_website assigned to groupID. The reads lock the rows and the gaps
+//around them, so in a transaction no assignment to the group can be added
+//until it ends.
+func (s *MySQLStore) CountGroupReferences(ctx context.Context, groupID int) (int, error) {
+	total := 0
+	for _, table := range []string{"gw_adv_banner", "gw_adv_client_banner", "gw_adv_website"} {
+		var n int
+		if err := s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table+" WHERE group_id=? FOR UPDATE", groupID).Scan(&n); err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+//GetClient reads a client from gw_adv_client and its group from gw_adv_client_banner
+func (s *MySQLStore) GetClient(ctx context.Context, id int) (Client, error) {
+	var c Client
+	var groupID sql.NullInt64
+	row := s.conn().QueryRowContext(ctx, "SELECT c.id, c.client_name, c.version, cb.group_id FROM gw_adv_client c LEFT JOIN gw_adv_client_banner cb ON cb.client_id=c.id WHERE c.id=? LIMIT 1", id)
+	err := row.Scan(&c.ID, &c.ClientName, &c.Version, &groupID)
+	if err == sql.ErrNoRows {
+		return c, ErrNotFound
+	}
+	c.GroupID = int(groupID.Int64)
+	return c, err
+}
+
+//InsertClient adds c to gw_adv_client and its group to gw_adv_client_banner
+func (s *MySQLStore) InsertClient(ctx context.Context, c *Client) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "INSERT INTO gw_adv_client (client_name, version) VALUES (?, 1)", c.ClientName)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		c.ID, c.Version = int(id), 1
+		return setClientBanner(ctx, tx, c)
+	})
+}
+
+//UpdateClient changes c in gw_adv_client and gw_adv_client_banner if it is still at c.Version
+func (s *MySQLStore) UpdateClient(ctx context.Context, c *Client) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE gw_adv_client SET client_name=?, version=version+1 WHERE id=? AND version=?", c.ClientName, c.ID, c.Version)
+		if err := s.checkVersioned(ctx, "gw_adv_client", c.ID, res, err); err != nil {
+			return err
+		}
+		return setClientBanner(ctx, tx, c)
+	})
+	if err == nil {
+		c.Version++
+	}
+	return err
+}
+
+//RemoveClient deletes a client and its group mapping if it is still at version
+func (s *MySQLStore) RemoveClient(ctx context.Context, id, version int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM gw_adv_client WHERE id=? AND version=?", id, version)
+		if err := s.checkVersioned(ctx, "gw_adv_client", id, res, err); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM gw_adv_client_banner WHERE client_id=?", id)
+		return err
+	})
+}
+
+//setClientBanner replaces the gw_adv_client_banner row of c, group 0 removes it
+func setClientBanner(ctx context.Context, tx *sql.Tx, c *Client) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM gw_adv_client_banner WHERE client_id=?", c.ID); err != nil || c.GroupID == 0 {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO gw_adv_client_banner (client_id, group_id) VALUES (?, ?)", c.ID, c.GroupID)
+	return err
+}
+
+//checkVersioned turns a versioned write that matched no row into
+//ErrVersionConflict or ErrNotFound
+func (s *MySQLStore) checkVersioned(ctx context.Context, table string, id int, res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+	var exists int
+	err = s.conn().QueryRowContext(ctx, "SELECT 1 FROM "+table+" WHERE id=? LIMIT 1", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+//conn returns the transaction of s, or its database outside of one
+func (s *MySQLStore) conn() execer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+//inTx runs fn in a transaction, committing if it succeeds. Inside the
+//transaction of s fn joins it.
+func (s *MySQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//InsertCampaign adds c to gw_adv_campaign with no impressions served
+func (s *MySQLStore) InsertCampaign(ctx context.Context, c *Campaign) error {
+	schedule, err := marshalSchedule(c.Schedule)
+	if err != nil {
+		return err
+	}
+	res, err := s.conn().ExecContext(ctx, "INSERT INTO gw_adv_campaign (name, status, start, end, daily_budget, total_budget, served, served_today, day, schedule, version) VALUES (?, ?, ?, ?, ?, ?, 0, 0, 0, ?, 1)",
+		c.Name, c.Status, c.Start, c.End, c.DailyBudget, c.TotalBudget, schedule)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	c.ID, c.Version = int(id), 1
+	c.Served, c.ServedToday, c.Day = 0, 0, 0
+	return err
+}
+
+//UpdateCampaign changes the settings of c in gw_adv_campaign if it is still
+//at c.Version, and reads back the stored row with its status and counters
+func (s *MySQLStore) UpdateCampaign(ctx context.Context, c *Campaign) error {
+	schedule, err := marshalSchedule(c.Schedule)
+	if err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE gw_adv_campaign SET name=?, start=?, end=?, daily_budget=?, total_budget=?, schedule=?, version=version+1 WHERE id=? AND version=?",
+			c.Name, c.Start, c.End, c.DailyBudget, c.TotalBudget, schedule, c.ID, c.Version)
+		if err := s.checkVersioned(ctx, "gw_adv_campaign", c.ID, res, err); err != nil {
+			return err
+		}
+		row := tx.QueryRowContext(ctx, "SELECT "+campaignColumns+" FROM gw_adv_campaign WHERE id=? LIMIT 1", c.ID)
+		return scanCampaign(row, c)
+	})
+}
+
+//InsertAuditEntry appends e to gw_adv_audit_log
+func (s *MySQLStore) InsertAuditEntry(ctx context.Context, e AuditEntry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn().ExecContext(ctx, "INSERT INTO gw_adv_audit_log (actor, entity, entity_id, action, version, changes, date) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.Actor, e.Entity, e.EntityID, e.Action, e.Version, changes, e.Date)
+	return err
+}
+
+//Audited runs fn on a store bound to a transaction and appends its entry
+//to gw_adv_audit_log in the same transaction
+func (s *MySQLStore) Audited(ctx context.Context, fn func(Store) (AuditEntry, error)) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		store := &MySQLStore{db: s.db, tx: tx}
+		e, err := fn(store)
+		if err != nil {
+			return err
+		}
+		return store.InsertAuditEntry(ctx, e)
+	})
+}
+
+//QueryAuditEntries reads gw_adv_audit_log, newest first
+func (s *MySQLStore) QueryAuditEntries(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	where, args := []string{"1=1"}, []interface{}{}
+	if q.Entity != "" {
+		where, args = append(where, "entity=?"), append(args, q.Entity)
+	}
+	if q.EntityID != 0 {
+		where, args = append(where, "entity_id=?"), append(args, q.EntityID)
+	}
+	if q.From != 0 {
+		where, args = append(where, "date>=?"), append(args, q.From)
+	}
+	if q.To != 0 {
+		where, args = append(where, "date<=?"), append(args, q.To)
+	}
+	query := "SELECT id, actor, entity, entity_id, action, version, changes, date FROM gw_adv_audit_log WHERE " + strings.Join(where, " AND ") + " ORDER BY date DESC, id DESC"
+	if q.Limit > 0 {
+		query, args = query+" LIMIT ?", append(args, q.Limit)
+	}
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e AuditEntry
+		var changes sql.NullString
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Entity, &e.EntityID, &e.Action, &e.Version, &changes, &e.Date); err != nil {
+			return entries, err
+		}
+		if changes.Valid && changes.String != "" {
+			if err := json.Unmarshal([]byte(changes.String), &e.Changes); err != nil {
+				return entries, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,28 @@
+package models
+
+import "context"
+
+//VisitorProfile holds the tag scores a visitor accumulated from impressions and clicks
+type VisitorProfile struct {
+	VisitorID string
+	//Scores are valid as of Updated and decay from then on
+	Scores map[string]float64
+	//Updated is the unix time of the last change in seconds
+	Updated int64
+}
+
+func (p VisitorProfile) clone() VisitorProfile {
+	scores := make(map[string]float64, len(p.Scores))
+	for tag, score := range p.Scores {
+		scores[tag] = score
+	}
+	p.Scores = scores
+	return p
+}
+
+//ProfileStore persists visitor profiles
+type ProfileStore interface {
+	//GetVisitorProfile returns the profile of a visitor, or ErrNotFound
+	GetVisitorProfile(ctx context.Context, visitorID string) (VisitorProfile, error)
+	SaveVisitorProfile(ctx context.Context, p VisitorProfile) error
+}
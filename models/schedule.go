@@ -0,0 +1,120 @@
+package models
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//Schedule restricts serving to windows of the week in a timezone. A nil
+//Schedule, or one without windows, is always on.
+type Schedule struct {
+	//Timezone is an IANA name like Europe/Berlin, empty means UTC
+	Timezone string           `json:"timezone"`
+	Windows  []ScheduleWindow `json:"windows"`
+}
+
+//ScheduleWindow is a range of hours on some days of the week. A window
+//whose End is not after its Start runs over midnight into the next day,
+//so 22-6 on fri is Friday 22:00 to Saturday 06:00.
+type ScheduleWindow struct {
+	//Days are lower case weekday abbreviations (mon, tue, ...), empty is every day
+	Days []string `json:"days,omitempty"`
+	//Start is the first hour served, 0-23, End the hour serving stops, 1-24
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+//ScheduleError describes why a schedule was rejected
+type ScheduleError struct {
+	Reason string
+}
+
+func (e ScheduleError) Error() string {
+	return "models: invalid schedule: " + e.Reason
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+//locations caches loaded timezones by name
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+//Validate checks the timezone, days and hours of s
+func (s *Schedule) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if _, err := loadLocation(s.Timezone); err != nil {
+		return ScheduleError{"unknown timezone " + s.Timezone}
+	}
+	for _, w := range s.Windows {
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				return ScheduleError{"unknown day " + d}
+			}
+		}
+		if w.Start < 0 || w.Start > 23 || w.End < 1 || w.End > 24 || w.Start == w.End {
+			return ScheduleError{"hours must be 0 <= start <= 23, 1 <= end <= 24 and differ"}
+		}
+	}
+	return nil
+}
+
+//Active reports whether t falls into a window of s. Schedules that do not
+//validate are never active.
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil || len(s.Windows) == 0 {
+		return true
+	}
+	loc, err := loadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	day, hour := t.Weekday(), t.Hour()
+	for _, w := range s.Windows {
+		if w.Start < w.End {
+			if w.on(day) && hour >= w.Start && hour < w.End {
+				return true
+			}
+			continue
+		}
+		if (w.on(day) && hour >= w.Start) || (w.on((day+6)%7) && hour < w.End) {
+			return true
+		}
+	}
+	return false
+}
+
+//on reports whether w starts on day
+func (w ScheduleWindow) on(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if wd, ok := weekdays[strings.ToLower(d)]; ok && wd == day {
+			return true
+		}
+	}
+	return false
+}
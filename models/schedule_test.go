@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	business := &Schedule{
+		Timezone: "America/New_York",
+		Windows:  []ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: 9, End: 17}},
+	}
+	weekendNights := &Schedule{
+		Windows: []ScheduleWindow{{Days: []string{"Fri", "sat"}, Start: 22, End: 6}},
+	}
+	cases := []struct {
+		s    *Schedule
+		t    string
+		want bool
+	}{
+		{nil, "2019-03-04T03:00:00Z", true},
+		{business, "2019-03-04T14:00:00Z", true},  //Monday 09:00 EST
+		{business, "2019-03-04T13:59:00Z", false}, //Monday 08:59 EST
+		{business, "2019-03-04T22:00:00Z", false}, //Monday 17:00 EST
+		{business, "2019-03-12T13:30:00Z", true},  //Tuesday 09:30 EDT
+		{business, "2019-03-09T15:00:00Z", false}, //Saturday
+		{weekendNights, "2019-03-08T23:00:00Z", true},
+		{weekendNights, "2019-03-09T05:59:00Z", true},
+		{weekendNights, "2019-03-10T05:00:00Z", true},
+		{weekendNights, "2019-03-10T07:00:00Z", false},
+		{weekendNights, "2019-03-11T01:00:00Z", false},
+		{&Schedule{Timezone: "Nowhere/Special", Windows: []ScheduleWindow{{Start: 0, End: 24}}}, "2019-03-04T03:00:00Z", false},
+	}
+	for _, tc := range cases {
+		at, _ := time.Parse(time.RFC3339, tc.t)
+		if got := tc.s.Active(at); got != tc.want {
+			t.Errorf("%+v at %s: want %v, got %v", tc.s, tc.t, tc.want, got)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	for _, s := range []*Schedule{
+		{Timezone: "Mars/Olympus"},
+		{Windows: []ScheduleWindow{{Days: []string{"someday"}, Start: 1, End: 2}}},
+		{Windows: []ScheduleWindow{{Start: 5, End: 5}}},
+		{Windows: []ScheduleWindow{{Start: 0, End: 25}}},
+	} {
+		if _, ok := s.Validate().(ScheduleError); !ok {
+			t.Errorf("want %+v rejected", s)
+		}
+	}
+}
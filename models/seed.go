@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"fmt"
+)
+
+//Fixture is sample data for a development database. IDs only link the rows
+//of the fixture to each other, the store assigns the seeded rows new ones.
+type Fixture struct {
+	Groups    []BannerGroup `json:"groups"`
+	Campaigns []Campaign    `json:"campaigns"`
+	Clients   []Client      `json:"clients"`
+	Banners   []Banner      `json:"banners"`
+}
+
+//Seed inserts the rows of f into store and returns them with the IDs and
+//versions they were stored with. Seeding the same fixture twice inserts its
+//rows twice.
+func Seed(ctx context.Context, store AdminStore, f Fixture) (Fixture, error) {
+	var seeded Fixture
+	groups := map[int]int{0: 0}
+	for _, g := range f.Groups {
+		fixtureID := g.ID
+		if err := store.InsertBannerGroup(ctx, &g); err != nil {
+			return seeded, fmt.Errorf("seed: group %d: %v", fixtureID, err)
+		}
+		groups[fixtureID] = g.ID
+		seeded.Groups = append(seeded.Groups, g)
+	}
+	campaigns := map[int]int{0: 0}
+	for _, c := range f.Campaigns {
+		fixtureID := c.ID
+		if c.Status == "" {
+			c.Status = CampaignDraft
+		}
+		if err := store.InsertCampaign(ctx, &c); err != nil {
+			return seeded, fmt.Errorf("seed: campaign %d: %v", fixtureID, err)
+		}
+		campaigns[fixtureID] = c.ID
+		seeded.Campaigns = append(seeded.Campaigns, c)
+	}
+	for _, c := range f.Clients {
+		groupID, ok := groups[c.GroupID]
+		if !ok {
+			return seeded, fmt.Errorf("seed: client %d: unknown group %d", c.ID, c.GroupID)
+		}
+		fixtureID := c.ID
+		c.GroupID = groupID
+		if err := store.InsertClient(ctx, &c); err != nil {
+			return seeded, fmt.Errorf("seed: client %d: %v", fixtureID, err)
+		}
+		seeded.Clients = append(seeded.Clients, c)
+	}
+	for _, b := range f.Banners {
+		groupID, ok := groups[b.GroupID]
+		if !ok || groupID == 0 {
+			return seeded, fmt.Errorf("seed: banner %d: unknown group %d", b.ID, b.GroupID)
+		}
+		campaignID, ok := campaigns[b.CampaignID]
+		if !ok {
+			return seeded, fmt.Errorf("seed: banner %d: unknown campaign %d", b.ID, b.CampaignID)
+		}
+		fixtureID := b.ID
+		b.GroupID, b.CampaignID = groupID, campaignID
+		if err := store.InsertBanner(ctx, &b); err != nil {
+			return seeded, fmt.Errorf("seed: banner %d: %v", fixtureID, err)
+		}
+		seeded.Banners = append(seeded.Banners, b)
+	}
+	return seeded, nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.PutBanner(Banner{ID: 1, GroupID: 1})
+	seeded, err := Seed(ctx, store, Fixture{
+		Groups:    []BannerGroup{{ID: 1, Name: "home"}, {ID: 2, Name: "news"}},
+		Campaigns: []Campaign{{ID: 1, Name: "spring"}},
+		Clients:   []Client{{ID: 1, ClientName: "acme", GroupID: 2}},
+		Banners:   []Banner{{ID: 1, GroupID: 2, CampaignID: 1, Name: "a", Size: "40*50", Status: StatusActive}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	news, b := seeded.Groups[1], seeded.Banners[0]
+	if b.ID == 1 || b.GroupID != news.ID || b.CampaignID != seeded.Campaigns[0].ID {
+		t.Errorf("want banner relinked to the seeded rows, got %+v", b)
+	}
+	if seeded.Campaigns[0].Status != CampaignDraft {
+		t.Errorf("want draft campaign, got %q", seeded.Campaigns[0].Status)
+	}
+	if g, err := store.GetBannerGroupByClient(ctx, seeded.Clients[0].ID); err != nil || g != news.ID {
+		t.Errorf("want client in group %d, got %d (%v)", news.ID, g, err)
+	}
+	if _, err := Seed(ctx, store, Fixture{Banners: []Banner{{ID: 1, GroupID: 9}}}); err == nil {
+		t.Error("want error for a banner of an unknown group")
+	}
+}
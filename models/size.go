@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//ErrInvalidSize is returned by ParseSize for strings that are not a size
+var ErrInvalidSize = errors.New("invalid size")
+
+//Size is the width and height of a banner in pixels
+type Size struct {
+	Width  int
+	Height int
+}
+
+//iabSizes maps IAB standard ad unit names to their size
+var iabSizes = map[string]Size{
+	"medium-rectangle":   {300, 250},
+	"large-rectangle":    {336, 280},
+	"leaderboard":        {728, 90},
+	"large-leaderboard":  {970, 90},
+	"mobile-leaderboard": {320, 50},
+	"billboard":          {970, 250},
+	"half-page":          {300, 600},
+	"skyscraper":         {120, 600},
+	"wide-skyscraper":    {160, 600},
+	"full-banner":        {468, 60},
+	"half-banner":        {234, 60},
+	"square":             {250, 250},
+	"small-square":       {200, 200},
+	"vertical-banner":    {120, 240},
+}
+
+//ParseSize parses "300x250", "300*250" or an IAB unit name like
+//"medium-rectangle" or "Medium Rectangle"
+func ParseSize(s string) (Size, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if size, ok := iabSizes[strings.Replace(s, " ", "-", -1)]; ok {
+		return size, nil
+	}
+	sep := strings.IndexAny(s, "x*")
+	if sep < 0 {
+		return Size{}, ErrInvalidSize
+	}
+	w, err := strconv.Atoi(strings.TrimSpace(s[:sep]))
+	if err != nil || w <= 0 {
+		return Size{}, ErrInvalidSize
+	}
+	h, err := strconv.Atoi(strings.TrimSpace(s[sep+1:]))
+	if err != nil || h <= 0 {
+		return Size{}, ErrInvalidSize
+	}
+	return Size{w, h}, nil
+}
+
+//String formats s the way sizes are stored in gw_adv_banner, "300*250"
+func (s Size) String() string {
+	return fmt.Sprintf("%d*%d", s.Width, s.Height)
+}
+
+//Fits reports whether s fits inside container
+func (s Size) Fits(container Size) bool {
+	return s.Width <= container.Width && s.Height <= container.Height
+}
+
+//aspectTolerance is how far two aspect ratios may differ relatively and still match
+const aspectTolerance = 0.02
+
+//SameAspect reports whether s and o have the same aspect ratio within 2%
+func (s Size) SameAspect(o Size) bool {
+	if s.Height == 0 || o.Height == 0 {
+		return false
+	}
+	a := float64(s.Width) / float64(s.Height)
+	b := float64(o.Width) / float64(o.Height)
+	d := a/b - 1
+	return d <= aspectTolerance && d >= -aspectTolerance
+}
+
+//ParsedSize parses the banner's Size
+func (b Banner) ParsedSize() (Size, error) {
+	return ParseSize(b.Size)
+}
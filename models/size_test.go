@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]Size{
+		"40*50":            {40, 50},
+		"40x50":            {40, 50},
+		" 300 X 250 ":      {300, 250},
+		"medium-rectangle": {300, 250},
+		"Medium Rectangle": {300, 250},
+		"leaderboard":      {728, 90},
+	} {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "40", "40x", "x50", "0x50", "-1*5", "big", "40x50x60"} {
+		if _, err := ParseSize(in); err != ErrInvalidSize {
+			t.Errorf("ParseSize(%q): want ErrInvalidSize, got %v", in, err)
+		}
+	}
+	if s := (Size{300, 250}).String(); s != "300*250" {
+		t.Errorf("want stored format 300*250, got %s", s)
+	}
+}
+
+func TestSizeMatching(t *testing.T) {
+	container := Size{300, 250}
+	if !(Size{300, 250}).Fits(container) || !(Size{120, 60}).Fits(container) || (Size{320, 50}).Fits(container) {
+		t.Error("Fits is wrong")
+	}
+	if !(Size{600, 500}).SameAspect(container) || !(Size{301, 250}).SameAspect(container) || (Size{250, 250}).SameAspect(container) {
+		t.Error("SameAspect is wrong")
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+	"errors"
+)
+
+//ErrNotFound is returned by a BannerStore when the requested row does not exist
+var ErrNotFound = errors.New("models: not found")
+
+//BannerStore is the persistence boundary of the ad service.
+//MySQLStore is used in production, MemoryStore for tests and local runs.
+type BannerStore interface {
+	//GetBannerByID returns a single banner, or ErrNotFound
+	GetBannerByID(ctx context.Context, id int) (Banner, error)
+	//GetBanners returns the active banners of a group with the given size
+	GetBanners(ctx context.Context, size string, groupID int) ([]*Banner, error)
+	//GetBannersByGroup returns the active banners of a group in every size
+	GetBannersByGroup(ctx context.Context, groupID int) ([]*Banner, error)
+	//GetBannerGroupByClient returns the banner group assigned to a client, or ErrNotFound
+	GetBannerGroupByClient(ctx context.Context, clientID int) (int, error)
+}
+
+//Store is everything the service needs from the database
+type Store interface {
+	BannerStore
+	BannerLogStore
+	ClickLogStore
+	ViewabilityStore
+	WebsiteStore
+	ProfileStore
+	CampaignStore
+	AdminStore
+	AuditStore
+}
@@ -0,0 +1,24 @@
+package models
+
+import "context"
+
+//BannerViewability accumulates heartbeat sessions of a banner for one day
+type BannerViewability struct {
+	BannerID int
+	//Day is the unix time of 00:00 UTC of the day
+	Day int
+	//Sessions counts impressions that sent at least one heartbeat
+	Sessions int
+	//ViewableSessions counts sessions visible for at least the viewable threshold
+	ViewableSessions int
+	//ViewableMillis is the total time banners were visible
+	ViewableMillis int64
+}
+
+//ViewabilityStore persists viewability metrics per banner
+type ViewabilityStore interface {
+	//AddViewability adds each record to the stored totals of its banner and day
+	AddViewability(ctx context.Context, records []BannerViewability) error
+	//GetViewability returns the daily totals of a banner ordered by day
+	GetViewability(ctx context.Context, bannerID int) ([]BannerViewability, error)
+}
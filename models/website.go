@@ -0,0 +1,39 @@
+package models
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+)
+
+//Website is a site of a client that embeds banners
+type Website struct {
+	ID       int
+	Domain   string
+	ClientID int
+	//GroupID is the banner group served on the site, 0 to use the client's group
+	GroupID int
+}
+
+//WebsiteStore looks up websites
+type WebsiteStore interface {
+	//GetWebsiteByDomain returns the website with the normalized domain, or ErrNotFound
+	GetWebsiteByDomain(ctx context.Context, domain string) (Website, error)
+}
+
+//NormalizeDomain reduces a domain, host:port or URL to the lower case host
+//name used as Website.Domain, "www." is dropped
+func NormalizeDomain(s string) string {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil {
+			s = u.Host
+		}
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(s, ".")
+	return strings.TrimPrefix(s, "www.")
+}
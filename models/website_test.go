@@ -0,0 +1,18 @@
+package models
+
+import "testing"
+
+func TestNormalizeDomain(t *testing.T) {
+	for in, want := range map[string]string{
+		"example.com":                   "example.com",
+		"WWW.Example.COM":               "example.com",
+		"example.com:8080":              "example.com",
+		"https://www.example.com/a?b=c": "example.com",
+		"blog.example.com.":             "blog.example.com",
+		" example.com ":                 "example.com",
+	} {
+		if got := NormalizeDomain(in); got != want {
+			t.Errorf("NormalizeDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
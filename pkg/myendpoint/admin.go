@@ -0,0 +1,419 @@
+package myendpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myservice"
+)
+
+// AdminSet collects all endpoints that compose the admin service.
+type AdminSet struct {
+	CreateBannerEndpoint      endpoint.Endpoint
+	UpdateBannerEndpoint      endpoint.Endpoint
+	SetBannerStatusEndpoint   endpoint.Endpoint
+	DeleteBannerEndpoint      endpoint.Endpoint
+	GetGroupEndpoint          endpoint.Endpoint
+	CreateGroupEndpoint       endpoint.Endpoint
+	UpdateGroupEndpoint       endpoint.Endpoint
+	DeleteGroupEndpoint       endpoint.Endpoint
+	GetClientEndpoint         endpoint.Endpoint
+	CreateClientEndpoint      endpoint.Endpoint
+	UpdateClientEndpoint      endpoint.Endpoint
+	DeleteClientEndpoint      endpoint.Endpoint
+	GetCampaignEndpoint       endpoint.Endpoint
+	CreateCampaignEndpoint    endpoint.Endpoint
+	UpdateCampaignEndpoint    endpoint.Endpoint
+	SetCampaignStatusEndpoint endpoint.Endpoint
+	AuditLogEndpoint          endpoint.Endpoint
+}
+
+// AdminSet is also usable as a client of the admin service.
+var _ myservice.AdminService = AdminSet{}
+
+// NewAdmin returns an AdminSet that wraps the provided server. Every
+// endpoint requires a token known to auth.
+func NewAdmin(svc myservice.AdminService, auth *myservice.Authenticator, logger log.Logger, duration metrics.Histogram) AdminSet {
+	wrap := func(method string, e endpoint.Endpoint) endpoint.Endpoint {
+		e = AuthMiddleware(auth)(e)
+		e = LoggingMiddleware(log.With(logger, "method", method))(e)
+		return InstrumentingMiddleware(duration.With("method", method))(e)
+	}
+	return AdminSet{
+		CreateBannerEndpoint:      wrap("CreateBanner", MakeCreateBannerEndpoint(svc)),
+		UpdateBannerEndpoint:      wrap("UpdateBanner", MakeUpdateBannerEndpoint(svc)),
+		SetBannerStatusEndpoint:   wrap("SetBannerStatus", MakeSetBannerStatusEndpoint(svc)),
+		DeleteBannerEndpoint:      wrap("DeleteBanner", MakeDeleteBannerEndpoint(svc)),
+		GetGroupEndpoint:          wrap("GetGroup", MakeGetGroupEndpoint(svc)),
+		CreateGroupEndpoint:       wrap("CreateGroup", MakeCreateGroupEndpoint(svc)),
+		UpdateGroupEndpoint:       wrap("UpdateGroup", MakeUpdateGroupEndpoint(svc)),
+		DeleteGroupEndpoint:       wrap("DeleteGroup", MakeDeleteGroupEndpoint(svc)),
+		GetClientEndpoint:         wrap("GetClient", MakeGetClientEndpoint(svc)),
+		CreateClientEndpoint:      wrap("CreateClient", MakeCreateClientEndpoint(svc)),
+		UpdateClientEndpoint:      wrap("UpdateClient", MakeUpdateClientEndpoint(svc)),
+		DeleteClientEndpoint:      wrap("DeleteClient", MakeDeleteClientEndpoint(svc)),
+		GetCampaignEndpoint:       wrap("GetCampaign", MakeGetCampaignEndpoint(svc)),
+		CreateCampaignEndpoint:    wrap("CreateCampaign", MakeCreateCampaignEndpoint(svc)),
+		UpdateCampaignEndpoint:    wrap("UpdateCampaign", MakeUpdateCampaignEndpoint(svc)),
+		SetCampaignStatusEndpoint: wrap("SetCampaignStatus", MakeSetCampaignStatusEndpoint(svc)),
+		AuditLogEndpoint:          wrap("AuditLog", MakeAuditLogEndpoint(svc)),
+	}
+}
+
+// CreateBanner implements the admin service interface.
+func (s AdminSet) CreateBanner(ctx context.Context, b models.Banner) (models.Banner, error) {
+	return bannerResult(s.CreateBannerEndpoint(ctx, b))
+}
+
+// UpdateBanner implements the admin service interface.
+func (s AdminSet) UpdateBanner(ctx context.Context, b models.Banner) (models.Banner, error) {
+	return bannerResult(s.UpdateBannerEndpoint(ctx, b))
+}
+
+// SetBannerStatus implements the admin service interface.
+func (s AdminSet) SetBannerStatus(ctx context.Context, id, version int, active bool) (models.Banner, error) {
+	return bannerResult(s.SetBannerStatusEndpoint(ctx, SetBannerStatusRequest{ID: id, Version: version, Active: active}))
+}
+
+// DeleteBanner implements the admin service interface.
+func (s AdminSet) DeleteBanner(ctx context.Context, id, version int) error {
+	return deleteResult(s.DeleteBannerEndpoint(ctx, DeleteRequest{ID: id, Version: version}))
+}
+
+// GetGroup implements the admin service interface.
+func (s AdminSet) GetGroup(ctx context.Context, id int) (models.BannerGroup, error) {
+	return groupResult(s.GetGroupEndpoint(ctx, GetRequest{ID: id}))
+}
+
+// CreateGroup implements the admin service interface.
+func (s AdminSet) CreateGroup(ctx context.Context, g models.BannerGroup) (models.BannerGroup, error) {
+	return groupResult(s.CreateGroupEndpoint(ctx, g))
+}
+
+// UpdateGroup implements the admin service interface.
+func (s AdminSet) UpdateGroup(ctx context.Context, g models.BannerGroup) (models.BannerGroup, error) {
+	return groupResult(s.UpdateGroupEndpoint(ctx, g))
+}
+
+// DeleteGroup implements the admin service interface.
+func (s AdminSet) DeleteGroup(ctx context.Context, id, version int) error {
+	return deleteResult(s.DeleteGroupEndpoint(ctx, DeleteRequest{ID: id, Version: version}))
+}
+
+// GetClient implements the admin service interface.
+func (s AdminSet) GetClient(ctx context.Context, id int) (models.Client, error) {
+	return clientResult(s.GetClientEndpoint(ctx, GetRequest{ID: id}))
+}
+
+// CreateClient implements the admin service interface.
+func (s AdminSet) CreateClient(ctx context.Context, c models.Client) (models.Client, error) {
+	return clientResult(s.CreateClientEndpoint(ctx, c))
+}
+
+// UpdateClient implements the admin service interface.
+func (s AdminSet) UpdateClient(ctx context.Context, c models.Client) (models.Client, error) {
+	return clientResult(s.UpdateClientEndpoint(ctx, c))
+}
+
+// DeleteClient implements the admin service interface.
+func (s AdminSet) DeleteClient(ctx context.Context, id, version int) error {
+	return deleteResult(s.DeleteClientEndpoint(ctx, DeleteRequest{ID: id, Version: version}))
+}
+
+// GetCampaign implements the admin service interface.
+func (s AdminSet) GetCampaign(ctx context.Context, id int) (models.Campaign, error) {
+	return campaignResult(s.GetCampaignEndpoint(ctx, GetRequest{ID: id}))
+}
+
+// CreateCampaign implements the admin service interface.
+func (s AdminSet) CreateCampaign(ctx context.Context, c models.Campaign) (models.Campaign, error) {
+	return campaignResult(s.CreateCampaignEndpoint(ctx, c))
+}
+
+// UpdateCampaign implements the admin service interface.
+func (s AdminSet) UpdateCampaign(ctx context.Context, c models.Campaign) (models.Campaign, error) {
+	return campaignResult(s.UpdateCampaignEndpoint(ctx, c))
+}
+
+// SetCampaignStatus implements the admin service interface.
+func (s AdminSet) SetCampaignStatus(ctx context.Context, id int, status string) (models.Campaign, error) {
+	return campaignResult(s.SetCampaignStatusEndpoint(ctx, SetCampaignStatusRequest{ID: id, Status: status}))
+}
+
+// AuditLog implements the admin service interface.
+func (s AdminSet) AuditLog(ctx context.Context, req myservice.AuditRequest) ([]models.AuditEntry, error) {
+	resp, err := s.AuditLogEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(AuditLogResponse)
+	return response.Entries, response.Err
+}
+
+func bannerResult(resp interface{}, err error) (models.Banner, error) {
+	if err != nil {
+		return models.Banner{}, err
+	}
+	response := resp.(BannerResponse)
+	return response.Banner, response.Err
+}
+
+func groupResult(resp interface{}, err error) (models.BannerGroup, error) {
+	if err != nil {
+		return models.BannerGroup{}, err
+	}
+	response := resp.(GroupResponse)
+	return response.Group, response.Err
+}
+
+func clientResult(resp interface{}, err error) (models.Client, error) {
+	if err != nil {
+		return models.Client{}, err
+	}
+	response := resp.(ClientResponse)
+	return response.Client, response.Err
+}
+
+func campaignResult(resp interface{}, err error) (models.Campaign, error) {
+	if err != nil {
+		return models.Campaign{}, err
+	}
+	response := resp.(CampaignResponse)
+	return response.Campaign, response.Err
+}
+
+func deleteResult(resp interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	return resp.(DeleteResponse).Err
+}
+
+// MakeCreateBannerEndpoint constructs a CreateBanner endpoint wrapping the service.
+func MakeCreateBannerEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		b, err := s.CreateBanner(ctx, request.(models.Banner))
+		return BannerResponse{Banner: b, Err: err}, nil
+	}
+}
+
+// MakeUpdateBannerEndpoint constructs an UpdateBanner endpoint wrapping the service.
+func MakeUpdateBannerEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		b, err := s.UpdateBanner(ctx, request.(models.Banner))
+		return BannerResponse{Banner: b, Err: err}, nil
+	}
+}
+
+// MakeSetBannerStatusEndpoint constructs a SetBannerStatus endpoint wrapping the service.
+func MakeSetBannerStatusEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SetBannerStatusRequest)
+		b, err := s.SetBannerStatus(ctx, req.ID, req.Version, req.Active)
+		return BannerResponse{Banner: b, Err: err}, nil
+	}
+}
+
+// MakeDeleteBannerEndpoint constructs a DeleteBanner endpoint wrapping the service.
+func MakeDeleteBannerEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteRequest)
+		return DeleteResponse{Err: s.DeleteBanner(ctx, req.ID, req.Version)}, nil
+	}
+}
+
+// MakeGetGroupEndpoint constructs a GetGroup endpoint wrapping the service.
+func MakeGetGroupEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		g, err := s.GetGroup(ctx, request.(GetRequest).ID)
+		return GroupResponse{Group: g, Err: err}, nil
+	}
+}
+
+// MakeCreateGroupEndpoint constructs a CreateGroup endpoint wrapping the service.
+func MakeCreateGroupEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		g, err := s.CreateGroup(ctx, request.(models.BannerGroup))
+		return GroupResponse{Group: g, Err: err}, nil
+	}
+}
+
+// MakeUpdateGroupEndpoint constructs an UpdateGroup endpoint wrapping the service.
+func MakeUpdateGroupEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		g, err := s.UpdateGroup(ctx, request.(models.BannerGroup))
+		return GroupResponse{Group: g, Err: err}, nil
+	}
+}
+
+// MakeDeleteGroupEndpoint constructs a DeleteGroup endpoint wrapping the service.
+func MakeDeleteGroupEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteRequest)
+		return DeleteResponse{Err: s.DeleteGroup(ctx, req.ID, req.Version)}, nil
+	}
+}
+
+// MakeGetClientEndpoint constructs a GetClient endpoint wrapping the service.
+func MakeGetClientEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		c, err := s.GetClient(ctx, request.(GetRequest).ID)
+		return ClientResponse{Client: c, Err: err}, nil
+	}
+}
+
+// MakeCreateClientEndpoint constructs a CreateClient endpoint wrapping the service.
+func MakeCreateClientEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		c, err := s.CreateClient(ctx, request.(models.Client))
+		return ClientResponse{Client: c, Err: err}, nil
+	}
+}
+
+// MakeUpdateClientEndpoint constructs an UpdateClient endpoint wrapping the service.
+func MakeUpdateClientEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		c, err := s.UpdateClient(ctx, request.(models.Client))
+		return ClientResponse{Client: c, Err: err}, nil
+	}
+}
+
+// MakeDeleteClientEndpoint constructs a DeleteClient endpoint wrapping the service.
+func MakeDeleteClientEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteRequest)
+		return DeleteResponse{Err: s.DeleteClient(ctx, req.ID, req.Version)}, nil
+	}
+}
+
+// MakeGetCampaignEndpoint constructs a GetCampaign endpoint wrapping the service.
+func MakeGetCampaignEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		c, err := s.GetCampaign(ctx, request.(GetRequest).ID)
+		return CampaignResponse{Campaign: c, Err: err}, nil
+	}
+}
+
+// MakeCreateCampaignEndpoint constructs a CreateCampaign endpoint wrapping the service.
+func MakeCreateCampaignEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		c, err := s.CreateCampaign(ctx, request.(models.Campaign))
+		return CampaignResponse{Campaign: c, Err: err}, nil
+	}
+}
+
+// MakeUpdateCampaignEndpoint constructs an UpdateCampaign endpoint wrapping the service.
+func MakeUpdateCampaignEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		c, err := s.UpdateCampaign(ctx, request.(models.Campaign))
+		return CampaignResponse{Campaign: c, Err: err}, nil
+	}
+}
+
+// MakeSetCampaignStatusEndpoint constructs a SetCampaignStatus endpoint wrapping the service.
+func MakeSetCampaignStatusEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SetCampaignStatusRequest)
+		c, err := s.SetCampaignStatus(ctx, req.ID, req.Status)
+		return CampaignResponse{Campaign: c, Err: err}, nil
+	}
+}
+
+// MakeAuditLogEndpoint constructs an AuditLog endpoint wrapping the service.
+func MakeAuditLogEndpoint(s myservice.AdminService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		entries, err := s.AuditLog(ctx, request.(myservice.AuditRequest))
+		return AuditLogResponse{Entries: entries, Err: err}, nil
+	}
+}
+
+// SetBannerStatusRequest collects the request parameters for the SetBannerStatus method.
+type SetBannerStatusRequest struct {
+	ID      int
+	Version int
+	Active  bool
+}
+
+// SetCampaignStatusRequest collects the request parameters for the SetCampaignStatus method.
+type SetCampaignStatusRequest struct {
+	ID     int
+	Status string
+}
+
+// GetRequest collects the request parameters for methods reading one row.
+type GetRequest struct {
+	ID int
+}
+
+// DeleteRequest collects the request parameters for the Delete methods.
+type DeleteRequest struct {
+	ID      int
+	Version int
+}
+
+// BannerResponse collects the response values for methods changing a banner.
+type BannerResponse struct {
+	Banner models.Banner `json:"banner"`
+	Err    error         `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// GroupResponse collects the response values for methods returning a banner group.
+type GroupResponse struct {
+	Group models.BannerGroup `json:"group"`
+	Err   error              `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// ClientResponse collects the response values for methods returning a client.
+type ClientResponse struct {
+	Client models.Client `json:"client"`
+	Err    error         `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// CampaignResponse collects the response values for methods returning a campaign.
+type CampaignResponse struct {
+	Campaign models.Campaign `json:"campaign"`
+	Err      error           `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// AuditLogResponse collects the response values for the AuditLog method.
+type AuditLogResponse struct {
+	Entries []models.AuditEntry `json:"entries"`
+	Err     error               `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// DeleteResponse collects the response values for the Delete methods.
+type DeleteResponse struct {
+	Err error `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// compile time assertions for our response types implementing Failer.
+var (
+	_ Failer = BannerResponse{}
+	_ Failer = GroupResponse{}
+	_ Failer = ClientResponse{}
+	_ Failer = CampaignResponse{}
+	_ Failer = AuditLogResponse{}
+	_ Failer = DeleteResponse{}
+)
+
+// Failed implements Failer.
+func (r BannerResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r GroupResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r ClientResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r CampaignResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r AuditLogResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r DeleteResponse) Failed() error { return r.Err }
@@ -8,18 +8,21 @@ import (
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
+
+	"jf/adservice/pkg/myservice"
 )
 
 //InstrumentingMiddleware returns an endpoint middleware that records
 // the duration of each invocation to passed histogram. The middleware adds
-// a single field: "success", which is "true" if no error is returned, and
-// "false" otherwise
+// a single field: "success", which is "true" if neither an error nor a
+// failed response is returned, and "false" otherwise
 func InstrumentingMiddleware(duration metrics.Histogram) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 
 			defer func(begin time.Time) {
-				duration.With("success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+				success := err == nil && failed(response) == nil
+				duration.With("success", fmt.Sprint(success)).Observe(time.Since(begin).Seconds())
 			}(time.Now())
 			return next(ctx, request)
 		}
@@ -27,14 +30,38 @@ func InstrumentingMiddleware(duration metrics.Histogram) endpoint.Middleware {
 }
 
 // LoggingMiddleware returns an endpoint middleware that logs the
-// duration of each invocation, and the resulting error, if any.
+// duration of each invocation, the resulting error, if any, and the
+// business error of a failed response, if any.
 func LoggingMiddleware(logger log.Logger) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 			defer func(begin time.Time) {
-				logger.Log("transport_error", err, "took", time.Since(begin))
+				logger.Log("transport_error", err, "error", failed(response), "took", time.Since(begin))
 			}(time.Now())
 			return next(ctx, request)
 		}
 	}
 }
+
+// failed returns the business error of response if it is a Failer.
+func failed(response interface{}) error {
+	if f, ok := response.(Failer); ok {
+		return f.Failed()
+	}
+	return nil
+}
+
+// AuthMiddleware returns an endpoint middleware that only lets requests
+// through whose token, stored in the context by the transport, belongs to
+// an actor. The actor is added to the context for the service to audit.
+func AuthMiddleware(auth *myservice.Authenticator) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			actor, ok := auth.Actor(myservice.TokenFromContext(ctx))
+			if !ok {
+				return nil, myservice.ErrUnauthorized
+			}
+			return next(myservice.WithActor(ctx, actor), request)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package myendpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myservice"
+)
+
+// labelHistogram records the label values of every observation.
+type labelHistogram struct {
+	labels   []string
+	observed *[][]string
+}
+
+func (h labelHistogram) With(labelValues ...string) metrics.Histogram {
+	return labelHistogram{append(append([]string{}, h.labels...), labelValues...), h.observed}
+}
+
+func (h labelHistogram) Observe(value float64) {
+	*h.observed = append(*h.observed, h.labels)
+}
+
+func TestMiddlewaresSeeFailedResponses(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	var observed [][]string
+	var logged []interface{}
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		logged = keyvals
+		return nil
+	})
+	set := New(myservice.NewService(store), logger, labelHistogram{observed: &observed})
+
+	if _, err := set.GetBannerEndpoint(context.Background(), GetBannerRequest{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.GetBannerEndpoint(context.Background(), GetBannerRequest{ID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if len(observed) != 2 || observed[0][3] != "true" || observed[1][3] != "false" {
+		t.Errorf("want success true then false, got %v", observed)
+	}
+	var loggedErr interface{}
+	for i := 0; i+1 < len(logged); i += 2 {
+		if logged[i] == "error" {
+			loggedErr = logged[i+1]
+		}
+	}
+	if loggedErr != myservice.ErrNotFound {
+		t.Errorf("want the NotFound error logged, got %v", logged)
+	}
+}
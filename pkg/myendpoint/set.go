@@ -1,24 +1,327 @@
 package myendpoint
 
 import (
+	"context"
+
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myservice"
 )
 
 // Set collects all endpoints that compose an ad service. It's meant to
 // be used as a helper struct, to collect all of the endpoints into a single
 // parameter.
 type Set struct {
-	GetAdEndpoint endpoint.Endpoint
+	GetBannersEndpoint      endpoint.Endpoint
+	GetBannerEndpoint       endpoint.Endpoint
+	RecordClickEndpoint     endpoint.Endpoint
+	HeartbeatEndpoint       endpoint.Endpoint
+	PacingEndpoint          endpoint.Endpoint
+	GetBannersBatchEndpoint endpoint.Endpoint
+	AdTagEndpoint           endpoint.Endpoint
 }
 
+// Set is also usable as a client of the ad service.
+var _ myservice.AdService = Set{}
+
 // New returned a Set that wraps the provided server, and wires in all of the
 // expected endpoint middlewares via the various parameters.
-func New(svc myservice.Service, logger log.Logger, duration metrics.Histogram, trace stdopentracing.Tracer) Set {
-	var getAdEndpoint endpoint.Endpoint
+func New(svc myservice.AdService, logger log.Logger, duration metrics.Histogram) Set {
+	var getBannersEndpoint endpoint.Endpoint
+	{
+		getBannersEndpoint = MakeGetBannersEndpoint(svc)
+		getBannersEndpoint = LoggingMiddleware(log.With(logger, "method", "GetBanners"))(getBannersEndpoint)
+		getBannersEndpoint = InstrumentingMiddleware(duration.With("method", "GetBanners"))(getBannersEndpoint)
+	}
+	var getBannerEndpoint endpoint.Endpoint
+	{
+		getBannerEndpoint = MakeGetBannerEndpoint(svc)
+		getBannerEndpoint = LoggingMiddleware(log.With(logger, "method", "GetBanner"))(getBannerEndpoint)
+		getBannerEndpoint = InstrumentingMiddleware(duration.With("method", "GetBanner"))(getBannerEndpoint)
+	}
+	var recordClickEndpoint endpoint.Endpoint
+	{
+		recordClickEndpoint = MakeRecordClickEndpoint(svc)
+		recordClickEndpoint = LoggingMiddleware(log.With(logger, "method", "RecordClick"))(recordClickEndpoint)
+		recordClickEndpoint = InstrumentingMiddleware(duration.With("method", "RecordClick"))(recordClickEndpoint)
+	}
+	var heartbeatEndpoint endpoint.Endpoint
 	{
+		// Heartbeats arrive every few seconds per impression, too many to log.
+		heartbeatEndpoint = MakeHeartbeatEndpoint(svc)
+		heartbeatEndpoint = InstrumentingMiddleware(duration.With("method", "Heartbeat"))(heartbeatEndpoint)
+	}
+	var pacingEndpoint endpoint.Endpoint
+	{
+		pacingEndpoint = MakePacingEndpoint(svc)
+		pacingEndpoint = LoggingMiddleware(log.With(logger, "method", "Pacing"))(pacingEndpoint)
+		pacingEndpoint = InstrumentingMiddleware(duration.With("method", "Pacing"))(pacingEndpoint)
+	}
+	var getBannersBatchEndpoint endpoint.Endpoint
+	{
+		getBannersBatchEndpoint = MakeGetBannersBatchEndpoint(svc)
+		getBannersBatchEndpoint = LoggingMiddleware(log.With(logger, "method", "GetBannersBatch"))(getBannersBatchEndpoint)
+		getBannersBatchEndpoint = InstrumentingMiddleware(duration.With("method", "GetBannersBatch"))(getBannersBatchEndpoint)
+	}
+	var adTagEndpoint endpoint.Endpoint
+	{
+		adTagEndpoint = MakeAdTagEndpoint(svc)
+		adTagEndpoint = LoggingMiddleware(log.With(logger, "method", "AdTag"))(adTagEndpoint)
+		adTagEndpoint = InstrumentingMiddleware(duration.With("method", "AdTag"))(adTagEndpoint)
+	}
+	return Set{
+		GetBannersEndpoint:      getBannersEndpoint,
+		GetBannerEndpoint:       getBannerEndpoint,
+		RecordClickEndpoint:     recordClickEndpoint,
+		HeartbeatEndpoint:       heartbeatEndpoint,
+		PacingEndpoint:          pacingEndpoint,
+		GetBannersBatchEndpoint: getBannersBatchEndpoint,
+		AdTagEndpoint:           adTagEndpoint,
+	}
+}
+
+// GetBanners implements the service interface, so Set may be used as a
+// service. This is primarily useful in the context of a client library.
+func (s Set) GetBanners(ctx context.Context, req myservice.BannerRequest) ([]myservice.Ad, error) {
+	resp, err := s.GetBannersEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(GetBannersResponse)
+	return response.Banners, response.Err
+}
+
+// GetBanner implements the service interface, so Set may be used as a
+// service. This is primarily useful in the context of a client library.
+func (s Set) GetBanner(ctx context.Context, id int) (models.Banner, error) {
+	resp, err := s.GetBannerEndpoint(ctx, GetBannerRequest{ID: id})
+	if err != nil {
+		return models.Banner{}, err
+	}
+	response := resp.(GetBannerResponse)
+	return response.Banner, response.Err
+}
+
+// RecordClick implements the service interface, so Set may be used as a
+// service. This is primarily useful in the context of a client library.
+func (s Set) RecordClick(ctx context.Context, token string) (string, error) {
+	resp, err := s.RecordClickEndpoint(ctx, RecordClickRequest{Token: token})
+	if err != nil {
+		return "", err
+	}
+	response := resp.(RecordClickResponse)
+	return response.URL, response.Err
+}
+
+// Heartbeat implements the service interface, so Set may be used as a
+// service. This is primarily useful in the context of a client library.
+func (s Set) Heartbeat(ctx context.Context, req myservice.HeartbeatRequest) error {
+	resp, err := s.HeartbeatEndpoint(ctx, req)
+	if err != nil {
+		return err
+	}
+	return resp.(HeartbeatResponse).Err
+}
+
+// Pacing implements the service interface, so Set may be used as a
+// service. This is primarily useful in the context of a client library.
+func (s Set) Pacing(ctx context.Context) ([]myservice.PacingState, error) {
+	resp, err := s.PacingEndpoint(ctx, PacingRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(PacingResponse)
+	return response.Banners, response.Err
+}
+
+// GetBannersBatch implements the service interface, so Set may be used as
+// a service. This is primarily useful in the context of a client library.
+func (s Set) GetBannersBatch(ctx context.Context, req myservice.BannersRequest) ([]myservice.SlotAds, error) {
+	resp, err := s.GetBannersBatchEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(GetBannersBatchResponse)
+	return response.Slots, response.Err
+}
+
+// AdTag implements the service interface, so Set may be used as a service.
+// This is primarily useful in the context of a client library.
+func (s Set) AdTag(ctx context.Context, clientID int) (myservice.AdTag, error) {
+	resp, err := s.AdTagEndpoint(ctx, AdTagRequest{ClientID: clientID})
+	if err != nil {
+		return myservice.AdTag{}, err
+	}
+	response := resp.(AdTagResponse)
+	return response.AdTag, response.Err
+}
+
+// MakeGetBannersEndpoint constructs a GetBanners endpoint wrapping the service.
+func MakeGetBannersEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(myservice.BannerRequest)
+		ads, err := s.GetBanners(ctx, req)
+		resp := GetBannersResponse{Banners: ads, Err: err}
+		if len(ads) > 0 {
+			resp.Locale = ads[0].Locale
+		}
+		return resp, nil
+	}
+}
+
+// MakeGetBannerEndpoint constructs a GetBanner endpoint wrapping the service.
+func MakeGetBannerEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GetBannerRequest)
+		banner, err := s.GetBanner(ctx, req.ID)
+		return GetBannerResponse{Banner: banner, Err: err}, nil
+	}
+}
 
+// MakeRecordClickEndpoint constructs a RecordClick endpoint wrapping the service.
+func MakeRecordClickEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(RecordClickRequest)
+		url, err := s.RecordClick(ctx, req.Token)
+		return RecordClickResponse{URL: url, Err: err}, nil
 	}
-	return Set{GetAdEndpoint: getAdEndpoint}
 }
+
+// MakeHeartbeatEndpoint constructs a Heartbeat endpoint wrapping the service.
+func MakeHeartbeatEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(myservice.HeartbeatRequest)
+		return HeartbeatResponse{Err: s.Heartbeat(ctx, req)}, nil
+	}
+}
+
+// MakePacingEndpoint constructs a Pacing endpoint wrapping the service.
+func MakePacingEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		states, err := s.Pacing(ctx)
+		return PacingResponse{Banners: states, Err: err}, nil
+	}
+}
+
+// MakeGetBannersBatchEndpoint constructs a GetBannersBatch endpoint wrapping
+// the service.
+func MakeGetBannersBatchEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(myservice.BannersRequest)
+		slots, err := s.GetBannersBatch(ctx, req)
+		return GetBannersBatchResponse{Slots: slots, Err: err}, nil
+	}
+}
+
+// MakeAdTagEndpoint constructs an AdTag endpoint wrapping the service.
+func MakeAdTagEndpoint(s myservice.AdService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(AdTagRequest)
+		tag, err := s.AdTag(ctx, req.ClientID)
+		return AdTagResponse{AdTag: tag, Err: err}, nil
+	}
+}
+
+// GetBannersResponse collects the response values for the GetBanners method.
+type GetBannersResponse struct {
+	Banners []myservice.Ad `json:"banners"`
+	// Locale is the language the banners were chosen for.
+	Locale string `json:"locale,omitempty"`
+	Err    error  `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// GetBannerRequest collects the request parameters for the GetBanner method.
+type GetBannerRequest struct {
+	ID int
+}
+
+// GetBannerResponse collects the response values for the GetBanner method.
+type GetBannerResponse struct {
+	Banner models.Banner `json:"banner"`
+	Err    error         `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// RecordClickRequest collects the request parameters for the RecordClick method.
+type RecordClickRequest struct {
+	Token string
+}
+
+// RecordClickResponse collects the response values for the RecordClick method.
+type RecordClickResponse struct {
+	URL string `json:"url"`
+	Err error  `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// HeartbeatResponse collects the response values for the Heartbeat method.
+type HeartbeatResponse struct {
+	Err error `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// PacingRequest collects the request parameters for the Pacing method.
+type PacingRequest struct{}
+
+// PacingResponse collects the response values for the Pacing method.
+type PacingResponse struct {
+	Banners []myservice.PacingState `json:"banners"`
+	Err     error                   `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// GetBannersBatchResponse collects the response values for the
+// GetBannersBatch method.
+type GetBannersBatchResponse struct {
+	Slots []myservice.SlotAds `json:"slots"`
+	Err   error               `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// AdTagRequest collects the request parameters for the AdTag method.
+type AdTagRequest struct {
+	ClientID int
+}
+
+// AdTagResponse collects the response values for the AdTag method.
+type AdTagResponse struct {
+	AdTag myservice.AdTag `json:"ad_tag"`
+	Err   error           `json:"-"` // should be intercepted by the transport error encoder
+}
+
+// Failer may be implemented by response types that carry a business logic
+// error. If Failed returns a non-nil error, transports encode it as an error
+// of its myerror.Code rather than as a successful response.
+type Failer interface {
+	Failed() error
+}
+
+// compile time assertions for our response types implementing Failer.
+var (
+	_ Failer = GetBannersResponse{}
+	_ Failer = GetBannerResponse{}
+	_ Failer = RecordClickResponse{}
+	_ Failer = HeartbeatResponse{}
+	_ Failer = PacingResponse{}
+	_ Failer = GetBannersBatchResponse{}
+	_ Failer = AdTagResponse{}
+)
+
+// Failed implements Failer.
+func (r GetBannersResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r GetBannerResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r RecordClickResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r HeartbeatResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r PacingResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r GetBannersBatchResponse) Failed() error { return r.Err }
+
+// Failed implements Failer.
+func (r AdTagResponse) Failed() error { return r.Err }
@@ -0,0 +1,31 @@
+package myendpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myservice"
+)
+
+func TestSetAsService(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	duration := generic.NewHistogram("duration", 10)
+	var svc myservice.AdService = New(myservice.NewService(store), log.NewNopLogger(), duration)
+
+	banners, err := svc.GetBanners(context.Background(), myservice.BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil || len(banners) != 1 {
+		t.Fatalf("want 1 banner, got %v (%v)", banners, err)
+	}
+	if _, err := svc.GetBanner(context.Background(), 42); err != myservice.ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := svc.GetBanners(context.Background(), myservice.BannerRequest{Size: "40*50"}); err != myservice.ErrInvalidClient {
+		t.Errorf("want ErrInvalidClient, got %v", err)
+	}
+}
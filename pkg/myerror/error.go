@@ -0,0 +1,106 @@
+//Package myerror classifies the errors of the ad service, so that every
+//transport reports the same failure with the same status
+package myerror
+
+//Code is the kind of failure an error reports
+type Code int
+
+//Codes of errors, the zero Code is an unexpected internal failure
+const (
+	Internal Code = iota
+	//InvalidArgument is a request the service can never satisfy as sent
+	InvalidArgument
+	NotFound
+	//Conflict is a request at odds with the current state, like a stale version
+	Conflict
+	//Expired is a request whose credentials, like a click token, ran out
+	Expired
+	Unauthorized
+	RateLimited
+	//Unavailable is a dependency like the database failing, retrying may help
+	Unavailable
+)
+
+var codeNames = [...]string{
+	Internal:        "internal",
+	InvalidArgument: "invalid_argument",
+	NotFound:        "not_found",
+	Conflict:        "conflict",
+	Expired:         "expired",
+	Unauthorized:    "unauthorized",
+	RateLimited:     "rate_limited",
+	Unavailable:     "unavailable",
+}
+
+//String returns the snake case name of c
+func (c Code) String() string {
+	if c < 0 || int(c) >= len(codeNames) {
+		return "internal"
+	}
+	return codeNames[c]
+}
+
+//Coder is implemented by errors that know their Code
+type Coder interface {
+	ErrorCode() Code
+}
+
+//Error is an error with a Code. Msg is meant for clients, Err is the cause
+//and only meant for logs.
+type Error struct {
+	Code Code
+	Msg  string
+	Err  error
+}
+
+//New returns an error with code and a message for clients
+func New(code Code, msg string) error {
+	return &Error{Code: code, Msg: msg}
+}
+
+//Wrap classifies err with code, errors that already have a Code are
+//returned as they are. Wrapping nil returns nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(Coder); ok {
+		return err
+	}
+	return &Error{Code: code, Msg: code.String(), Err: err}
+}
+
+//Error implements error
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+//ErrorCode implements Coder
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+//CodeOf returns the Code of err, Internal for errors without one
+func CodeOf(err error) Code {
+	if c, ok := err.(Coder); ok {
+		return c.ErrorCode()
+	}
+	return Internal
+}
+
+//Message returns the message of err that is safe to show to clients.
+//Internal and Unavailable errors only show their code, their causes may
+//name hosts, tables or queries.
+func Message(err error) string {
+	switch code := CodeOf(err); code {
+	case Internal, Unavailable:
+		return code.String()
+	}
+	if e, ok := err.(*Error); ok {
+		return e.Msg
+	}
+	return err.Error()
+}
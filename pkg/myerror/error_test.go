@@ -0,0 +1,46 @@
+package myerror
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("dial tcp 10.0.0.1:3306: connection refused")
+	err := Wrap(Unavailable, cause)
+	if CodeOf(err) != Unavailable || err.(*Error).Err != cause {
+		t.Errorf("want unavailable wrapping the cause, got %#v", err)
+	}
+	if err.Error() != "unavailable: "+cause.Error() {
+		t.Errorf("want the cause in the log message, got %q", err)
+	}
+	notFound := New(NotFound, "not found")
+	if Wrap(Unavailable, notFound) != notFound {
+		t.Error("want coded errors to keep their code")
+	}
+	if Wrap(Unavailable, nil) != nil {
+		t.Error("want nil for nil")
+	}
+	if CodeOf(cause) != Internal {
+		t.Errorf("want internal for plain errors, got %s", CodeOf(cause))
+	}
+}
+
+func TestMessage(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want string
+	}{
+		{New(InvalidArgument, "invalid size"), "invalid size"},
+		{Wrap(Unavailable, errors.New("table gw_adv_banner is locked")), "unavailable"},
+		{errors.New("runtime failure"), "internal"},
+		{New(Internal, "select from gw_adv_banner failed"), "internal"},
+	} {
+		if got := Message(c.err); got != c.want {
+			t.Errorf("%v: want %q, got %q", c.err, c.want, got)
+		}
+	}
+	if Code(42).String() != "internal" {
+		t.Errorf("want internal for unknown codes, got %s", Code(42))
+	}
+}
@@ -0,0 +1,597 @@
+package myservice
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+//AdminService changes banners, banner groups, clients and campaigns. Every
+//change names the version it was based on and fails with ErrVersionConflict
+//if the row moved on since. Changes are audited under the actor of the
+//context, with the fields they changed.
+type AdminService interface {
+	CreateBanner(ctx context.Context, b models.Banner) (models.Banner, error)
+	UpdateBanner(ctx context.Context, b models.Banner) (models.Banner, error)
+	//SetBannerStatus activates or deactivates a banner
+	SetBannerStatus(ctx context.Context, id, version int, active bool) (models.Banner, error)
+	DeleteBanner(ctx context.Context, id, version int) error
+
+	GetGroup(ctx context.Context, id int) (models.BannerGroup, error)
+	CreateGroup(ctx context.Context, g models.BannerGroup) (models.BannerGroup, error)
+	UpdateGroup(ctx context.Context, g models.BannerGroup) (models.BannerGroup, error)
+	//DeleteGroup refuses groups that still have active banners
+	DeleteGroup(ctx context.Context, id, version int) error
+
+	GetClient(ctx context.Context, id int) (models.Client, error)
+	CreateClient(ctx context.Context, c models.Client) (models.Client, error)
+	UpdateClient(ctx context.Context, c models.Client) (models.Client, error)
+	DeleteClient(ctx context.Context, id, version int) error
+
+	GetCampaign(ctx context.Context, id int) (models.Campaign, error)
+	//CreateCampaign creates a draft, UpdateCampaign changes its settings
+	CreateCampaign(ctx context.Context, c models.Campaign) (models.Campaign, error)
+	UpdateCampaign(ctx context.Context, c models.Campaign) (models.Campaign, error)
+	//SetCampaignStatus moves a campaign to active, paused or finished by hand
+	SetCampaignStatus(ctx context.Context, id int, status string) (models.Campaign, error)
+
+	//AuditLog returns the recorded changes selected by req, newest first
+	AuditLog(ctx context.Context, req AuditRequest) ([]models.AuditEntry, error)
+}
+
+//AuditRequest selects audit entries, zero fields match everything
+type AuditRequest struct {
+	//Entity is banner, group, client or campaign
+	Entity   string `p:"entity"`
+	EntityID int    `p:"entity_id"`
+	//From and To bound the date of entries in unix seconds, both inclusive
+	From int `p:"from"`
+	To   int `p:"to"`
+	//Limit caps the entries returned, at most and by default maxAuditEntries
+	Limit int `p:"limit"`
+}
+
+//maxAuditEntries caps the entries AuditLog returns
+const maxAuditEntries = 1000
+
+var (
+	//ErrVersionConflict is returned when a change names an outdated version
+	ErrVersionConflict = myerror.New(myerror.Conflict, "version conflict")
+	//ErrGroupInUse is returned when deleting a group that banners, clients
+	//or websites are still assigned to
+	ErrGroupInUse = myerror.New(myerror.Conflict, "group is in use")
+	//ErrInvalidTransition is returned for campaign state changes the lifecycle does not allow
+	ErrInvalidTransition = myerror.New(myerror.Conflict, "invalid campaign transition")
+)
+
+//ValidationError rejects a field of an admin request
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return "invalid " + e.Field + ": " + e.Reason
+}
+
+//ErrorCode implements myerror.Coder
+func (e ValidationError) ErrorCode() myerror.Code {
+	return myerror.InvalidArgument
+}
+
+//Invalidator is told about the rows read by AdService that changed,
+//BannerCache is one
+type Invalidator interface {
+	//Invalidate is told about banner groups whose banners changed
+	Invalidate(groupID int)
+	InvalidateClient(clientID int)
+	InvalidateCampaign(campaignID int)
+}
+
+//AdminOption configures the service returned by NewAdminService
+type AdminOption func(*adminService)
+
+//WithInvalidator reports changed banner groups, clients and campaigns to inv
+func WithInvalidator(inv Invalidator) AdminOption {
+	return func(s *adminService) {
+		s.invalidator = inv
+	}
+}
+
+//WithAdminClock makes the admin service read the time from now, by default time.Now
+func WithAdminClock(now func() time.Time) AdminOption {
+	return func(s *adminService) {
+		s.now = now
+	}
+}
+
+//NewAdminService returns an AdminService changing store
+func NewAdminService(store models.Store, options ...AdminOption) AdminService {
+	s := adminService{store: store}
+	for _, option := range options {
+		option(&s)
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	return s
+}
+
+type adminService struct {
+	store       models.Store
+	invalidator Invalidator
+	now         func() time.Time
+}
+
+//CreateBanner implements AdminService
+func (s adminService) CreateBanner(ctx context.Context, b models.Banner) (models.Banner, error) {
+	if err := s.validateBanner(ctx, &b); err != nil {
+		return b, err
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.InsertBanner(ctx, &b)
+		return auditChange{models.AuditBanner, b.ID, "create", b.Version, nil, b}, err
+	}); err != nil {
+		return b, err
+	}
+	s.invalidate(b.GroupID)
+	return b, nil
+}
+
+//UpdateBanner implements AdminService
+func (s adminService) UpdateBanner(ctx context.Context, b models.Banner) (models.Banner, error) {
+	if b.ID <= 0 {
+		return b, ErrInvalidBanner
+	}
+	if err := s.validateBanner(ctx, &b); err != nil {
+		return b, err
+	}
+	old, err := s.store.GetBannerByID(ctx, b.ID)
+	if err != nil {
+		return b, storeError(err)
+	}
+	if old.Version != b.Version {
+		return b, ErrVersionConflict
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.UpdateBanner(ctx, &b)
+		return auditChange{models.AuditBanner, b.ID, "update", b.Version, old, b}, err
+	}); err != nil {
+		return b, err
+	}
+	s.invalidate(old.GroupID)
+	if b.GroupID != old.GroupID {
+		s.invalidate(b.GroupID)
+	}
+	return b, nil
+}
+
+//SetBannerStatus implements AdminService
+func (s adminService) SetBannerStatus(ctx context.Context, id, version int, active bool) (models.Banner, error) {
+	if id <= 0 {
+		return models.Banner{}, ErrInvalidBanner
+	}
+	old, err := s.store.GetBannerByID(ctx, id)
+	if err != nil {
+		return old, storeError(err)
+	}
+	if old.Version != version {
+		return old, ErrVersionConflict
+	}
+	b := old
+	action := "deactivate"
+	b.Status = 0
+	if active {
+		b.Status, action = models.StatusActive, "activate"
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.UpdateBanner(ctx, &b)
+		return auditChange{models.AuditBanner, b.ID, action, b.Version, old, b}, err
+	}); err != nil {
+		return b, err
+	}
+	s.invalidate(b.GroupID)
+	return b, nil
+}
+
+//DeleteBanner implements AdminService
+func (s adminService) DeleteBanner(ctx context.Context, id, version int) error {
+	if id <= 0 {
+		return ErrInvalidBanner
+	}
+	old, err := s.store.GetBannerByID(ctx, id)
+	if err != nil {
+		return storeError(err)
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.RemoveBanner(ctx, id, version)
+		return auditChange{models.AuditBanner, id, "delete", version, old, nil}, err
+	}); err != nil {
+		return err
+	}
+	s.invalidate(old.GroupID)
+	return nil
+}
+
+//GetGroup implements AdminService
+func (s adminService) GetGroup(ctx context.Context, id int) (models.BannerGroup, error) {
+	g, err := s.store.GetBannerGroup(ctx, id)
+	return g, storeError(err)
+}
+
+//CreateGroup implements AdminService
+func (s adminService) CreateGroup(ctx context.Context, g models.BannerGroup) (models.BannerGroup, error) {
+	if err := validateName(g.Name); err != nil {
+		return g, err
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.InsertBannerGroup(ctx, &g)
+		return auditChange{models.AuditGroup, g.ID, "create", g.Version, nil, g}, err
+	}); err != nil {
+		return g, err
+	}
+	return g, nil
+}
+
+//UpdateGroup implements AdminService
+func (s adminService) UpdateGroup(ctx context.Context, g models.BannerGroup) (models.BannerGroup, error) {
+	if err := validateName(g.Name); err != nil {
+		return g, err
+	}
+	old, err := s.store.GetBannerGroup(ctx, g.ID)
+	if err != nil {
+		return g, storeError(err)
+	}
+	if old.Version != g.Version {
+		return g, ErrVersionConflict
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.UpdateBannerGroup(ctx, &g)
+		return auditChange{models.AuditGroup, g.ID, "update", g.Version, old, g}, err
+	}); err != nil {
+		return g, err
+	}
+	return g, nil
+}
+
+//DeleteGroup implements AdminService. Groups that banners of any status,
+//clients or websites are assigned to are not deleted.
+func (s adminService) DeleteGroup(ctx context.Context, id, version int) error {
+	old, err := s.store.GetBannerGroup(ctx, id)
+	if err != nil {
+		return storeError(err)
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		change := auditChange{models.AuditGroup, id, "delete", version, old, nil}
+		n, err := store.CountGroupReferences(ctx, id)
+		if err == nil && n > 0 {
+			err = models.ErrGroupInUse
+		}
+		if err != nil {
+			return change, err
+		}
+		return change, store.RemoveBannerGroup(ctx, id, version)
+	}); err != nil {
+		return err
+	}
+	s.invalidate(id)
+	return nil
+}
+
+//GetClient implements AdminService
+func (s adminService) GetClient(ctx context.Context, id int) (models.Client, error) {
+	c, err := s.store.GetClient(ctx, id)
+	return c, storeError(err)
+}
+
+//CreateClient implements AdminService
+func (s adminService) CreateClient(ctx context.Context, c models.Client) (models.Client, error) {
+	if err := s.validateClient(ctx, c); err != nil {
+		return c, err
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.InsertClient(ctx, &c)
+		return auditChange{models.AuditClient, c.ID, "create", c.Version, nil, c}, err
+	}); err != nil {
+		return c, err
+	}
+	s.invalidateClient(c.ID)
+	return c, nil
+}
+
+//UpdateClient implements AdminService
+func (s adminService) UpdateClient(ctx context.Context, c models.Client) (models.Client, error) {
+	if err := s.validateClient(ctx, c); err != nil {
+		return c, err
+	}
+	old, err := s.store.GetClient(ctx, c.ID)
+	if err != nil {
+		return c, storeError(err)
+	}
+	if old.Version != c.Version {
+		return c, ErrVersionConflict
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.UpdateClient(ctx, &c)
+		return auditChange{models.AuditClient, c.ID, "update", c.Version, old, c}, err
+	}); err != nil {
+		return c, err
+	}
+	s.invalidateClient(c.ID)
+	return c, nil
+}
+
+//DeleteClient implements AdminService
+func (s adminService) DeleteClient(ctx context.Context, id, version int) error {
+	old, err := s.store.GetClient(ctx, id)
+	if err != nil {
+		return storeError(err)
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.RemoveClient(ctx, id, version)
+		return auditChange{models.AuditClient, id, "delete", version, old, nil}, err
+	}); err != nil {
+		return err
+	}
+	s.invalidateClient(id)
+	return nil
+}
+
+//GetCampaign implements AdminService
+func (s adminService) GetCampaign(ctx context.Context, id int) (models.Campaign, error) {
+	c, err := s.store.GetCampaign(ctx, id)
+	return c, storeError(err)
+}
+
+//CreateCampaign implements AdminService
+func (s adminService) CreateCampaign(ctx context.Context, c models.Campaign) (models.Campaign, error) {
+	if c.Status == "" {
+		c.Status = models.CampaignDraft
+	}
+	if c.Status != models.CampaignDraft {
+		return c, ValidationError{"status", "new campaigns must be drafts"}
+	}
+	if err := validateCampaign(c); err != nil {
+		return c, err
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.InsertCampaign(ctx, &c)
+		return auditChange{models.AuditCampaign, c.ID, "create", c.Version, nil, c}, err
+	}); err != nil {
+		return c, err
+	}
+	s.invalidateCampaign(c.ID)
+	return c, nil
+}
+
+//UpdateCampaign implements AdminService
+func (s adminService) UpdateCampaign(ctx context.Context, c models.Campaign) (models.Campaign, error) {
+	if err := validateCampaign(c); err != nil {
+		return c, err
+	}
+	old, err := s.store.GetCampaign(ctx, c.ID)
+	if err != nil {
+		return c, storeError(err)
+	}
+	if old.Version != c.Version {
+		return c, ErrVersionConflict
+	}
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		err := store.UpdateCampaign(ctx, &c)
+		return auditChange{models.AuditCampaign, c.ID, "update", c.Version, campaignSettings(old), campaignSettings(c)}, err
+	}); err != nil {
+		return c, err
+	}
+	s.invalidateCampaign(c.ID)
+	return c, nil
+}
+
+//campaignActions names the audit action of moving a campaign to a state by hand
+var campaignActions = map[string]string{
+	models.CampaignActive:   "activate",
+	models.CampaignPaused:   "pause",
+	models.CampaignFinished: "finish",
+}
+
+//SetCampaignStatus implements AdminService
+func (s adminService) SetCampaignStatus(ctx context.Context, id int, status string) (models.Campaign, error) {
+	c, err := s.store.GetCampaign(ctx, id)
+	if err != nil {
+		return c, storeError(err)
+	}
+	if !models.CanTransition(c.Status, status) {
+		return c, ErrInvalidTransition
+	}
+	old := c
+	c.Status = status
+	if err := s.audited(ctx, func(store models.Store) (auditChange, error) {
+		ok, err := store.SetCampaignStatus(ctx, id, old.Status, status)
+		if err == nil && !ok {
+			err = models.ErrVersionConflict
+		}
+		return auditChange{models.AuditCampaign, id, campaignActions[status], c.Version, campaignSettings(old), campaignSettings(c)}, err
+	}); err != nil {
+		return old, err
+	}
+	s.invalidateCampaign(id)
+	return c, nil
+}
+
+//AuditLog implements AdminService
+func (s adminService) AuditLog(ctx context.Context, req AuditRequest) ([]models.AuditEntry, error) {
+	switch req.Entity {
+	case "", models.AuditBanner, models.AuditGroup, models.AuditClient, models.AuditCampaign:
+	default:
+		return nil, ValidationError{"entity", "must be banner, group, client or campaign"}
+	}
+	if req.From < 0 || req.To < 0 || (req.To > 0 && req.To < req.From) {
+		return nil, ValidationError{"to", "must not be before from"}
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxAuditEntries {
+		limit = maxAuditEntries
+	}
+	entries, err := s.store.QueryAuditEntries(ctx, models.AuditQuery{
+		Entity:   req.Entity,
+		EntityID: req.EntityID,
+		From:     req.From,
+		To:       req.To,
+		Limit:    limit,
+	})
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	return entries, storeError(err)
+}
+
+//validateBanner checks the fields of b and normalizes its size
+func (s adminService) validateBanner(ctx context.Context, b *models.Banner) error {
+	if err := validateName(b.Name); err != nil {
+		return err
+	}
+	u, err := url.Parse(b.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationError{"url", "must be an absolute http or https URL"}
+	}
+	size, err := models.ParseSize(b.Size)
+	if err != nil {
+		return ValidationError{"size", "must be like 300x250 or an IAB unit name"}
+	}
+	b.Size = size.String()
+	if b.Language != "" && !ValidLanguageTag(b.Language) {
+		return ValidationError{"language", "must be a language tag like en or zh-TW"}
+	}
+	if b.Status != 0 && b.Status != models.StatusActive {
+		return ValidationError{"status", "must be 0 or 1"}
+	}
+	if b.Weight < 0 {
+		return ValidationError{"weight", "must not be negative"}
+	}
+	if b.DailyTarget < 0 {
+		return ValidationError{"daily_target", "must not be negative"}
+	}
+	if err := b.Schedule.Validate(); err != nil {
+		return ValidationError{"schedule", err.(models.ScheduleError).Reason}
+	}
+	if err := s.checkGroup(ctx, b.GroupID); err != nil {
+		return err
+	}
+	if b.CampaignID != 0 {
+		if _, err := s.store.GetCampaign(ctx, b.CampaignID); err == models.ErrNotFound {
+			return ValidationError{"campaign_id", "no such campaign"}
+		} else if err != nil {
+			return storeError(err)
+		}
+	}
+	return nil
+}
+
+func (s adminService) validateClient(ctx context.Context, c models.Client) error {
+	if strings.TrimSpace(c.ClientName) == "" {
+		return ValidationError{"client_name", "must not be empty"}
+	}
+	if c.GroupID == 0 {
+		return nil
+	}
+	return s.checkGroup(ctx, c.GroupID)
+}
+
+//checkGroup rejects group ids that do not name an existing group
+func (s adminService) checkGroup(ctx context.Context, id int) error {
+	if id <= 0 {
+		return ValidationError{"group_id", "must be positive"}
+	}
+	_, err := s.store.GetBannerGroup(ctx, id)
+	if err == models.ErrNotFound {
+		return ValidationError{"group_id", "no such group"}
+	}
+	return storeError(err)
+}
+
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ValidationError{"name", "must not be empty"}
+	}
+	return nil
+}
+
+func validateCampaign(c models.Campaign) error {
+	if err := validateName(c.Name); err != nil {
+		return err
+	}
+	if c.Start < 0 || c.End < 0 || (c.End > 0 && c.End <= c.Start) {
+		return ValidationError{"end", "must be after start"}
+	}
+	if c.DailyBudget < 0 {
+		return ValidationError{"daily_budget", "must not be negative"}
+	}
+	if c.TotalBudget < 0 {
+		return ValidationError{"total_budget", "must not be negative"}
+	}
+	if err := c.Schedule.Validate(); err != nil {
+		return ValidationError{"schedule", err.(models.ScheduleError).Reason}
+	}
+	return nil
+}
+
+//campaignSettings drops the impression counters of c, which change with
+//every impression and would clutter its audit diffs
+func campaignSettings(c models.Campaign) models.Campaign {
+	c.Served, c.ServedToday, c.Day = 0, 0, 0
+	return c
+}
+
+//auditChange is a change to audit: the entity and id of the row, the action,
+//the version of the row after it, and the row before and after. before is
+//nil for created rows, after for deleted ones.
+type auditChange struct {
+	entity        string
+	id            int
+	action        string
+	version       int
+	before, after interface{}
+}
+
+//audited runs write and records the change it returns for the actor of
+//ctx in one store transaction, with the fields that differ between before
+//and after. Either both are stored or neither is.
+func (s adminService) audited(ctx context.Context, write func(store models.Store) (auditChange, error)) error {
+	return storeError(s.store.Audited(ctx, func(store models.Store) (models.AuditEntry, error) {
+		c, err := write(store)
+		if err != nil {
+			return models.AuditEntry{}, err
+		}
+		changes, err := models.Diff(c.before, c.after)
+		return models.AuditEntry{
+			Actor:    ActorFromContext(ctx),
+			Entity:   c.entity,
+			EntityID: c.id,
+			Action:   c.action,
+			Version:  c.version,
+			Changes:  changes,
+			Date:     int(s.now().Unix()),
+		}, err
+	}))
+}
+
+func (s adminService) invalidate(groupID int) {
+	if s.invalidator != nil {
+		s.invalidator.Invalidate(groupID)
+	}
+}
+
+func (s adminService) invalidateClient(clientID int) {
+	if s.invalidator != nil {
+		s.invalidator.InvalidateClient(clientID)
+	}
+}
+
+func (s adminService) invalidateCampaign(campaignID int) {
+	if s.invalidator != nil {
+		s.invalidator.InvalidateCampaign(campaignID)
+	}
+}
@@ -0,0 +1,250 @@
+package myservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"jf/adservice/models"
+)
+
+type invalidations struct {
+	groups, clients, campaigns []int
+}
+
+func (i *invalidations) Invalidate(groupID int)        { i.groups = append(i.groups, groupID) }
+func (i *invalidations) InvalidateClient(clientID int) { i.clients = append(i.clients, clientID) }
+func (i *invalidations) InvalidateCampaign(campaignID int) {
+	i.campaigns = append(i.campaigns, campaignID)
+}
+
+func newTestAdmin(t *testing.T) (AdminService, *models.MemoryStore, *invalidations, models.BannerGroup) {
+	store := models.NewMemoryStore()
+	inv := &invalidations{}
+	svc := NewAdminService(store, WithInvalidator(inv), WithAdminClock(func() time.Time { return time.Unix(1000, 0) }))
+	g, err := svc.CreateGroup(context.Background(), models.BannerGroup{Name: "home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, store, inv, g
+}
+
+func TestAdminBannerLifecycle(t *testing.T) {
+	svc, store, inv, g := newTestAdmin(t)
+	ctx := WithActor(context.Background(), "ops")
+	b, err := svc.CreateBanner(ctx, models.Banner{GroupID: g.ID, Name: "a", Size: "300x250", URL: "https://a.example", Language: "zh-TW"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Size != "300*250" || b.Version != 1 {
+		t.Errorf("want normalized size and version 1, got %+v", b)
+	}
+	b, err = svc.SetBannerStatus(ctx, b.ID, b.Version, true)
+	if err != nil || b.Status != models.StatusActive || b.Version != 2 {
+		t.Fatalf("activate: %+v (%v)", b, err)
+	}
+	if _, err := svc.SetBannerStatus(ctx, b.ID, 1, false); err != ErrVersionConflict {
+		t.Errorf("want ErrVersionConflict, got %v", err)
+	}
+	if err := svc.DeleteGroup(ctx, g.ID, g.Version); err != ErrGroupInUse {
+		t.Errorf("want ErrGroupInUse, got %v", err)
+	}
+	if err := svc.DeleteBanner(ctx, b.ID, b.Version); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteBanner(ctx, b.ID, b.Version); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if len(inv.groups) != 3 {
+		t.Errorf("want 3 invalidations, got %v", inv.groups)
+	}
+
+	entries := store.AuditEntries()
+	want := []string{"create", "create", "activate", "delete"}
+	if len(entries) != len(want) {
+		t.Fatalf("want %d audit entries, got %+v", len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Action != want[i] || e.Date != 1000 {
+			t.Errorf("entry %d: want %s, got %+v", i, want[i], e)
+		}
+	}
+	if last := entries[3]; last.Actor != "ops" || last.Entity != "banner" || last.EntityID != b.ID || last.Version != 2 {
+		t.Errorf("unexpected entry %+v", last)
+	}
+}
+
+func TestAdminDeleteGroupInUse(t *testing.T) {
+	svc, store, _, g := newTestAdmin(t)
+	ctx := context.Background()
+	b, err := svc.CreateBanner(ctx, models.Banner{GroupID: g.ID, Name: "a", Size: "300x250", URL: "https://a.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteGroup(ctx, g.ID, g.Version); err != ErrGroupInUse {
+		t.Errorf("want ErrGroupInUse for an inactive banner, got %v", err)
+	}
+	if err := svc.DeleteBanner(ctx, b.ID, b.Version); err != nil {
+		t.Fatal(err)
+	}
+	c, err := svc.CreateClient(ctx, models.Client{ClientName: "acme", GroupID: g.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteGroup(ctx, g.ID, g.Version); err != ErrGroupInUse {
+		t.Errorf("want ErrGroupInUse for a client, got %v", err)
+	}
+	if err := svc.DeleteClient(ctx, c.ID, c.Version); err != nil {
+		t.Fatal(err)
+	}
+	store.PutWebsite(models.Website{ID: 1, Domain: "news.example", ClientID: c.ID, GroupID: g.ID})
+	if err := svc.DeleteGroup(ctx, g.ID, g.Version); err != ErrGroupInUse {
+		t.Errorf("want ErrGroupInUse for a website, got %v", err)
+	}
+	store.PutWebsite(models.Website{ID: 1, Domain: "news.example", ClientID: c.ID})
+	if err := svc.DeleteGroup(ctx, g.ID, g.Version); err != nil {
+		t.Errorf("want the unused group deleted, got %v", err)
+	}
+	entries := store.AuditEntries()
+	if last := entries[len(entries)-1]; last.Entity != models.AuditGroup || last.Action != "delete" {
+		t.Errorf("want the group delete audited last, got %+v", last)
+	}
+}
+
+func TestAdminValidation(t *testing.T) {
+	svc, _, _, g := newTestAdmin(t)
+	ctx := context.Background()
+	valid := models.Banner{GroupID: g.ID, Name: "a", Size: "300*250", URL: "http://a.example"}
+	for field, change := range map[string]func(*models.Banner){
+		"name":     func(b *models.Banner) { b.Name = " " },
+		"url":      func(b *models.Banner) { b.URL = "/relative" },
+		"size":     func(b *models.Banner) { b.Size = "huge" },
+		"language": func(b *models.Banner) { b.Language = "e n" },
+		"status":   func(b *models.Banner) { b.Status = 2 },
+		"group_id": func(b *models.Banner) { b.GroupID = 99 },
+		"schedule": func(b *models.Banner) { b.Schedule = &models.Schedule{Timezone: "Nowhere/City"} },
+	} {
+		b := valid
+		change(&b)
+		_, err := svc.CreateBanner(ctx, b)
+		if ve, ok := err.(ValidationError); !ok || ve.Field != field {
+			t.Errorf("%s: want ValidationError, got %v", field, err)
+		}
+	}
+	if _, err := svc.CreateClient(ctx, models.Client{ClientName: "acme", GroupID: 99}); err == nil {
+		t.Error("want error for unknown group")
+	}
+}
+
+func TestAdminClientVersions(t *testing.T) {
+	svc, _, inv, g := newTestAdmin(t)
+	ctx := context.Background()
+	c, err := svc.CreateClient(ctx, models.Client{ClientName: "acme", GroupID: g.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := c
+	c.ClientName = "acme inc"
+	if c, err = svc.UpdateClient(ctx, c); err != nil || c.Version != 2 {
+		t.Fatalf("update: %+v (%v)", c, err)
+	}
+	if _, err := svc.UpdateClient(ctx, stale); err != ErrVersionConflict {
+		t.Errorf("want ErrVersionConflict, got %v", err)
+	}
+	if got, err := svc.GetClient(ctx, c.ID); err != nil || got != c {
+		t.Errorf("want %+v, got %+v (%v)", c, got, err)
+	}
+	if len(inv.clients) != 2 || inv.clients[1] != c.ID {
+		t.Errorf("want the client invalidated on create and update, got %v", inv.clients)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	auth := NewAuthenticator(map[string]string{"ops": "0123456789abcdef", "nobody": ""})
+	if actor, ok := auth.Actor("0123456789abcdef"); !ok || actor != "ops" {
+		t.Errorf("want ops, got %q", actor)
+	}
+	for _, token := range []string{"", "0123456789abcde", "wrong"} {
+		if _, ok := auth.Actor(token); ok {
+			t.Errorf("%q should not authenticate", token)
+		}
+	}
+}
+
+func TestAdminAuditDiffs(t *testing.T) {
+	svc, _, inv, g := newTestAdmin(t)
+	ctx := WithActor(context.Background(), "ops")
+	if _, err := svc.UpdateGroup(ctx, models.BannerGroup{ID: g.ID, Name: "landing", Version: g.Version}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := svc.CreateCampaign(ctx, models.Campaign{Name: "spring", DailyBudget: 100})
+	if err != nil || c.Status != models.CampaignDraft {
+		t.Fatalf("create campaign: %+v (%v)", c, err)
+	}
+	if _, err := svc.SetCampaignStatus(ctx, c.ID, models.CampaignPaused); err != ErrInvalidTransition {
+		t.Errorf("want ErrInvalidTransition, got %v", err)
+	}
+	if c, err = svc.SetCampaignStatus(ctx, c.ID, models.CampaignActive); err != nil || c.Status != models.CampaignActive {
+		t.Fatalf("activate campaign: %+v (%v)", c, err)
+	}
+	c.DailyBudget = 200
+	if c, err = svc.UpdateCampaign(ctx, c); err != nil || c.Version != 2 || c.Status != models.CampaignActive {
+		t.Fatalf("update campaign: %+v (%v)", c, err)
+	}
+	if len(inv.campaigns) != 3 {
+		t.Errorf("want the campaign invalidated on create, activate and update, got %v", inv.campaigns)
+	}
+
+	entries, err := svc.AuditLog(ctx, AuditRequest{Entity: models.AuditGroup, EntityID: g.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "update" {
+		t.Fatalf("want update and create of the group, got %+v", entries)
+	}
+	if ch := entries[0].Changes; len(ch) != 1 || ch[0].Field != "name" || string(ch[0].Before) != `"home"` || string(ch[0].After) != `"landing"` {
+		t.Errorf("unexpected group diff %+v", ch)
+	}
+	entries, err = svc.AuditLog(ctx, AuditRequest{Entity: models.AuditCampaign})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ action, field string }{{"update", "daily_budget"}, {"activate", "status"}}
+	for i, w := range want {
+		if e := entries[i]; e.Action != w.action || len(e.Changes) != 1 || e.Changes[0].Field != w.field {
+			t.Errorf("entry %d: want %s of %s, got %+v", i, w.action, w.field, e)
+		}
+	}
+	if _, err := svc.AuditLog(ctx, AuditRequest{Entity: "website"}); err == nil {
+		t.Error("want error for unknown entity")
+	}
+	if _, err := svc.AuditLog(ctx, AuditRequest{From: 2000, To: 1000}); err == nil {
+		t.Error("want error for inverted range")
+	}
+}
+
+func TestAdminScheduleEdits(t *testing.T) {
+	svc, store, inv, g := newTestAdmin(t)
+	ctx := WithActor(context.Background(), "ops")
+	b, err := svc.CreateBanner(ctx, models.Banner{GroupID: g.ID, Name: "a", Size: "300*250", URL: "http://a.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Schedule = &models.Schedule{Timezone: "Asia/Shanghai", Windows: []models.ScheduleWindow{{Start: 8, End: 20}}}
+	if b, err = svc.UpdateBanner(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := store.GetBannerByID(ctx, b.ID); stored.Schedule == nil || stored.Schedule.Timezone != "Asia/Shanghai" {
+		t.Errorf("want the schedule stored, got %+v", stored.Schedule)
+	}
+	if len(inv.groups) != 2 {
+		t.Errorf("want the group invalidated on create and schedule edit, got %v", inv.groups)
+	}
+	entries, err := svc.AuditLog(ctx, AuditRequest{Entity: models.AuditBanner, EntityID: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "update" || len(entries[0].Changes) != 1 || entries[0].Changes[0].Field != "schedule" {
+		t.Errorf("want the schedule edit audited, got %+v", entries)
+	}
+}
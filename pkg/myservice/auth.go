@@ -0,0 +1,39 @@
+package myservice
+
+import (
+	"crypto/subtle"
+
+	"jf/adservice/pkg/myerror"
+)
+
+//ErrUnauthorized is returned when a request carries no valid admin token
+var ErrUnauthorized = myerror.New(myerror.Unauthorized, "unauthorized")
+
+//Authenticator maps admin tokens to the actor they belong to
+type Authenticator struct {
+	tokens map[string]string
+}
+
+//NewAuthenticator returns an Authenticator for tokens keyed by actor name.
+//Empty tokens never authenticate.
+func NewAuthenticator(tokens map[string]string) *Authenticator {
+	a := &Authenticator{tokens: make(map[string]string, len(tokens))}
+	for actor, token := range tokens {
+		if token != "" {
+			a.tokens[actor] = token
+		}
+	}
+	return a
+}
+
+//Actor returns the actor token belongs to. Every token is compared in
+//constant time so the answer does not leak how much of a token matched.
+func (a *Authenticator) Actor(token string) (string, bool) {
+	found := ""
+	for actor, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			found = actor
+		}
+	}
+	return found, found != ""
+}
@@ -0,0 +1,468 @@
+package myservice
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"jf/adservice/models"
+)
+
+//CacheConfig sets how long a BannerCache keeps what it loaded
+type CacheConfig struct {
+	//TTL is how long loaded lookups are answered without asking the store
+	TTL time.Duration
+	//StaleTTL is how long past TTL lookups are still answered while they are
+	//being reloaded, or while the store fails
+	StaleTTL time.Duration
+	//RefreshInterval is how often lookups in use are reloaded in the background
+	RefreshInterval time.Duration
+}
+
+//CacheMetrics instruments a BannerCache, nil fields are discarded
+type CacheMetrics struct {
+	Hits   metrics.Counter
+	Misses metrics.Counter
+	//Stale counts lookups answered past their TTL
+	Stale metrics.Counter
+	//Errors counts failed loads
+	Errors metrics.Counter
+}
+
+//cacheKind names the store lookups a BannerCache answers
+type cacheKind string
+
+const (
+	bannerLookup   cacheKind = "banners"
+	clientLookup   cacheKind = "client"
+	websiteLookup  cacheKind = "website"
+	campaignLookup cacheKind = "campaign"
+)
+
+//cacheKey identifies a lookup: the banners of group id, of size name when it
+//is set, the group of client id, the website of domain name, or campaign id.
+//Languages are not part of the key: a group is loaded in every language and
+//the language chain is resolved on the cached banners.
+type cacheKey struct {
+	kind cacheKind
+	id   int
+	name string
+}
+
+//cacheResult is the answer of the store to a lookup. err is only ever
+//models.ErrNotFound, other errors are not cached.
+type cacheResult struct {
+	value interface{}
+	err   error
+}
+
+type cacheEntry struct {
+	cacheResult
+	loaded     time.Time
+	used       time.Time
+	refreshing bool
+	//dirty is set when the entry is invalidated while it is being
+	//refreshed, the running load may predate the change
+	dirty bool
+}
+
+//missLoad is a load of a key that missed the cache. stale is set when the
+//key is invalidated during the load, its result may then predate the change
+//and is not cached.
+type missLoad struct {
+	key   cacheKey
+	stale bool
+}
+
+//BannerCache is a models.Store that answers the lookups of the banner read
+//path from memory: banners, the groups of clients and websites, and
+//campaigns. Misses read through to the store. Lookups past their TTL are
+//still answered while a background reload is under way, and for as long as
+//StaleTTL when the store keeps failing. Lookups in use are also reloaded
+//every RefreshInterval, and the Invalidate methods reload them after they
+//changed; lookups unused for TTL+StaleTTL are dropped. Campaign impressions
+//and status changes written through the cache update the cached campaign.
+//Returned banners must not be modified.
+type BannerCache struct {
+	models.Store
+	logger  log.Logger
+	cfg     CacheConfig
+	metrics CacheMetrics
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+	misses  map[*missLoad]bool
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+//NewBannerCache starts a BannerCache in front of store
+func NewBannerCache(store models.Store, logger log.Logger, cfg CacheConfig, m CacheMetrics) *BannerCache {
+	c := newBannerCache(store, logger, cfg, m, time.Now)
+	go c.run()
+	return c
+}
+
+//newBannerCache returns a BannerCache without its refresh loop
+func newBannerCache(store models.Store, logger log.Logger, cfg CacheConfig, m CacheMetrics, now func() time.Time) *BannerCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * time.Second
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = cfg.TTL / 2
+	}
+	if m.Hits == nil {
+		m.Hits = discard.NewCounter()
+	}
+	if m.Misses == nil {
+		m.Misses = discard.NewCounter()
+	}
+	if m.Stale == nil {
+		m.Stale = discard.NewCounter()
+	}
+	if m.Errors == nil {
+		m.Errors = discard.NewCounter()
+	}
+	return &BannerCache{
+		Store:   store,
+		logger:  logger,
+		cfg:     cfg,
+		metrics: m,
+		now:     now,
+		entries: make(map[cacheKey]*cacheEntry),
+		misses:  make(map[*missLoad]bool),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+//GetBanners implements models.BannerStore
+func (c *BannerCache) GetBanners(ctx context.Context, size string, groupID int) ([]*models.Banner, error) {
+	v, err := c.get(ctx, cacheKey{kind: bannerLookup, id: groupID, name: size})
+	banners, _ := v.([]*models.Banner)
+	return banners, err
+}
+
+//GetBannersByGroup implements models.BannerStore
+func (c *BannerCache) GetBannersByGroup(ctx context.Context, groupID int) ([]*models.Banner, error) {
+	v, err := c.get(ctx, cacheKey{kind: bannerLookup, id: groupID})
+	banners, _ := v.([]*models.Banner)
+	return banners, err
+}
+
+//GetBannerGroupByClient implements models.BannerStore
+func (c *BannerCache) GetBannerGroupByClient(ctx context.Context, clientID int) (int, error) {
+	v, err := c.get(ctx, cacheKey{kind: clientLookup, id: clientID})
+	groupID, _ := v.(int)
+	return groupID, err
+}
+
+//GetWebsiteByDomain implements models.WebsiteStore
+func (c *BannerCache) GetWebsiteByDomain(ctx context.Context, domain string) (models.Website, error) {
+	v, err := c.get(ctx, cacheKey{kind: websiteLookup, name: models.NormalizeDomain(domain)})
+	website, _ := v.(models.Website)
+	return website, err
+}
+
+//GetCampaigns implements models.CampaignStore. Campaigns are cached one by
+//one, the missing ones are loaded together.
+func (c *BannerCache) GetCampaigns(ctx context.Context, ids []int) ([]models.Campaign, error) {
+	var campaigns []models.Campaign
+	var missing []int
+	var loads []*missLoad
+	c.mu.Lock()
+	now := c.now()
+	for _, id := range ids {
+		key := cacheKey{kind: campaignLookup, id: id}
+		r, ok := c.lookup(key, now)
+		if !ok {
+			missing = append(missing, id)
+			loads = append(loads, c.startMiss(key))
+			continue
+		}
+		if r.err == nil {
+			campaigns = append(campaigns, r.value.(models.Campaign))
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) > 0 {
+		c.metrics.Misses.Add(float64(len(missing)))
+		loaded, err := c.Store.GetCampaigns(ctx, missing)
+		c.mu.Lock()
+		if err != nil {
+			for _, l := range loads {
+				c.endMiss(l)
+			}
+			c.mu.Unlock()
+			c.metrics.Errors.Add(1)
+			return nil, err
+		}
+		results := make(map[int]cacheResult, len(loaded))
+		for _, campaign := range loaded {
+			results[campaign.ID] = cacheResult{value: campaign}
+		}
+		for _, l := range loads {
+			r, ok := results[l.key.id]
+			if !ok {
+				r = cacheResult{err: models.ErrNotFound}
+			}
+			if c.endMiss(l) {
+				c.put(l.key, r, now)
+			}
+		}
+		c.mu.Unlock()
+		campaigns = append(campaigns, loaded...)
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].ID < campaigns[j].ID })
+	return campaigns, nil
+}
+
+//AddCampaignImpressions implements models.CampaignStore, keeping the
+//cached campaign up to date with its counters
+func (c *BannerCache) AddCampaignImpressions(ctx context.Context, id, day, n int) (models.Campaign, error) {
+	campaign, err := c.Store.AddCampaignImpressions(ctx, id, day, n)
+	if err != nil {
+		return campaign, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[cacheKey{kind: campaignLookup, id: id}]; ok {
+		e.cacheResult, e.loaded = cacheResult{value: campaign}, c.now()
+	}
+	return campaign, nil
+}
+
+//SetCampaignStatus implements models.CampaignStore and reloads the cached
+//campaign, whether this or another instance moved it
+func (c *BannerCache) SetCampaignStatus(ctx context.Context, id int, from, to string) (bool, error) {
+	ok, err := c.Store.SetCampaignStatus(ctx, id, from, to)
+	if err == nil {
+		c.InvalidateCampaign(id)
+	}
+	return ok, err
+}
+
+//Invalidate reloads every cached lookup of the banners of groupID in the
+//background. Until the reload finishes the old banners are served as stale.
+func (c *BannerCache) Invalidate(groupID int) {
+	c.invalidate(bannerLookup, groupID)
+}
+
+//InvalidateClient reloads the cached group of clientID in the background
+func (c *BannerCache) InvalidateClient(clientID int) {
+	c.invalidate(clientLookup, clientID)
+}
+
+//InvalidateCampaign reloads cached campaign campaignID in the background
+func (c *BannerCache) InvalidateCampaign(campaignID int) {
+	c.invalidate(campaignLookup, campaignID)
+}
+
+func (c *BannerCache) invalidate(kind cacheKind, id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for l := range c.misses {
+		if l.key.kind == kind && l.key.id == id {
+			l.stale = true
+		}
+	}
+	expired := c.now().Add(-c.cfg.TTL)
+	for key, e := range c.entries {
+		if key.kind == kind && key.id == id {
+			if e.loaded.After(expired) {
+				e.loaded = expired
+			}
+			if e.refreshing {
+				e.dirty = true
+			}
+			c.startRefresh(key, e)
+		}
+	}
+}
+
+//Close stops the background refresh and returns once it has, or ctx is done
+func (c *BannerCache) Close(ctx context.Context) error {
+	c.mu.Lock()
+	select {
+	case <-c.quit:
+	default:
+		close(c.quit)
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *BannerCache) get(ctx context.Context, key cacheKey) (interface{}, error) {
+	c.mu.Lock()
+	now := c.now()
+	if r, ok := c.lookup(key, now); ok {
+		c.mu.Unlock()
+		return r.value, r.err
+	}
+	l := c.startMiss(key)
+	c.mu.Unlock()
+
+	c.metrics.Misses.Add(1)
+	r, err := c.load(ctx, key)
+	c.mu.Lock()
+	if c.endMiss(l) && err == nil {
+		c.put(key, r, now)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		c.metrics.Errors.Add(1)
+		return nil, err
+	}
+	return r.value, r.err
+}
+
+//startMiss records a load of key that missed the cache, c.mu must be held
+func (c *BannerCache) startMiss(key cacheKey) *missLoad {
+	l := &missLoad{key: key}
+	c.misses[l] = true
+	return l
+}
+
+//endMiss forgets l and reports whether its result may be cached, c.mu must be held
+func (c *BannerCache) endMiss(l *missLoad) bool {
+	delete(c.misses, l)
+	return !l.stale
+}
+
+//lookup answers key from memory unless it is missing or past its stale TTL,
+//c.mu must be held
+func (c *BannerCache) lookup(key cacheKey, now time.Time) (cacheResult, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return cacheResult{}, false
+	}
+	e.used = now
+	age := now.Sub(e.loaded)
+	if age < c.cfg.TTL {
+		c.metrics.Hits.Add(1)
+		return e.cacheResult, true
+	}
+	if age < c.cfg.TTL+c.cfg.StaleTTL {
+		c.startRefresh(key, e)
+		c.metrics.Stale.Add(1)
+		return e.cacheResult, true
+	}
+	return cacheResult{}, false
+}
+
+//put stores what the store answered to key at now, c.mu must be held
+func (c *BannerCache) put(key cacheKey, r cacheResult, now time.Time) {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	e.cacheResult, e.loaded, e.used = r, now, now
+}
+
+//startRefresh reloads e in the background unless it already is, c.mu must be held
+func (c *BannerCache) startRefresh(key cacheKey, e *cacheEntry) {
+	if e.refreshing {
+		return
+	}
+	e.refreshing = true
+	go c.refresh(key, e)
+}
+
+//refresh reloads e, keeping the old result if the store fails. It loads
+//again as long as e was invalidated during the load.
+func (c *BannerCache) refresh(key cacheKey, e *cacheEntry) {
+	for {
+		r, err := c.load(context.Background(), key)
+		c.mu.Lock()
+		if e.dirty {
+			e.dirty = false
+			c.mu.Unlock()
+			continue
+		}
+		e.refreshing = false
+		if err != nil {
+			c.metrics.Errors.Add(1)
+			c.logger.Log("component", "cache", "lookup", string(key.kind), "id", key.id, "name", key.name, "err", err)
+		} else {
+			e.cacheResult, e.loaded = r, c.now()
+		}
+		c.mu.Unlock()
+		return
+	}
+}
+
+//load asks the store for key. A models.ErrNotFound answer is returned as
+//the result, so it is cached like any other.
+func (c *BannerCache) load(ctx context.Context, key cacheKey) (cacheResult, error) {
+	var (
+		value interface{}
+		err   error
+	)
+	switch key.kind {
+	case bannerLookup:
+		if key.name == "" {
+			value, err = c.Store.GetBannersByGroup(ctx, key.id)
+		} else {
+			value, err = c.Store.GetBanners(ctx, key.name, key.id)
+		}
+	case clientLookup:
+		value, err = c.Store.GetBannerGroupByClient(ctx, key.id)
+	case websiteLookup:
+		value, err = c.Store.GetWebsiteByDomain(ctx, key.name)
+	case campaignLookup:
+		var campaigns []models.Campaign
+		campaigns, err = c.Store.GetCampaigns(ctx, []int{key.id})
+		if err == nil && len(campaigns) == 0 {
+			err = models.ErrNotFound
+		}
+		if len(campaigns) > 0 {
+			value = campaigns[0]
+		}
+	}
+	if err == models.ErrNotFound {
+		return cacheResult{value: value, err: err}, nil
+	}
+	return cacheResult{value: value}, err
+}
+
+func (c *BannerCache) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.sweep()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+//sweep drops idle entries and reloads the rest
+func (c *BannerCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, e := range c.entries {
+		if now.Sub(e.used) >= c.cfg.TTL+c.cfg.StaleTTL {
+			delete(c.entries, key)
+			continue
+		}
+		c.startRefresh(key, e)
+	}
+}
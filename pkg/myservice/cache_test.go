@@ -0,0 +1,314 @@
+package myservice
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+
+	"jf/adservice/models"
+)
+
+//flakyStore counts group loads and fails them on demand
+type flakyStore struct {
+	*models.MemoryStore
+	mu    sync.Mutex
+	loads int
+	fail  bool
+}
+
+var errStoreDown = errors.New("store down")
+
+func (s *flakyStore) GetBannersByGroup(ctx context.Context, groupID int) ([]*models.Banner, error) {
+	s.mu.Lock()
+	s.loads++
+	fail := s.fail
+	s.mu.Unlock()
+	if fail {
+		return nil, errStoreDown
+	}
+	return s.MemoryStore.GetBannersByGroup(ctx, groupID)
+}
+
+//down fails the other lookups of the read path on demand, without counting them
+func (s *flakyStore) down() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errStoreDown
+	}
+	return nil
+}
+
+func (s *flakyStore) GetBannerGroupByClient(ctx context.Context, clientID int) (int, error) {
+	if err := s.down(); err != nil {
+		return 0, err
+	}
+	return s.MemoryStore.GetBannerGroupByClient(ctx, clientID)
+}
+
+func (s *flakyStore) GetWebsiteByDomain(ctx context.Context, domain string) (models.Website, error) {
+	if err := s.down(); err != nil {
+		return models.Website{}, err
+	}
+	return s.MemoryStore.GetWebsiteByDomain(ctx, domain)
+}
+
+func (s *flakyStore) GetCampaigns(ctx context.Context, ids []int) ([]models.Campaign, error) {
+	if err := s.down(); err != nil {
+		return nil, err
+	}
+	return s.MemoryStore.GetCampaigns(ctx, ids)
+}
+
+func (s *flakyStore) SetCampaignStatus(ctx context.Context, id int, from, to string) (bool, error) {
+	if err := s.down(); err != nil {
+		return false, err
+	}
+	return s.MemoryStore.SetCampaignStatus(ctx, id, from, to)
+}
+
+func (s *flakyStore) GetVisitorProfile(ctx context.Context, visitorID string) (models.VisitorProfile, error) {
+	if err := s.down(); err != nil {
+		return models.VisitorProfile{}, err
+	}
+	return s.MemoryStore.GetVisitorProfile(ctx, visitorID)
+}
+
+func (s *flakyStore) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *flakyStore) loadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads
+}
+
+//waitFor polls cond for up to a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBannerCache(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyStore{MemoryStore: models.NewMemoryStore()}
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	var mu sync.Mutex
+	now := time.Unix(1000, 0)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	hits, misses, stale := generic.NewCounter("hits"), generic.NewCounter("misses"), generic.NewCounter("stale")
+	c := newBannerCache(store, log.NewNopLogger(), CacheConfig{TTL: time.Minute, StaleTTL: 5 * time.Minute},
+		CacheMetrics{Hits: hits, Misses: misses, Stale: stale}, clock)
+
+	for i := 0; i < 3; i++ {
+		if banners, err := c.GetBannersByGroup(ctx, 1); err != nil || len(banners) != 1 {
+			t.Fatalf("want 1 banner, got %v (%v)", banners, err)
+		}
+	}
+	if store.loadCount() != 1 || misses.Value() != 1 || hits.Value() != 2 {
+		t.Errorf("want 1 load and 2 hits, got %d loads, %v hits, %v misses", store.loadCount(), hits.Value(), misses.Value())
+	}
+
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.setFail(true)
+	advance(2 * time.Minute)
+	if banners, err := c.GetBannersByGroup(ctx, 1); err != nil || len(banners) != 1 {
+		t.Fatalf("want the stale banner while the store is down, got %v (%v)", banners, err)
+	}
+	waitFor(t, "failed refresh", func() bool { return store.loadCount() == 2 })
+	advance(5 * time.Minute)
+	if _, err := c.GetBannersByGroup(ctx, 1); err != errStoreDown {
+		t.Errorf("want the store error once past the stale TTL, got %v", err)
+	}
+
+	store.setFail(false)
+	if banners, _ := c.GetBannersByGroup(ctx, 1); len(banners) != 2 {
+		t.Fatalf("want the reloaded banners, got %v", banners)
+	}
+	store.PutBanner(models.Banner{ID: 3, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	c.Invalidate(1)
+	waitFor(t, "invalidated group", func() bool {
+		banners, _ := c.GetBannersByGroup(ctx, 1)
+		return len(banners) == 3
+	})
+	if stale.Value() < 1 {
+		t.Error("want stale lookups counted")
+	}
+}
+
+func TestBannerCacheSweep(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyStore{MemoryStore: models.NewMemoryStore()}
+	var mu sync.Mutex
+	now := time.Unix(1000, 0)
+	c := newBannerCache(store, log.NewNopLogger(), CacheConfig{TTL: time.Minute}, CacheMetrics{}, func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	c.GetBannersByGroup(ctx, 1)
+	c.GetBanners(ctx, "40*50", 2)
+	c.sweep()
+	waitFor(t, "background refresh", func() bool { return store.loadCount() == 2 })
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	c.sweep()
+	c.mu.Lock()
+	n := len(c.entries)
+	c.mu.Unlock()
+	if n != 0 {
+		t.Errorf("want idle entries dropped, have %d", n)
+	}
+}
+
+//gatedStore reads a group, then holds the load until it is released
+type gatedStore struct {
+	*models.MemoryStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s gatedStore) GetBannersByGroup(ctx context.Context, groupID int) ([]*models.Banner, error) {
+	banners, err := s.MemoryStore.GetBannersByGroup(ctx, groupID)
+	s.started <- struct{}{}
+	<-s.release
+	return banners, err
+}
+
+//next lets the load waiting at the gate finish, failing if none starts
+func (s gatedStore) next(t *testing.T) {
+	select {
+	case <-s.started:
+		s.release <- struct{}{}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a load")
+	}
+}
+
+func TestBannerCacheInvalidateDuringRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := gatedStore{MemoryStore: models.NewMemoryStore(), started: make(chan struct{}), release: make(chan struct{})}
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	now := time.Unix(1000, 0)
+	c := newBannerCache(store, log.NewNopLogger(), CacheConfig{TTL: time.Minute, StaleTTL: time.Minute}, CacheMetrics{}, func() time.Time { return now })
+	loaded := make(chan struct{})
+	go func() {
+		c.GetBannersByGroup(ctx, 1)
+		close(loaded)
+	}()
+	store.next(t)
+	<-loaded
+
+	c.Invalidate(1)
+	<-store.started
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	c.Invalidate(1)
+	store.release <- struct{}{}
+	store.next(t)
+	waitFor(t, "reload after the second invalidation", func() bool {
+		banners, _ := c.GetBannersByGroup(ctx, 1)
+		return len(banners) == 2
+	})
+}
+
+func TestBannerCacheInvalidateDuringMiss(t *testing.T) {
+	ctx := context.Background()
+	store := gatedStore{MemoryStore: models.NewMemoryStore(), started: make(chan struct{}), release: make(chan struct{})}
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	now := time.Unix(1000, 0)
+	c := newBannerCache(store, log.NewNopLogger(), CacheConfig{TTL: time.Minute, StaleTTL: time.Minute}, CacheMetrics{}, func() time.Time { return now })
+	loaded := make(chan []*models.Banner)
+	go func() {
+		banners, _ := c.GetBannersByGroup(ctx, 1)
+		loaded <- banners
+	}()
+	<-store.started
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	c.Invalidate(1)
+	store.release <- struct{}{}
+	<-loaded
+
+	go func() {
+		banners, _ := c.GetBannersByGroup(ctx, 1)
+		loaded <- banners
+	}()
+	store.next(t)
+	if banners := <-loaded; len(banners) != 2 {
+		t.Errorf("want the load from before the invalidation discarded, got %d banners", len(banners))
+	}
+}
+
+func TestGetBannersServesStaleWhenStoreDown(t *testing.T) {
+	ctx := WithVisitor(context.Background(), Visitor{ID: "v1"})
+	store := &flakyStore{MemoryStore: models.NewMemoryStore()}
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive, CampaignID: 1})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 3, GroupID: 1, Size: "40*50", Status: models.StatusActive, CampaignID: 2})
+	store.PutCampaign(models.Campaign{ID: 1, Status: models.CampaignActive})
+	store.PutCampaign(models.Campaign{ID: 2, Status: models.CampaignActive, TotalBudget: 1, Served: 1})
+	store.SetClientGroup(10, 1)
+	store.PutWebsite(models.Website{ID: 1, Domain: "news.example", ClientID: 10})
+	var mu sync.Mutex
+	now := time.Unix(1000, 0)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	cache := newBannerCache(store, log.NewNopLogger(), CacheConfig{TTL: time.Minute, StaleTTL: 5 * time.Minute}, CacheMetrics{}, clock)
+	profiles := NewTagProfiles(store, log.NewNopLogger(), TagConfig{})
+	defer profiles.Close(context.Background())
+	svc := NewService(cache, WithClock(clock), WithTagTargeting(profiles, TagsBoost, 1))
+
+	served := func(req BannerRequest) map[int]bool {
+		ads, err := svc.GetBanners(ctx, req)
+		if err != nil {
+			t.Fatalf("%+v: %v", req, err)
+		}
+		ids := map[int]bool{}
+		for _, ad := range ads {
+			ids[ad.ID] = true
+		}
+		return ids
+	}
+	reqs := []BannerRequest{
+		{ClientID: 10, Size: "40*50", Count: 5},
+		{Website: "news.example", Size: "40*50", Count: 5},
+	}
+	for _, req := range reqs {
+		served(req)
+	}
+
+	store.setFail(true)
+	mu.Lock()
+	now = now.Add(2 * time.Minute)
+	mu.Unlock()
+	for _, req := range reqs {
+		if ids := served(req); len(ids) != 2 || !ids[1] || !ids[2] {
+			t.Errorf("%+v: want banners 1 and 2 from the stale cache, got %v", req, ids)
+		}
+	}
+}
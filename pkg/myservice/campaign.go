@@ -0,0 +1,236 @@
+package myservice
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"jf/adservice/models"
+)
+
+//WithClock makes the service read the time from now, by default time.Now
+func WithClock(now func() time.Time) Option {
+	return func(s *bannerService) {
+		s.now = now
+	}
+}
+
+//servable drops candidates outside their schedule at now, and those whose
+//campaign may not be served. Banners without a campaign only follow their
+//own schedule. Campaigns found due for a new state are moved to it on the way,
+//a failed move is retried by the next request and never fails this one.
+func (s bannerService) servable(ctx context.Context, candidates []*models.Banner, now time.Time) ([]*models.Banner, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, b := range candidates {
+		if b.CampaignID != 0 && !seen[b.CampaignID] {
+			seen[b.CampaignID] = true
+			ids = append(ids, b.CampaignID)
+		}
+	}
+	servable := map[int]bool{}
+	if len(ids) > 0 {
+		campaigns, err := s.store.GetCampaigns(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range campaigns {
+			advanceCampaign(ctx, s.store, c, now)
+			servable[c.ID] = c.Servable(now)
+		}
+	}
+	kept := candidates[:0:0]
+	for _, b := range candidates {
+		if (b.CampaignID == 0 || servable[b.CampaignID]) && b.Schedule.Active(now) {
+			kept = append(kept, b)
+		}
+	}
+	return kept, nil
+}
+
+//advanceCampaign stores the state c is due for at now, if it changed.
+//Losing the race to another instance is fine, it made the same move.
+func advanceCampaign(ctx context.Context, store models.CampaignStore, c models.Campaign, now time.Time) error {
+	next := c.Next(now)
+	if next == c.Status {
+		return nil
+	}
+	_, err := store.SetCampaignStatus(ctx, c.ID, c.Status, next)
+	return err
+}
+
+//CampaignRecorder charges served banners to the budget of their campaign.
+//Served must not block.
+type CampaignRecorder interface {
+	Served(campaignID int, now time.Time)
+}
+
+//storeCampaigns charges every impression with its own write. It is the
+//default of NewService, failed writes are ignored so they never fail a
+//request that already logged its impressions.
+type storeCampaigns struct {
+	store models.CampaignStore
+}
+
+func (r storeCampaigns) Served(campaignID int, now time.Time) {
+	ctx := context.Background()
+	c, err := r.store.AddCampaignImpressions(ctx, campaignID, models.DayOf(now), 1)
+	if err != nil {
+		return
+	}
+	advanceCampaign(ctx, r.store, c, now)
+}
+
+//WithCampaignCounter charges served banners to their campaign through rec,
+//usually a CampaignCounter
+func WithCampaignCounter(rec CampaignRecorder) Option {
+	return func(s *bannerService) {
+		s.campaigns = rec
+	}
+}
+
+//CampaignCounterConfig sizes a CampaignCounter
+type CampaignCounterConfig struct {
+	//QueueSize is how many impressions may wait to be counted before new ones are dropped
+	QueueSize int
+	//FlushInterval is how often the summed impressions are written
+	FlushInterval time.Duration
+}
+
+//CampaignMetrics instruments a CampaignCounter, nil fields are discarded
+type CampaignMetrics struct {
+	Dropped metrics.Counter
+	Failed  metrics.Counter
+}
+
+//campaignDay keys the impressions of a campaign on a day
+type campaignDay struct {
+	id  int
+	day int
+}
+
+//CampaignCounter is a CampaignRecorder that sums impressions per campaign
+//and day in the background and writes every sum with one
+//AddCampaignImpressions per FlushInterval. Campaigns out of budget are moved
+//to their next state after the write, so a campaign may overshoot its
+//budget by the impressions of one interval. When the queue is full
+//impressions are dropped and counted rather than slowing down requests.
+//Close writes everything that was accepted.
+type CampaignCounter struct {
+	store   models.CampaignStore
+	logger  log.Logger
+	cfg     CampaignCounterConfig
+	metrics CampaignMetrics
+	now     func() time.Time
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan campaignDay
+	done   chan struct{}
+}
+
+//NewCampaignCounter starts a CampaignCounter writing to store
+func NewCampaignCounter(store models.CampaignStore, logger log.Logger, cfg CampaignCounterConfig, m CampaignMetrics) *CampaignCounter {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if m.Dropped == nil {
+		m.Dropped = discard.NewCounter()
+	}
+	if m.Failed == nil {
+		m.Failed = discard.NewCounter()
+	}
+	c := &CampaignCounter{
+		store:   store,
+		logger:  logger,
+		cfg:     cfg,
+		metrics: m,
+		now:     time.Now,
+		queue:   make(chan campaignDay, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+//Served implements CampaignRecorder. The impression is dropped if the
+//queue is full or the counter is closed.
+func (c *CampaignCounter) Served(campaignID int, now time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		c.metrics.Dropped.Add(1)
+		return
+	}
+	select {
+	case c.queue <- campaignDay{campaignID, models.DayOf(now)}:
+	default:
+		c.metrics.Dropped.Add(1)
+	}
+}
+
+//Close stops accepting impressions and returns once every queued one has
+//been written, or ctx is done.
+func (c *CampaignCounter) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *CampaignCounter) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+	counts := map[campaignDay]int{}
+	for {
+		select {
+		case key, ok := <-c.queue:
+			if !ok {
+				c.flush(counts)
+				return
+			}
+			counts[key]++
+		case <-ticker.C:
+			counts = c.flush(counts)
+		}
+	}
+}
+
+//flush writes counts and returns an empty map for the next interval
+func (c *CampaignCounter) flush(counts map[campaignDay]int) map[campaignDay]int {
+	if len(counts) == 0 {
+		return counts
+	}
+	ctx := context.Background()
+	for key, n := range counts {
+		campaign, err := c.store.AddCampaignImpressions(ctx, key.id, key.day, n)
+		if err == models.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			c.metrics.Failed.Add(float64(n))
+			c.logger.Log("campaign", key.id, "impressions", n, "err", err)
+			continue
+		}
+		if err := advanceCampaign(ctx, c.store, campaign, c.now()); err != nil {
+			c.logger.Log("campaign", key.id, "during", "advance", "err", err)
+		}
+	}
+	return map[campaignDay]int{}
+}
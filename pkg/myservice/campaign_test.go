@@ -0,0 +1,126 @@
+package myservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+)
+
+func TestGetBannersServesActiveCampaigns(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(86400*100, 0)
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive, CampaignID: 1})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive, CampaignID: 2})
+	store.PutBanner(models.Banner{ID: 3, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.PutCampaign(models.Campaign{ID: 1, Status: models.CampaignActive, DailyBudget: 2})
+	store.PutCampaign(models.Campaign{ID: 2, Status: models.CampaignPaused})
+	store.SetClientGroup(10, 1)
+	svc := NewService(store, WithClock(func() time.Time { return now }))
+	req := BannerRequest{ClientID: 10, Size: "40*50", Count: 5}
+
+	served := func() map[int]bool {
+		ads, err := svc.GetBanners(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[int]bool{}
+		for _, ad := range ads {
+			ids[ad.ID] = true
+		}
+		return ids
+	}
+	if ids := served(); len(ids) != 2 || !ids[1] || !ids[3] {
+		t.Errorf("want banners 1 and 3, got %v", ids)
+	}
+	served()
+	if ids := served(); len(ids) != 1 || !ids[3] {
+		t.Errorf("want campaign 1 out of its daily budget, got %v", ids)
+	}
+	if c, _ := store.GetCampaign(ctx, 1); c.Status != models.CampaignExhausted {
+		t.Errorf("want campaign 1 exhausted, got %s", c.Status)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if ids := served(); !ids[1] {
+		t.Errorf("want campaign 1 served again the next day, got %v", ids)
+	}
+	if c, _ := store.GetCampaign(ctx, 1); c.Status != models.CampaignActive || c.ServedToday != 1 {
+		t.Errorf("want campaign 1 active with 1 impression today, got %+v", c)
+	}
+}
+
+func TestGetBannersFollowsSchedules(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 3, 4, 10, 0, 0, 0, time.UTC) //Monday
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive,
+		Schedule: &models.Schedule{Windows: []models.ScheduleWindow{{Days: []string{"sat", "sun"}, Start: 0, End: 24}}}})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive, CampaignID: 1})
+	store.PutCampaign(models.Campaign{ID: 1, Status: models.CampaignActive,
+		Schedule: &models.Schedule{Timezone: "Asia/Tokyo", Windows: []models.ScheduleWindow{{Start: 9, End: 18}}}})
+	store.SetClientGroup(10, 1)
+	svc := NewService(store, WithClock(func() time.Time { return now }))
+	req := BannerRequest{ClientID: 10, Size: "40*50", Count: 2}
+
+	if ads, _ := svc.GetBanners(ctx, req); len(ads) != 0 {
+		t.Errorf("want nothing on Monday 19:00 in Tokyo, got %v", ads)
+	}
+	now = now.Add(-9 * time.Hour)
+	if ads, _ := svc.GetBanners(ctx, req); len(ads) != 1 || ads[0].ID != 2 {
+		t.Errorf("want the campaign banner during Tokyo business hours, got %v", ads)
+	}
+	now = now.Add(5 * 24 * time.Hour)
+	if ads, _ := svc.GetBanners(ctx, req); len(ads) != 2 {
+		t.Errorf("want both banners on Saturday, got %v", ads)
+	}
+}
+
+func TestCampaignCounter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(86400*100, 0)
+	store := models.NewMemoryStore()
+	store.PutCampaign(models.Campaign{ID: 1, Status: models.CampaignActive, DailyBudget: 2})
+	c := NewCampaignCounter(store, log.NewNopLogger(), CampaignCounterConfig{FlushInterval: time.Hour}, CampaignMetrics{})
+	c.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		c.Served(1, now)
+	}
+	c.Served(2, now)
+	if err := c.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	campaign, _ := store.GetCampaign(ctx, 1)
+	if campaign.Served != 3 || campaign.ServedToday != 3 || campaign.Status != models.CampaignExhausted {
+		t.Errorf("want 3 impressions and the campaign exhausted, got %+v", campaign)
+	}
+	c.Served(1, now)
+	if campaign, _ := store.GetCampaign(ctx, 1); campaign.Served != 3 {
+		t.Errorf("want nothing counted after Close, got %d", campaign.Served)
+	}
+}
+
+//failingCampaignStore fails to count campaign impressions
+type failingCampaignStore struct {
+	*models.MemoryStore
+}
+
+func (failingCampaignStore) AddCampaignImpressions(ctx context.Context, id, day, n int) (models.Campaign, error) {
+	return models.Campaign{}, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+}
+
+func TestGetBannersIgnoresCampaignCountFailures(t *testing.T) {
+	store := failingCampaignStore{models.NewMemoryStore()}
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive, CampaignID: 1})
+	store.PutCampaign(models.Campaign{ID: 1, Status: models.CampaignActive})
+	store.SetClientGroup(10, 1)
+	ads, err := NewService(store).GetBanners(context.Background(), BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil || len(ads) != 1 {
+		t.Errorf("want the banner served, got %v (%v)", ads, err)
+	}
+}
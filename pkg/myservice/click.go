@@ -0,0 +1,115 @@
+package myservice
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"jf/adservice/pkg/myerror"
+)
+
+var (
+	//ErrInvalidToken is returned for click tokens that are malformed or not signed by us
+	ErrInvalidToken = myerror.New(myerror.InvalidArgument, "invalid click token")
+	//ErrTokenExpired is returned for correctly signed click tokens past their expiry
+	ErrTokenExpired = myerror.New(myerror.Expired, "click token expired")
+)
+
+//ClickToken is the payload carried by a tracking URL
+type ClickToken struct {
+	BannerID     int    `json:"b"`
+	ClientID     int    `json:"c"`
+	VisitorID    string `json:"v,omitempty"`
+	ImpressionID string `json:"i"`
+	//Expires is a unix time in seconds
+	Expires int64 `json:"e"`
+}
+
+//ClickSigner issues and verifies HMAC-SHA256 signed click tokens.
+//A token is base64url(payload) "." base64url(signature).
+type ClickSigner struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+//NewClickSigner returns a ClickSigner whose tokens are valid for ttl
+func NewClickSigner(key []byte, ttl time.Duration) *ClickSigner {
+	return &ClickSigner{key: key, ttl: ttl, now: time.Now}
+}
+
+//Sign returns the token for t, setting its expiry from the signer's ttl
+func (s *ClickSigner) Sign(t ClickToken) string {
+	t.Expires = s.now().Add(s.ttl).Unix()
+	payload, _ := json.Marshal(t)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.mac(payload))
+}
+
+//Verify checks the signature and expiry of token and returns its payload
+func (s *ClickSigner) Verify(token string) (ClickToken, error) {
+	var t ClickToken
+	enc := base64.RawURLEncoding
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return t, ErrInvalidToken
+	}
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return t, ErrInvalidToken
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, s.mac(payload)) {
+		return t, ErrInvalidToken
+	}
+	if err := json.Unmarshal(payload, &t); err != nil {
+		return t, ErrInvalidToken
+	}
+	if s.now().Unix() > t.Expires {
+		return t, ErrTokenExpired
+	}
+	return t, nil
+}
+
+//ImpressionID returns a new impression ID of a banner. The ID is 8 random
+//bytes followed by 8 bytes of their signature with the banner in hex, so
+//heartbeats can be checked without looking the impression up.
+func (s *ClickSigner) ImpressionID(bannerID int) string {
+	var b [16]byte
+	if _, err := rand.Read(b[:8]); err != nil {
+		panic(err)
+	}
+	copy(b[8:], s.impressionMAC(b[:8], bannerID))
+	return hex.EncodeToString(b[:])
+}
+
+//VerifyImpression reports whether id was returned by ImpressionID for the banner
+func (s *ClickSigner) VerifyImpression(id string, bannerID int) bool {
+	b, err := hex.DecodeString(id)
+	if err != nil || len(b) != 16 {
+		return false
+	}
+	return hmac.Equal(b[8:], s.impressionMAC(b[:8], bannerID))
+}
+
+func (s *ClickSigner) impressionMAC(nonce []byte, bannerID int) []byte {
+	payload := make([]byte, 0, len("impression")+16)
+	payload = append(payload, "impression"...)
+	payload = append(payload, nonce...)
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], uint64(bannerID))
+	payload = append(payload, id[:]...)
+	return s.mac(payload)[:8]
+}
+
+func (s *ClickSigner) mac(payload []byte) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write(payload)
+	return m.Sum(nil)
+}
@@ -0,0 +1,68 @@
+package myservice
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClickSignerRoundTrip(t *testing.T) {
+	s := NewClickSigner([]byte("secret"), time.Hour)
+	want := ClickToken{BannerID: 1, ClientID: 10, VisitorID: "v", ImpressionID: "i"}
+	got, err := s.Verify(s.Sign(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Expires = got.Expires
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestClickSignerRejectsTampering(t *testing.T) {
+	s := NewClickSigner([]byte("secret"), time.Hour)
+	token := s.Sign(ClickToken{BannerID: 1})
+	other := NewClickSigner([]byte("other"), time.Hour).Sign(ClickToken{BannerID: 2})
+	parts := strings.Split(token, ".")
+	forged := strings.Split(other, ".")[0] + "." + parts[1]
+	for _, bad := range []string{"", "abc", token + "x", forged, parts[0], "!!." + parts[1]} {
+		if _, err := s.Verify(bad); err != ErrInvalidToken {
+			t.Errorf("%q: want ErrInvalidToken, got %v", bad, err)
+		}
+	}
+}
+
+func TestClickSignerExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewClickSigner([]byte("secret"), time.Minute)
+	s.now = func() time.Time { return now }
+	token := s.Sign(ClickToken{BannerID: 1})
+	now = now.Add(time.Minute)
+	if _, err := s.Verify(token); err != nil {
+		t.Errorf("want token valid until expiry, got %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := s.Verify(token); err != ErrTokenExpired {
+		t.Errorf("want ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestClickSignerImpressionIDs(t *testing.T) {
+	s := NewClickSigner([]byte("secret"), time.Hour)
+	id := s.ImpressionID(1)
+	if len(id) != 32 || id == s.ImpressionID(1) {
+		t.Fatalf("want distinct 32 character IDs, got %q", id)
+	}
+	if !s.VerifyImpression(id, 1) {
+		t.Error("want the ID verified for its banner")
+	}
+	other := NewClickSigner([]byte("other"), time.Hour)
+	for _, bad := range []struct {
+		id     string
+		banner int
+	}{{id, 2}, {other.ImpressionID(1), 1}, {"abc", 1}, {strings.Repeat("0", 32), 1}, {id[:30], 1}} {
+		if s.VerifyImpression(bad.id, bad.banner) {
+			t.Errorf("%q for banner %d: want rejected", bad.id, bad.banner)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package myservice
+
+import "context"
+
+type contextKey int
+
+const (
+	transportKey contextKey = iota
+	visitorKey
+	tokenKey
+	actorKey
+)
+
+//WithTransport returns a context recording the name of the transport that
+//received the request, transports call it before invoking endpoints
+func WithTransport(ctx context.Context, transport string) context.Context {
+	return context.WithValue(ctx, transportKey, transport)
+}
+
+//TransportFromContext returns the transport name stored by WithTransport
+func TransportFromContext(ctx context.Context) string {
+	transport, _ := ctx.Value(transportKey).(string)
+	return transport
+}
+
+//Visitor identifies the browser or app a request is made for
+type Visitor struct {
+	ID string
+	//Ephemeral is set when the visitor asked not to be tracked, ID is then
+	//only valid for the current request
+	Ephemeral bool
+}
+
+//WithVisitor returns a context carrying v, transports call it before invoking endpoints
+func WithVisitor(ctx context.Context, v Visitor) context.Context {
+	return context.WithValue(ctx, visitorKey, v)
+}
+
+//VisitorFromContext returns the visitor stored by WithVisitor, or the zero Visitor
+func VisitorFromContext(ctx context.Context) Visitor {
+	v, _ := ctx.Value(visitorKey).(Visitor)
+	return v
+}
+
+//WithToken returns a context carrying the bearer token a request presented,
+//transports call it before invoking endpoints
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+
+//TokenFromContext returns the token stored by WithToken
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey).(string)
+	return token
+}
+
+//WithActor returns a context carrying the name of the authenticated caller
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+//ActorFromContext returns the actor stored by WithActor
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
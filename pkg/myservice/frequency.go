@@ -0,0 +1,213 @@
+package myservice
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+//ErrInvalidFrequencyCap is returned for enabled caps with a window under one second
+var ErrInvalidFrequencyCap = myerror.New(myerror.InvalidArgument, "invalid frequency cap")
+
+//Frequency cap scopes
+const (
+	//CapBanner counts impressions of a banner
+	CapBanner = "banner"
+	//CapCampaign counts impressions of every banner of a campaign together
+	CapCampaign = "campaign"
+)
+
+//FrequencyCap limits how often one visitor is shown the same banner or
+//campaign. Windows are fixed and aligned to the unix epoch, so an hourly
+//cap resets on the hour.
+type FrequencyCap struct {
+	Scope  string
+	Window time.Duration
+	//Max is the number of impressions allowed per window, 0 or less disables the cap
+	Max int
+}
+
+//CounterStore keeps expiring impression counters for frequency capping
+type CounterStore interface {
+	//Counts returns the current value of every key, 0 for unknown or expired keys
+	Counts(ctx context.Context, keys []string) ([]int, error)
+	//Incr adds one to key, which expires ttl after it was first created
+	Incr(ctx context.Context, key string, ttl time.Duration) error
+}
+
+//FrequencyCapper filters out banners a visitor has reached a cap for and
+//counts the banners served to them.
+type FrequencyCapper struct {
+	counters CounterStore
+	caps     []FrequencyCap
+	now      func() time.Time
+}
+
+//NewFrequencyCapper returns a FrequencyCapper enforcing caps with counters.
+//Windows are counted in whole seconds, enabled caps with a shorter window
+//are rejected with ErrInvalidFrequencyCap.
+func NewFrequencyCapper(counters CounterStore, caps ...FrequencyCap) (*FrequencyCapper, error) {
+	for _, c := range caps {
+		if c.Max > 0 && c.Window < time.Second {
+			return nil, ErrInvalidFrequencyCap
+		}
+	}
+	return newFrequencyCapper(counters, caps, time.Now), nil
+}
+
+func newFrequencyCapper(counters CounterStore, caps []FrequencyCap, now func() time.Time) *FrequencyCapper {
+	f := &FrequencyCapper{counters: counters, now: now}
+	for _, c := range caps {
+		if c.Max > 0 {
+			f.caps = append(f.caps, c)
+		}
+	}
+	return f
+}
+
+//WithFrequencyCaps stops serving banners to visitors that reached a cap of f
+func WithFrequencyCaps(f *FrequencyCapper) Option {
+	return func(s *bannerService) {
+		s.capper = f
+	}
+}
+
+//Filter returns the candidates visitorID has not reached any cap for.
+//Banners are only counted once served, so a single request for several
+//banners of one campaign may overshoot a campaign cap by that many.
+func (f *FrequencyCapper) Filter(ctx context.Context, visitorID string, candidates []*models.Banner) ([]*models.Banner, error) {
+	if len(f.caps) == 0 || len(candidates) == 0 {
+		return candidates, nil
+	}
+	var keys []string
+	for _, b := range candidates {
+		keys = append(keys, f.keys(visitorID, b)...)
+	}
+	counts, err := f.counters.Counts(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	allowed := candidates[:0:0]
+	i := 0
+	for _, b := range candidates {
+		capped := false
+		for _, c := range f.caps {
+			if c.Scope == CapCampaign && b.CampaignID == 0 {
+				continue
+			}
+			if counts[i] >= c.Max {
+				capped = true
+			}
+			i++
+		}
+		if !capped {
+			allowed = append(allowed, b)
+		}
+	}
+	return allowed, nil
+}
+
+//Count records that b was served to visitorID
+func (f *FrequencyCapper) Count(ctx context.Context, visitorID string, b *models.Banner) error {
+	keys := f.keys(visitorID, b)
+	i := 0
+	for _, c := range f.caps {
+		if c.Scope == CapCampaign && b.CampaignID == 0 {
+			continue
+		}
+		if err := f.counters.Incr(ctx, keys[i], c.Window); err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
+
+//keys returns the counter key of every cap applying to b, in cap order
+func (f *FrequencyCapper) keys(visitorID string, b *models.Banner) []string {
+	now := f.now().Unix()
+	keys := make([]string, 0, len(f.caps))
+	for _, c := range f.caps {
+		id := b.ID
+		if c.Scope == CapCampaign {
+			if b.CampaignID == 0 {
+				continue
+			}
+			id = b.CampaignID
+		}
+		window := int64(c.Window / time.Second)
+		keys = append(keys, c.Scope+":"+strconv.Itoa(id)+":"+visitorID+":"+
+			strconv.FormatInt(window, 10)+":"+strconv.FormatInt(now/window, 10))
+	}
+	return keys
+}
+
+//MemoryCounters is an in-memory CounterStore. Expired counters are swept
+//at most once per minute. It is safe for concurrent use.
+type MemoryCounters struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type memoryCounter struct {
+	n       int
+	expires time.Time
+}
+
+//NewMemoryCounters returns an empty MemoryCounters
+func NewMemoryCounters() *MemoryCounters {
+	return newMemoryCounters(time.Now)
+}
+
+func newMemoryCounters(now func() time.Time) *MemoryCounters {
+	return &MemoryCounters{counters: make(map[string]memoryCounter), now: now, lastSweep: now()}
+}
+
+//Counts implements CounterStore
+func (m *MemoryCounters) Counts(ctx context.Context, keys []string) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	counts := make([]int, len(keys))
+	for i, key := range keys {
+		if c, ok := m.counters[key]; ok && now.Before(c.expires) {
+			counts[i] = c.n
+		}
+	}
+	return counts, nil
+}
+
+//Incr implements CounterStore
+func (m *MemoryCounters) Incr(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) >= time.Minute {
+		for k, c := range m.counters {
+			if !now.Before(c.expires) {
+				delete(m.counters, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = memoryCounter{expires: now.Add(ttl)}
+	}
+	c.n++
+	m.counters[key] = c
+	return nil
+}
+
+//Len returns the number of counters held, including expired ones not yet swept
+func (m *MemoryCounters) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.counters)
+}
@@ -0,0 +1,139 @@
+package myservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+)
+
+func TestFrequencyCapper(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(3600*1000, 0)
+	clock := func() time.Time { return now }
+	f := newFrequencyCapper(newMemoryCounters(clock), []FrequencyCap{
+		{Scope: CapBanner, Window: time.Hour, Max: 2},
+		{Scope: CapCampaign, Window: 24 * time.Hour, Max: 3},
+	}, clock)
+	a := &models.Banner{ID: 1, CampaignID: 7}
+	b := &models.Banner{ID: 2, CampaignID: 7}
+	c := &models.Banner{ID: 3}
+	candidates := []*models.Banner{a, b, c}
+
+	f.Count(ctx, "v1", a)
+	f.Count(ctx, "v1", a)
+	allowed, err := f.Filter(ctx, "v1", candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 2 || allowed[0] != b || allowed[1] != c {
+		t.Errorf("want banner 1 capped for the hour, got %v", allowed)
+	}
+	if allowed, _ := f.Filter(ctx, "v2", candidates); len(allowed) != 3 {
+		t.Errorf("want other visitors unaffected, got %v", allowed)
+	}
+
+	now = now.Add(time.Hour)
+	f.Count(ctx, "v1", b)
+	allowed, _ = f.Filter(ctx, "v1", candidates)
+	if len(allowed) != 1 || allowed[0] != c {
+		t.Errorf("want campaign 7 capped for the day, got %v", allowed)
+	}
+	f.Count(ctx, "v1", c)
+	if allowed, _ := f.Filter(ctx, "v1", []*models.Banner{c}); len(allowed) != 1 {
+		t.Errorf("want banners without campaign only capped per banner, got %v", allowed)
+	}
+	f.Count(ctx, "v1", c)
+	if allowed, _ := f.Filter(ctx, "v1", []*models.Banner{c}); len(allowed) != 0 {
+		t.Errorf("want banner 3 capped for the hour, got %v", allowed)
+	}
+}
+
+func TestMemoryCountersExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	m := newMemoryCounters(func() time.Time { return now })
+	m.Incr(ctx, "a", time.Minute)
+	m.Incr(ctx, "a", time.Minute)
+	if counts, _ := m.Counts(ctx, []string{"a", "b"}); counts[0] != 2 || counts[1] != 0 {
+		t.Errorf("want [2 0], got %v", counts)
+	}
+	now = now.Add(time.Minute)
+	if counts, _ := m.Counts(ctx, []string{"a"}); counts[0] != 0 {
+		t.Errorf("want expired counter to read 0, got %v", counts)
+	}
+	m.Incr(ctx, "b", time.Minute)
+	if m.Len() != 1 {
+		t.Errorf("want expired counters swept, have %d", m.Len())
+	}
+}
+
+func TestGetBannersFrequencyCapped(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	capper, err := NewFrequencyCapper(NewMemoryCounters(), FrequencyCap{Scope: CapBanner, Window: time.Hour, Max: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(store, WithFrequencyCaps(capper))
+	ctx := WithVisitor(context.Background(), Visitor{ID: "v1"})
+	req := BannerRequest{ClientID: 10, Size: "40*50"}
+	if ads, err := svc.GetBanners(ctx, req); err != nil || len(ads) != 1 {
+		t.Fatalf("want the banner served once, got %v (%v)", ads, err)
+	}
+	if ads, err := svc.GetBanners(ctx, req); err != nil || len(ads) != 0 {
+		t.Errorf("want the banner capped, got %v (%v)", ads, err)
+	}
+	dnt := WithVisitor(context.Background(), Visitor{ID: "v1", Ephemeral: true})
+	if ads, _ := svc.GetBanners(dnt, req); len(ads) != 1 {
+		t.Errorf("want ephemeral visitors uncapped, got %v", ads)
+	}
+}
+
+//failingIncr reads counters but fails every increment
+type failingIncr struct{ *MemoryCounters }
+
+func (failingIncr) Incr(ctx context.Context, key string, ttl time.Duration) error {
+	return errors.New("counters down")
+}
+
+func TestGetBannersIgnoresFailedCounts(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	capper, err := NewFrequencyCapper(failingIncr{NewMemoryCounters()}, FrequencyCap{Scope: CapBanner, Window: time.Hour, Max: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := 0
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		logged++
+		return nil
+	})
+	svc := NewService(store, WithFrequencyCaps(capper), WithLogger(logger))
+	ctx := WithVisitor(context.Background(), Visitor{ID: "v1"})
+	ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50", Count: 2})
+	if err != nil || len(ads) != 2 {
+		t.Fatalf("want both banners served, got %v (%v)", ads, err)
+	}
+	if logged != 2 {
+		t.Errorf("want every failed count logged, got %d", logged)
+	}
+}
+
+func TestNewFrequencyCapperRejectsShortWindows(t *testing.T) {
+	for _, window := range []time.Duration{0, -time.Hour, 500 * time.Millisecond} {
+		if _, err := NewFrequencyCapper(NewMemoryCounters(), FrequencyCap{Scope: CapBanner, Window: window, Max: 1}); err != ErrInvalidFrequencyCap {
+			t.Errorf("window %s: want ErrInvalidFrequencyCap, got %v", window, err)
+		}
+	}
+	if _, err := NewFrequencyCapper(NewMemoryCounters(), FrequencyCap{Scope: CapBanner, Max: 0}, FrequencyCap{Scope: CapBanner, Window: time.Second, Max: 1}); err != nil {
+		t.Errorf("want disabled caps and one second windows accepted, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package myservice
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"jf/adservice/models"
+)
+
+//ImpressionRecorder receives every served banner. Record must not block.
+type ImpressionRecorder interface {
+	Record(impression models.BannerLog)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(models.BannerLog) {}
+
+//ImpressionConfig sizes an ImpressionLogger
+type ImpressionConfig struct {
+	//QueueSize is how many impressions may wait to be written before new ones are dropped
+	QueueSize int
+	//BatchSize is the largest number of impressions written in one insert
+	BatchSize int
+	//FlushInterval is the longest an impression waits for its batch to fill
+	FlushInterval time.Duration
+}
+
+//ImpressionMetrics instruments an ImpressionLogger, nil fields are discarded
+type ImpressionMetrics struct {
+	Queued  metrics.Gauge
+	Dropped metrics.Counter
+	Written metrics.Counter
+	Failed  metrics.Counter
+}
+
+//ImpressionLogger is an ImpressionRecorder that writes impressions to a
+//BannerLogStore in batches from a background goroutine. When the queue is
+//full impressions are dropped and counted rather than slowing down requests.
+//Close flushes everything that was accepted.
+type ImpressionLogger struct {
+	store   models.BannerLogStore
+	logger  log.Logger
+	cfg     ImpressionConfig
+	metrics ImpressionMetrics
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan models.BannerLog
+	done   chan struct{}
+}
+
+//NewImpressionLogger starts an ImpressionLogger writing to store
+func NewImpressionLogger(store models.BannerLogStore, logger log.Logger, cfg ImpressionConfig, m ImpressionMetrics) *ImpressionLogger {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if m.Queued == nil {
+		m.Queued = discard.NewGauge()
+	}
+	if m.Dropped == nil {
+		m.Dropped = discard.NewCounter()
+	}
+	if m.Written == nil {
+		m.Written = discard.NewCounter()
+	}
+	if m.Failed == nil {
+		m.Failed = discard.NewCounter()
+	}
+	l := &ImpressionLogger{
+		store:   store,
+		logger:  logger,
+		cfg:     cfg,
+		metrics: m,
+		queue:   make(chan models.BannerLog, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+//Record queues impression without blocking. It is dropped if the queue is
+//full or the logger is closed.
+func (l *ImpressionLogger) Record(impression models.BannerLog) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.metrics.Dropped.Add(1)
+		return
+	}
+	select {
+	case l.queue <- impression:
+		l.metrics.Queued.Set(float64(len(l.queue)))
+	default:
+		l.metrics.Dropped.Add(1)
+	}
+}
+
+//Close stops accepting impressions and returns once every queued one has
+//been handed to the store, or ctx is done.
+func (l *ImpressionLogger) Close(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *ImpressionLogger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]models.BannerLog, 0, l.cfg.BatchSize)
+	for {
+		select {
+		case impression, ok := <-l.queue:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, impression)
+			if len(batch) >= l.cfg.BatchSize {
+				batch = l.flush(batch)
+			}
+		case <-ticker.C:
+			batch = l.flush(batch)
+		}
+	}
+}
+
+//flush writes batch and returns it emptied for reuse
+func (l *ImpressionLogger) flush(batch []models.BannerLog) []models.BannerLog {
+	l.metrics.Queued.Set(float64(len(l.queue)))
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := l.store.InsertBannerLogs(context.Background(), batch); err != nil {
+		l.metrics.Failed.Add(float64(len(batch)))
+		l.logger.Log("component", "impressions", "batch", len(batch), "err", err)
+	} else {
+		l.metrics.Written.Add(float64(len(batch)))
+	}
+	return batch[:0]
+}
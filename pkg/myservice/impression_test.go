@@ -0,0 +1,109 @@
+package myservice
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+
+	"jf/adservice/models"
+)
+
+//batchStore records the size of every batch it receives
+type batchStore struct {
+	mu      sync.Mutex
+	batches []int
+	logs    []models.BannerLog
+	block   chan struct{}
+}
+
+func (s *batchStore) InsertBannerLogs(ctx context.Context, logs []models.BannerLog) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, len(logs))
+	s.logs = append(s.logs, logs...)
+	return nil
+}
+
+func TestImpressionLoggerBatchesAndFlushesOnClose(t *testing.T) {
+	store := &batchStore{}
+	written := generic.NewCounter("written")
+	l := NewImpressionLogger(store, log.NewNopLogger(), ImpressionConfig{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	}, ImpressionMetrics{Written: written})
+	for i := 0; i < 25; i++ {
+		l.Record(models.BannerLog{BannerID: i})
+	}
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.logs) != 25 {
+		t.Fatalf("want 25 impressions written, got %d", len(store.logs))
+	}
+	if len(store.batches) != 3 || store.batches[0] != 10 || store.batches[2] != 5 {
+		t.Errorf("want batches [10 10 5], got %v", store.batches)
+	}
+	if v := written.Value(); v != 25 {
+		t.Errorf("want written counter 25, got %v", v)
+	}
+	l.Record(models.BannerLog{})
+	if len(store.logs) != 25 {
+		t.Error("impression accepted after Close")
+	}
+}
+
+func TestImpressionLoggerFlushInterval(t *testing.T) {
+	store := &batchStore{}
+	l := NewImpressionLogger(store, log.NewNopLogger(), ImpressionConfig{
+		BatchSize:     100,
+		FlushInterval: 10 * time.Millisecond,
+	}, ImpressionMetrics{})
+	defer l.Close(context.Background())
+	l.Record(models.BannerLog{BannerID: 1})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		store.mu.Lock()
+		n := len(store.logs)
+		store.mu.Unlock()
+		if n == 1 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("impression not flushed by the interval")
+}
+
+func TestImpressionLoggerDropsWhenFull(t *testing.T) {
+	store := &batchStore{block: make(chan struct{})}
+	dropped := generic.NewCounter("dropped")
+	l := NewImpressionLogger(store, log.NewNopLogger(), ImpressionConfig{
+		QueueSize:     2,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+	}, ImpressionMetrics{Dropped: dropped})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			l.Record(models.BannerLog{BannerID: i})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Record blocked on a full queue")
+	}
+	if dropped.Value() == 0 {
+		t.Error("want dropped impressions to be counted")
+	}
+	close(store.block)
+	l.Close(context.Background())
+}
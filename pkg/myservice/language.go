@@ -0,0 +1,138 @@
+package myservice
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"jf/adservice/models"
+)
+
+//Languages builds the locale fallback chain of a request. A tag falls back
+//to its configured fallbacks, or when it has none to its parent tag
+//(zh-Hant-TW -> zh-Hant -> zh). The default language ends every chain.
+type Languages struct {
+	fallbacks   map[string][]string
+	defaultLang string
+}
+
+//NewLanguages returns Languages using fallbacks, keyed by language tag,
+//and defaultLang, which may be empty
+func NewLanguages(fallbacks map[string][]string, defaultLang string) *Languages {
+	l := &Languages{
+		fallbacks:   make(map[string][]string, len(fallbacks)),
+		defaultLang: normalizeLang(defaultLang),
+	}
+	for tag, chain := range fallbacks {
+		normalized := make([]string, 0, len(chain))
+		for _, f := range chain {
+			normalized = append(normalized, normalizeLang(f))
+		}
+		l.fallbacks[normalizeLang(tag)] = normalized
+	}
+	return l
+}
+
+//Chain returns the tags to try, in order, for the preferred languages
+func (l *Languages) Chain(preferred ...string) []string {
+	var chain []string
+	seen := map[string]bool{}
+	var expand func(tag string)
+	expand = func(tag string) {
+		if tag == "" || seen[tag] {
+			return
+		}
+		seen[tag] = true
+		chain = append(chain, tag)
+		if fallbacks, ok := l.fallbacks[tag]; ok {
+			for _, f := range fallbacks {
+				expand(f)
+			}
+			return
+		}
+		expand(parentLang(tag))
+	}
+	for _, p := range preferred {
+		expand(normalizeLang(p))
+	}
+	expand(l.defaultLang)
+	return chain
+}
+
+//pickLanguage returns the banners of the first language in chain that has any,
+//with the language they matched. Language neutral banners, with an empty
+//Language, are used when no language in chain matches. An empty chain
+//leaves candidates unfiltered.
+func pickLanguage(candidates []*models.Banner, chain []string) ([]*models.Banner, string) {
+	if len(chain) == 0 {
+		return candidates, ""
+	}
+	byLang := map[string][]*models.Banner{}
+	for _, b := range candidates {
+		tag := normalizeLang(b.Language)
+		byLang[tag] = append(byLang[tag], b)
+	}
+	for _, tag := range chain {
+		if banners := byLang[tag]; len(banners) > 0 {
+			return banners, banners[0].Language
+		}
+	}
+	return byLang[""], ""
+}
+
+//ParseAcceptLanguage returns the languages of an Accept-Language header
+//ordered by quality, dropping "*" and q=0 entries
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, weighted{tag, q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	tags := make([]string, 0, len(langs))
+	for _, l := range langs {
+		tags = append(tags, l.tag)
+	}
+	return tags
+}
+
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,8}([-_][A-Za-z0-9]{1,8})*$`)
+
+//ValidLanguageTag reports whether tag looks like a language tag such as en or zh-TW
+func ValidLanguageTag(tag string) bool {
+	return languageTag.MatchString(tag)
+}
+
+//normalizeLang lower cases tag and uses '-' as separator, so "zh_TW" and
+//"zh-tw" match
+func normalizeLang(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
+
+func parentLang(tag string) string {
+	if i := strings.LastIndex(tag, "-"); i > 0 {
+		return tag[:i]
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package myservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"jf/adservice/models"
+)
+
+func TestLanguagesChain(t *testing.T) {
+	langs := NewLanguages(map[string][]string{"zh-TW": {"zh-Hant", "zh"}}, "en")
+	for _, tc := range []struct {
+		preferred []string
+		want      []string
+	}{
+		{[]string{"zh-TW"}, []string{"zh-tw", "zh-hant", "zh", "en"}},
+		{[]string{"zh_tw"}, []string{"zh-tw", "zh-hant", "zh", "en"}},
+		{[]string{"de-AT", "fr"}, []string{"de-at", "de", "fr", "en"}},
+		{[]string{"en-GB"}, []string{"en-gb", "en"}},
+		{nil, []string{"en"}},
+	} {
+		if got := langs.Chain(tc.preferred...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Chain(%v) = %v, want %v", tc.preferred, got, tc.want)
+		}
+	}
+	if got := NewLanguages(nil, "").Chain(); len(got) != 0 {
+		t.Errorf("want empty chain without languages, got %v", got)
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5, ja;q=0")
+	want := []string{"fr-CH", "fr", "en", "de"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got := ParseAcceptLanguage("en;q=0.1, zh-TW"); !reflect.DeepEqual(got, []string{"zh-TW", "en"}) {
+		t.Errorf("not ordered by quality: %v", got)
+	}
+}
+
+func TestGetBannersLanguageFallback(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Language: "zh", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Language: "en", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 3, GroupID: 1, Size: "40*50", Language: "ja-JP", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 4, GroupID: 2, Size: "40*50", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	store.SetClientGroup(20, 2)
+	svc := NewService(store, WithLanguages(NewLanguages(map[string][]string{"zh-TW": {"zh", "en"}}, "en")))
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		req    BannerRequest
+		banner int
+		locale string
+	}{
+		{BannerRequest{ClientID: 10, Size: "40*50", Lang: "zh-TW"}, 1, "zh"},
+		{BannerRequest{ClientID: 10, Size: "40*50", Lang: "ja-jp"}, 3, "ja-JP"},
+		{BannerRequest{ClientID: 10, Size: "40*50", Lang: "de"}, 2, "en"},
+		{BannerRequest{ClientID: 10, Size: "40*50", AcceptLanguage: "ja-JP;q=0.5, zh-TW"}, 1, "zh"},
+		{BannerRequest{ClientID: 10, Size: "40*50", Lang: "en", AcceptLanguage: "zh"}, 2, "en"},
+		{BannerRequest{ClientID: 20, Size: "40*50", Lang: "fr"}, 4, ""},
+	} {
+		ads, err := svc.GetBanners(ctx, tc.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ads) != 1 || ads[0].ID != tc.banner || ads[0].Locale != tc.locale {
+			t.Errorf("%+v: want banner %d in %q, got %+v", tc.req, tc.banner, tc.locale, ads)
+		}
+	}
+}
@@ -1,10 +1,10 @@
 package myservice
 
 import (
-  "context"
+	"context"
 
 	"github.com/go-kit/kit/log"
-  "jf/adservice/models"
+	"jf/adservice/models"
 )
 
 //Middleware describe a service (as opposed to endpoint) endpoint
@@ -23,9 +23,153 @@ type loggingMiddleware struct {
 	next   AdService
 }
 
-func (mw loggingMiddleware) GetBanners(ctx context.Context, clientID int, size string) (banners []*models.Banner, err error){
-  defer func(){
-    mw.logger.Log("method", "GetBanners", "clientID", clientID, "size", size, "banners", banners, "err", err)
-  }
-  return GetBanners(ctx, clientID, size)
+func (mw loggingMiddleware) GetBanner(ctx context.Context, id int) (banner models.Banner, err error) {
+	defer func() {
+		mw.logger.Log("method", "GetBanner", "id", id, "err", err)
+	}()
+	return mw.next.GetBanner(ctx, id)
+}
+
+func (mw loggingMiddleware) GetBanners(ctx context.Context, req BannerRequest) (ads []Ad, err error) {
+	defer func() {
+		mw.logger.Log("method", "GetBanners", "clientID", req.ClientID, "website", req.Website, "size", req.Size, "match", req.Match, "lang", req.Lang, "count", req.Count, "tags", req.Tags, "banners", len(ads), "err", err)
+	}()
+	return mw.next.GetBanners(ctx, req)
+}
+
+func (mw loggingMiddleware) RecordClick(ctx context.Context, token string) (url string, err error) {
+	defer func() {
+		mw.logger.Log("method", "RecordClick", "url", url, "err", err)
+	}()
+	return mw.next.RecordClick(ctx, token)
+}
+
+//Heartbeat is not logged, heartbeats arrive every few seconds per impression
+func (mw loggingMiddleware) Heartbeat(ctx context.Context, req HeartbeatRequest) error {
+	return mw.next.Heartbeat(ctx, req)
+}
+
+func (mw loggingMiddleware) Pacing(ctx context.Context) (states []PacingState, err error) {
+	defer func() {
+		mw.logger.Log("method", "Pacing", "banners", len(states), "err", err)
+	}()
+	return mw.next.Pacing(ctx)
+}
+
+func (mw loggingMiddleware) GetBannersBatch(ctx context.Context, req BannersRequest) (slots []SlotAds, err error) {
+	defer func() {
+		mw.logger.Log("method", "GetBannersBatch", "clientID", req.ClientID, "website", req.Website, "lang", req.Lang, "slots", len(req.Slots), "err", err)
+	}()
+	return mw.next.GetBannersBatch(ctx, req)
+}
+
+func (mw loggingMiddleware) AdTag(ctx context.Context, clientID int) (tag AdTag, err error) {
+	defer func() {
+		mw.logger.Log("method", "AdTag", "clientID", clientID, "sizes", len(tag.Sizes), "err", err)
+	}()
+	return mw.next.AdTag(ctx, clientID)
+}
+
+//AdminMiddleware describes an AdminService middleware
+type AdminMiddleware func(AdminService) AdminService
+
+//AdminLoggingMiddleware logs every admin change with the actor making it
+func AdminLoggingMiddleware(logger log.Logger) AdminMiddleware {
+	return func(next AdminService) AdminService {
+		return adminLoggingMiddleware{logger, next}
+	}
+}
+
+type adminLoggingMiddleware struct {
+	logger log.Logger
+	next   AdminService
+}
+
+func (mw adminLoggingMiddleware) log(ctx context.Context, method string, id, version int, err error) {
+	mw.logger.Log("method", method, "actor", ActorFromContext(ctx), "id", id, "version", version, "err", err)
+}
+
+func (mw adminLoggingMiddleware) CreateBanner(ctx context.Context, b models.Banner) (banner models.Banner, err error) {
+	defer func() { mw.log(ctx, "CreateBanner", banner.ID, banner.Version, err) }()
+	return mw.next.CreateBanner(ctx, b)
+}
+
+func (mw adminLoggingMiddleware) UpdateBanner(ctx context.Context, b models.Banner) (banner models.Banner, err error) {
+	defer func() { mw.log(ctx, "UpdateBanner", b.ID, banner.Version, err) }()
+	return mw.next.UpdateBanner(ctx, b)
+}
+
+func (mw adminLoggingMiddleware) SetBannerStatus(ctx context.Context, id, version int, active bool) (banner models.Banner, err error) {
+	defer func() {
+		mw.logger.Log("method", "SetBannerStatus", "actor", ActorFromContext(ctx), "id", id, "version", banner.Version, "active", active, "err", err)
+	}()
+	return mw.next.SetBannerStatus(ctx, id, version, active)
+}
+
+func (mw adminLoggingMiddleware) DeleteBanner(ctx context.Context, id, version int) (err error) {
+	defer func() { mw.log(ctx, "DeleteBanner", id, version, err) }()
+	return mw.next.DeleteBanner(ctx, id, version)
+}
+
+func (mw adminLoggingMiddleware) GetGroup(ctx context.Context, id int) (models.BannerGroup, error) {
+	return mw.next.GetGroup(ctx, id)
+}
+
+func (mw adminLoggingMiddleware) CreateGroup(ctx context.Context, g models.BannerGroup) (group models.BannerGroup, err error) {
+	defer func() { mw.log(ctx, "CreateGroup", group.ID, group.Version, err) }()
+	return mw.next.CreateGroup(ctx, g)
+}
+
+func (mw adminLoggingMiddleware) UpdateGroup(ctx context.Context, g models.BannerGroup) (group models.BannerGroup, err error) {
+	defer func() { mw.log(ctx, "UpdateGroup", g.ID, group.Version, err) }()
+	return mw.next.UpdateGroup(ctx, g)
+}
+
+func (mw adminLoggingMiddleware) DeleteGroup(ctx context.Context, id, version int) (err error) {
+	defer func() { mw.log(ctx, "DeleteGroup", id, version, err) }()
+	return mw.next.DeleteGroup(ctx, id, version)
+}
+
+func (mw adminLoggingMiddleware) GetClient(ctx context.Context, id int) (models.Client, error) {
+	return mw.next.GetClient(ctx, id)
+}
+
+func (mw adminLoggingMiddleware) CreateClient(ctx context.Context, c models.Client) (client models.Client, err error) {
+	defer func() { mw.log(ctx, "CreateClient", client.ID, client.Version, err) }()
+	return mw.next.CreateClient(ctx, c)
+}
+
+func (mw adminLoggingMiddleware) UpdateClient(ctx context.Context, c models.Client) (client models.Client, err error) {
+	defer func() { mw.log(ctx, "UpdateClient", c.ID, client.Version, err) }()
+	return mw.next.UpdateClient(ctx, c)
+}
+
+func (mw adminLoggingMiddleware) DeleteClient(ctx context.Context, id, version int) (err error) {
+	defer func() { mw.log(ctx, "DeleteClient", id, version, err) }()
+	return mw.next.DeleteClient(ctx, id, version)
+}
+
+func (mw adminLoggingMiddleware) GetCampaign(ctx context.Context, id int) (models.Campaign, error) {
+	return mw.next.GetCampaign(ctx, id)
+}
+
+func (mw adminLoggingMiddleware) CreateCampaign(ctx context.Context, c models.Campaign) (campaign models.Campaign, err error) {
+	defer func() { mw.log(ctx, "CreateCampaign", campaign.ID, campaign.Version, err) }()
+	return mw.next.CreateCampaign(ctx, c)
+}
+
+func (mw adminLoggingMiddleware) UpdateCampaign(ctx context.Context, c models.Campaign) (campaign models.Campaign, err error) {
+	defer func() { mw.log(ctx, "UpdateCampaign", c.ID, campaign.Version, err) }()
+	return mw.next.UpdateCampaign(ctx, c)
+}
+
+func (mw adminLoggingMiddleware) SetCampaignStatus(ctx context.Context, id int, status string) (campaign models.Campaign, err error) {
+	defer func() {
+		mw.logger.Log("method", "SetCampaignStatus", "actor", ActorFromContext(ctx), "id", id, "status", status, "err", err)
+	}()
+	return mw.next.SetCampaignStatus(ctx, id, status)
+}
+
+func (mw adminLoggingMiddleware) AuditLog(ctx context.Context, req AuditRequest) ([]models.AuditEntry, error) {
+	return mw.next.AuditLog(ctx, req)
 }
@@ -0,0 +1,253 @@
+package myservice
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+//ErrInvalidCurve is returned for pacing curves that are not 24 non-negative
+//hourly weights with a positive sum
+var ErrInvalidCurve = myerror.New(myerror.InvalidArgument, "invalid pacing curve")
+
+//PacingCurve is the share of daily traffic of every UTC hour. Delivery of a
+//daily target follows the cumulative curve; a nil curve paces evenly.
+type PacingCurve []float64
+
+//NewPacingCurve validates and normalizes hourly traffic weights
+func NewPacingCurve(hourly []float64) (PacingCurve, error) {
+	if len(hourly) == 0 {
+		return nil, nil
+	}
+	if len(hourly) != 24 {
+		return nil, ErrInvalidCurve
+	}
+	sum := 0.0
+	for _, w := range hourly {
+		if w < 0 {
+			return nil, ErrInvalidCurve
+		}
+		sum += w
+	}
+	if sum <= 0 {
+		return nil, ErrInvalidCurve
+	}
+	curve := make(PacingCurve, 24)
+	for i, w := range hourly {
+		curve[i] = w / sum
+	}
+	return curve, nil
+}
+
+//At returns the share of a daily target due by the fraction f of the day
+func (c PacingCurve) At(f float64) float64 {
+	if f <= 0 {
+		return 0
+	}
+	if f >= 1 {
+		return 1
+	}
+	if c == nil {
+		return f
+	}
+	hours := f * 24
+	hour := int(hours)
+	due := 0.0
+	for _, share := range c[:hour] {
+		due += share
+	}
+	return due + c[hour]*(hours-float64(hour))
+}
+
+//PacingConfig tunes a Pacer
+type PacingConfig struct {
+	Curve PacingCurve
+	//Interval is how often serving probabilities are recomputed
+	Interval time.Duration
+}
+
+//PacingState is the delivery of one banner's daily target today
+type PacingState struct {
+	BannerID int `json:"banner_id"`
+	//Day is the unix time of 00:00 UTC of the day being paced
+	Day    int `json:"day"`
+	Target int `json:"target"`
+	Served int `json:"served"`
+	//Expected is what the curve wants delivered by now
+	Expected float64 `json:"expected"`
+	//Probability is the chance an eligible request may serve the banner
+	Probability float64 `json:"probability"`
+}
+
+type pacingState struct {
+	day         int
+	target      int
+	served      int
+	probability float64
+	windowStart time.Time
+	eligible    int
+}
+
+//Pacer spreads the DailyTarget impressions of banners over the UTC day.
+//Every Interval it sets the probability with which a request may serve a
+//banner to what the last interval's traffic suggests is needed to catch up
+//with the curve by the end of the next one. A banner is never allowed more
+//than one interval ahead of the curve. Counts are kept per instance.
+//Pacer is safe for concurrent use.
+type Pacer struct {
+	cfg PacingConfig
+	now func() time.Time
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+	//day is the day of the newest state, older states are dropped when it changes
+	day    int
+	states map[int]*pacingState
+}
+
+//NewPacer returns a Pacer
+func NewPacer(cfg PacingConfig) *Pacer {
+	return newPacer(cfg, time.Now, time.Now().UnixNano())
+}
+
+func newPacer(cfg PacingConfig, now func() time.Time, seed int64) *Pacer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	return &Pacer{
+		cfg:    cfg,
+		now:    now,
+		rnd:    rand.New(rand.NewSource(seed)),
+		states: make(map[int]*pacingState),
+	}
+}
+
+//WithPacing throttles banners with a daily target through p
+func WithPacing(p *Pacer) Option {
+	return func(s *bannerService) {
+		s.pacer = p
+	}
+}
+
+//Filter returns the candidates that may be served now. Banners without a
+//daily target are always kept.
+func (p *Pacer) Filter(candidates []*models.Banner) []*models.Banner {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	kept := candidates[:0:0]
+	for _, b := range candidates {
+		if b.DailyTarget <= 0 || p.allow(b, now) {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+//Served counts an impression of b against its daily target
+func (p *Pacer) Served(b *models.Banner) {
+	if b.DailyTarget <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state(b, p.now()).served++
+}
+
+//State returns the pacing of every banner seen today, ordered by banner id
+func (p *Pacer) State() []PacingState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	day := models.DayOf(now)
+	states := make([]PacingState, 0, len(p.states))
+	for id, s := range p.states {
+		if s.day != day {
+			continue
+		}
+		states = append(states, PacingState{
+			BannerID:    id,
+			Day:         s.day,
+			Target:      s.target,
+			Served:      s.served,
+			Expected:    p.due(s, now, 0),
+			Probability: s.probability,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].BannerID < states[j].BannerID })
+	return states
+}
+
+//allow counts an eligible request for b and draws whether it may serve it
+func (p *Pacer) allow(b *models.Banner, now time.Time) bool {
+	s := p.state(b, now)
+	if now.Sub(s.windowStart) >= p.cfg.Interval {
+		p.adjust(s, now)
+	}
+	s.eligible++
+	if s.served >= s.target || float64(s.served) >= p.due(s, now, p.cfg.Interval) {
+		return false
+	}
+	return p.rnd.Float64() < s.probability
+}
+
+//adjust sets the probability for the window starting at now from the
+//traffic of the window that ended
+func (p *Pacer) adjust(s *pacingState, now time.Time) {
+	elapsed := now.Sub(s.windowStart)
+	if s.eligible > 0 && elapsed > 0 {
+		expected := float64(s.eligible) * float64(p.cfg.Interval) / float64(elapsed)
+		want := p.due(s, now, p.cfg.Interval) - float64(s.served)
+		switch {
+		case want <= 0:
+			s.probability = 0
+		case want >= expected:
+			s.probability = 1
+		default:
+			s.probability = want / expected
+		}
+	}
+	s.windowStart = now
+	s.eligible = 0
+}
+
+//state returns the pacing state of b, starting afresh on a new day. The
+//first state of a day drops the states of the banners not paced since.
+func (p *Pacer) state(b *models.Banner, now time.Time) *pacingState {
+	day := models.DayOf(now)
+	if day > p.day {
+		for id, s := range p.states {
+			if s.day < day {
+				delete(p.states, id)
+			}
+		}
+		p.day = day
+	}
+	s, ok := p.states[b.ID]
+	if !ok || s.day != day {
+		s = &pacingState{day: day, probability: 1, windowStart: now}
+		p.states[b.ID] = s
+	}
+	s.target = b.DailyTarget
+	return s
+}
+
+//due returns the impressions of s the curve wants delivered by now+ahead,
+//without looking past the end of the day
+func (p *Pacer) due(s *pacingState, now time.Time, ahead time.Duration) float64 {
+	f := (float64(now.Unix()-int64(s.day)) + ahead.Seconds()) / 86400
+	return float64(s.target) * p.cfg.Curve.At(f)
+}
+
+//Pacing implements AdService
+func (s bannerService) Pacing(ctx context.Context) ([]PacingState, error) {
+	if s.pacer == nil {
+		return []PacingState{}, nil
+	}
+	return s.pacer.State(), nil
+}
@@ -0,0 +1,130 @@
+package myservice
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"jf/adservice/models"
+)
+
+func TestPacingCurve(t *testing.T) {
+	var even PacingCurve
+	if even.At(0.25) != 0.25 || even.At(2) != 1 {
+		t.Errorf("want an even curve linear, got %v %v", even.At(0.25), even.At(2))
+	}
+	hourly := make([]float64, 24)
+	hourly[12], hourly[13] = 1, 3
+	curve, err := NewPacingCurve(hourly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if curve.At(12.0/24) != 0 || curve.At(13.0/24) != 0.25 || math.Abs(curve.At(13.5/24)-0.625) > 1e-9 {
+		t.Errorf("unexpected curve %v %v %v", curve.At(12.0/24), curve.At(13.0/24), curve.At(13.5/24))
+	}
+	if _, err := NewPacingCurve([]float64{1, 2}); err != ErrInvalidCurve {
+		t.Errorf("want ErrInvalidCurve, got %v", err)
+	}
+}
+
+//simulatePacing sends one request per second for hours and returns the
+//impressions served at the end of each hour
+func simulatePacing(p *Pacer, b *models.Banner, clock *time.Time, hours int) []int {
+	var served []int
+	total := 0
+	for h := 0; h < hours; h++ {
+		for i := 0; i < 3600; i++ {
+			if len(p.Filter([]*models.Banner{b})) == 1 {
+				p.Served(b)
+				total++
+			}
+			*clock = clock.Add(time.Second)
+		}
+		served = append(served, total)
+	}
+	return served
+}
+
+func TestPacerEven(t *testing.T) {
+	clock := time.Unix(86400*100, 0)
+	p := newPacer(PacingConfig{Interval: time.Minute}, func() time.Time { return clock }, 1)
+	b := &models.Banner{ID: 1, DailyTarget: 2400}
+	served := simulatePacing(p, b, &clock, 24)
+	for h, n := range served {
+		want := 100 * (h + 1)
+		if math.Abs(float64(n-want)) > 15 {
+			t.Errorf("hour %d: want about %d served, got %d", h, want, n)
+		}
+	}
+	if served[23] > 2400 {
+		t.Errorf("served %d over the target", served[23])
+	}
+}
+
+func TestPacerTrafficShaped(t *testing.T) {
+	clock := time.Unix(86400*100, 0)
+	hourly := make([]float64, 24)
+	for h := 8; h < 20; h++ {
+		hourly[h] = 1
+	}
+	curve, _ := NewPacingCurve(hourly)
+	p := newPacer(PacingConfig{Curve: curve, Interval: time.Minute}, func() time.Time { return clock }, 1)
+	b := &models.Banner{ID: 1, DailyTarget: 1200}
+	served := simulatePacing(p, b, &clock, 24)
+	if served[7] != 0 {
+		t.Errorf("want nothing served before 08:00, got %d", served[7])
+	}
+	if math.Abs(float64(served[13]-600)) > 15 {
+		t.Errorf("want about half served by 14:00, got %d", served[13])
+	}
+	if served[23] < 1185 || served[23] > 1200 {
+		t.Errorf("want the target delivered, got %d", served[23])
+	}
+
+	states := p.State()
+	if len(states) != 0 {
+		t.Errorf("want yesterday's state hidden, got %+v", states)
+	}
+	p.Filter([]*models.Banner{b, {ID: 2}})
+	states = p.State()
+	if len(states) != 1 || states[0].BannerID != 1 || states[0].Served != 0 || states[0].Target != 1200 {
+		t.Errorf("want a fresh state for banner 1, got %+v", states)
+	}
+}
+
+func TestPacerDropsPastDays(t *testing.T) {
+	clock := time.Unix(86400*100, 0)
+	p := newPacer(PacingConfig{Interval: time.Minute}, func() time.Time { return clock }, 1)
+	p.Filter([]*models.Banner{{ID: 1, DailyTarget: 100}, {ID: 2, DailyTarget: 100}})
+	clock = clock.Add(24 * time.Hour)
+	p.Served(&models.Banner{ID: 2, DailyTarget: 100})
+	if len(p.states) != 1 || p.states[2] == nil || p.states[2].served != 1 {
+		t.Errorf("want only today's state of banner 2 kept, got %+v", p.states)
+	}
+}
+
+func TestGetBannersPaced(t *testing.T) {
+	clock := time.Unix(86400*100, 0)
+	now := func() time.Time { return clock }
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive, DailyTarget: 24})
+	store.SetClientGroup(10, 1)
+	svc := NewService(store, WithClock(now), WithPacing(newPacer(PacingConfig{}, now, 1)))
+	served := 0
+	for i := 0; i < 600; i++ {
+		ads, err := svc.GetBanners(context.Background(), BannerRequest{ClientID: 10, Size: "40*50"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		served += len(ads)
+		clock = clock.Add(time.Second)
+	}
+	if served > 2 {
+		t.Errorf("want at most 2 impressions in the first 10 minutes, got %d", served)
+	}
+	states, err := svc.Pacing(context.Background())
+	if err != nil || len(states) != 1 || states[0].Served != served {
+		t.Errorf("want the pacing state of banner 1, got %+v (%v)", states, err)
+	}
+}
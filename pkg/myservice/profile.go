@@ -0,0 +1,273 @@
+package myservice
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+//Tag targeting modes of a BannerRequest
+const (
+	//TagsOff ignores the visitor profile
+	TagsOff = "off"
+	//TagsBoost favours banners whose tags the visitor has scores for
+	TagsBoost = "boost"
+	//TagsRequire only serves banners sharing a tag with the visitor profile
+	TagsRequire = "require"
+)
+
+//ErrInvalidTags is returned for unknown tag targeting modes
+var ErrInvalidTags = myerror.New(myerror.InvalidArgument, "invalid tag targeting mode")
+
+//TagConfig tunes TagProfiles
+type TagConfig struct {
+	//HalfLife is how long it takes a tag score to halve
+	HalfLife time.Duration
+	//MaxTags is the most tags kept per visitor, the lowest scores are dropped first
+	MaxTags int
+	//ImpressionWeight is added to the banner tags of every served banner
+	ImpressionWeight float64
+	//ClickWeight is added to the banner and landing tags of every click
+	ClickWeight float64
+	//QueueSize is how many observations may wait before new ones are dropped
+	QueueSize int
+}
+
+type tagObservation struct {
+	visitorID string
+	tags      []string
+	weight    float64
+}
+
+//TagProfiles accumulates decayed tag scores on visitor profiles. Updates are
+//applied one at a time by a background goroutine so Observe never blocks a
+//request; when the queue is full observations are dropped. Close applies
+//everything that was accepted.
+type TagProfiles struct {
+	store  models.ProfileStore
+	logger log.Logger
+	cfg    TagConfig
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan tagObservation
+	done   chan struct{}
+}
+
+//NewTagProfiles starts TagProfiles keeping profiles in store
+func NewTagProfiles(store models.ProfileStore, logger log.Logger, cfg TagConfig) *TagProfiles {
+	return newTagProfiles(store, logger, cfg, time.Now)
+}
+
+func newTagProfiles(store models.ProfileStore, logger log.Logger, cfg TagConfig, now func() time.Time) *TagProfiles {
+	if cfg.HalfLife <= 0 {
+		cfg.HalfLife = 7 * 24 * time.Hour
+	}
+	if cfg.MaxTags <= 0 {
+		cfg.MaxTags = 50
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	p := &TagProfiles{
+		store:  store,
+		logger: logger,
+		cfg:    cfg,
+		now:    now,
+		queue:  make(chan tagObservation, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+//Impression records that visitorID was shown a banner with tags
+func (p *TagProfiles) Impression(visitorID string, tags []string) {
+	p.Observe(visitorID, tags, p.cfg.ImpressionWeight)
+}
+
+//Click records that visitorID clicked a banner with tags
+func (p *TagProfiles) Click(visitorID string, tags []string) {
+	p.Observe(visitorID, tags, p.cfg.ClickWeight)
+}
+
+//Observe queues adding weight to each of tags on the profile of visitorID.
+//It never blocks.
+func (p *TagProfiles) Observe(visitorID string, tags []string, weight float64) {
+	if visitorID == "" || len(tags) == 0 || weight == 0 {
+		return
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- tagObservation{visitorID: visitorID, tags: tags, weight: weight}:
+	default:
+	}
+}
+
+//Profile returns the tag scores of visitorID decayed to now, empty for
+//unknown visitors
+func (p *TagProfiles) Profile(ctx context.Context, visitorID string) (map[string]float64, error) {
+	profile, err := p.store.GetVisitorProfile(ctx, visitorID)
+	if err == models.ErrNotFound {
+		return map[string]float64{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.decay(&profile)
+	return profile.Scores, nil
+}
+
+//Close stops accepting observations and returns once every queued one has
+//been saved, or ctx is done.
+func (p *TagProfiles) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *TagProfiles) run() {
+	defer close(p.done)
+	for o := range p.queue {
+		if err := p.apply(o); err != nil {
+			p.logger.Log("component", "profiles", "visitor", o.visitorID, "err", err)
+		}
+	}
+}
+
+//apply reads, decays, updates and saves one profile
+func (p *TagProfiles) apply(o tagObservation) error {
+	ctx := context.Background()
+	profile, err := p.store.GetVisitorProfile(ctx, o.visitorID)
+	if err != nil && err != models.ErrNotFound {
+		return err
+	}
+	profile.VisitorID = o.visitorID
+	p.decay(&profile)
+	for _, tag := range o.tags {
+		profile.Scores[tag] += o.weight
+	}
+	p.trim(&profile)
+	return p.store.SaveVisitorProfile(ctx, profile)
+}
+
+//decay brings the scores of profile forward to now
+func (p *TagProfiles) decay(profile *models.VisitorProfile) {
+	now := p.now().Unix()
+	if profile.Scores == nil {
+		profile.Scores = map[string]float64{}
+	}
+	if elapsed := now - profile.Updated; elapsed > 0 && profile.Updated > 0 {
+		factor := math.Exp2(-float64(elapsed) / p.cfg.HalfLife.Seconds())
+		for tag, score := range profile.Scores {
+			profile.Scores[tag] = score * factor
+		}
+	}
+	profile.Updated = now
+}
+
+//trim drops the lowest scores beyond MaxTags
+func (p *TagProfiles) trim(profile *models.VisitorProfile) {
+	if len(profile.Scores) <= p.cfg.MaxTags {
+		return
+	}
+	tags := make([]string, 0, len(profile.Scores))
+	for tag := range profile.Scores {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if profile.Scores[tags[i]] != profile.Scores[tags[j]] {
+			return profile.Scores[tags[i]] > profile.Scores[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	for _, tag := range tags[p.cfg.MaxTags:] {
+		delete(profile.Scores, tag)
+	}
+}
+
+//WithTagTargeting keeps visitor tag profiles in profiles and targets banners
+//on them. mode is used for requests that do not set one, boost is how much
+//a fully matching banner's weight is multiplied by on top of its own.
+func WithTagTargeting(profiles *TagProfiles, mode string, boost float64) Option {
+	return func(s *bannerService) {
+		s.profiles = profiles
+		s.tagMode = mode
+		s.tagBoost = boost
+	}
+}
+
+//targetTags applies the tag targeting mode of req to candidates. A profile
+//that cannot be read counts as empty, targeting never fails a request.
+func (s bannerService) targetTags(ctx context.Context, candidates []*models.Banner, mode string, visitor Visitor) ([]*models.Banner, error) {
+	if mode == "" {
+		mode = s.tagMode
+	}
+	switch mode {
+	case "", TagsOff:
+		return candidates, nil
+	case TagsBoost, TagsRequire:
+	default:
+		return nil, ErrInvalidTags
+	}
+	scores := map[string]float64{}
+	if s.profiles != nil && visitor.ID != "" && !visitor.Ephemeral {
+		if profile, err := s.profiles.Profile(ctx, visitor.ID); err == nil {
+			scores = profile
+		}
+	}
+	if mode == TagsRequire {
+		var matched []*models.Banner
+		for _, b := range candidates {
+			if affinity(b, scores) > 0 {
+				matched = append(matched, b)
+			}
+		}
+		return matched, nil
+	}
+	boosted := make([]*models.Banner, 0, len(candidates))
+	for _, b := range candidates {
+		c := *b
+		c.Weight = int(math.Round(float64(weight(b)) * 100 * (1 + s.tagBoost*affinity(b, scores))))
+		boosted = append(boosted, &c)
+	}
+	return boosted, nil
+}
+
+//affinity is the summed profile score of the banner tags relative to the
+//visitor's top score, 0 when they share no tag
+func affinity(b *models.Banner, scores map[string]float64) float64 {
+	top := 0.0
+	for _, score := range scores {
+		top = math.Max(top, score)
+	}
+	if top <= 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, tag := range b.Tags {
+		sum += scores[tag]
+	}
+	return sum / top
+}
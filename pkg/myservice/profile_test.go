@@ -0,0 +1,131 @@
+package myservice
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+)
+
+func TestTagProfilesDecay(t *testing.T) {
+	store := models.NewMemoryStore()
+	now := time.Unix(1000000, 0)
+	p := newTagProfiles(store, log.NewNopLogger(), TagConfig{HalfLife: time.Hour, ClickWeight: 4}, func() time.Time { return now })
+	p.Click("v1", []string{"sports", "cars"})
+	p.Observe("v1", []string{"sports"}, 2)
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	scores, err := p.Profile(context.Background(), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(scores["sports"]-1.5) > 1e-9 || math.Abs(scores["cars"]-1) > 1e-9 {
+		t.Errorf("want sports 1.5 and cars 1 after two half lives, got %v", scores)
+	}
+	if scores, _ := p.Profile(context.Background(), "unknown"); len(scores) != 0 {
+		t.Errorf("want an empty profile, got %v", scores)
+	}
+}
+
+func TestTagProfilesMaxTags(t *testing.T) {
+	store := models.NewMemoryStore()
+	p := NewTagProfiles(store, log.NewNopLogger(), TagConfig{MaxTags: 2})
+	p.Observe("v1", []string{"a", "b"}, 1)
+	p.Observe("v1", []string{"b", "c"}, 2)
+	p.Close(context.Background())
+	profile, err := store.GetVisitorProfile(context.Background(), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profile.Scores) != 2 || profile.Scores["a"] != 0 {
+		t.Errorf("want the lowest tag dropped, got %v", profile.Scores)
+	}
+}
+
+func newTagService(mode string) (AdService, *TagProfiles, *models.MemoryStore) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive, Tags: []string{"sports"}, LandingTags: []string{"shoes"}})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Status: models.StatusActive, Tags: []string{"cooking"}})
+	store.SetClientGroup(10, 1)
+	profiles := NewTagProfiles(store, log.NewNopLogger(), TagConfig{ClickWeight: 1})
+	signer := NewClickSigner([]byte("0123456789abcdef"), time.Hour)
+	svc := NewService(store, WithSelector(NewSelector(1)), WithClickTracking(signer, ""), WithTagTargeting(profiles, mode, 100))
+	return svc, profiles, store
+}
+
+func TestTagTargeting(t *testing.T) {
+	svc, profiles, store := newTagService(TagsOff)
+	store.SaveVisitorProfile(context.Background(), models.VisitorProfile{VisitorID: "v1", Scores: map[string]float64{"sports": 1}})
+	ctx := WithVisitor(context.Background(), Visitor{ID: "v1"})
+	defer profiles.Close(context.Background())
+
+	for i := 0; i < 10; i++ {
+		ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50", Tags: TagsRequire})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ads) != 1 || ads[0].ID != 1 {
+			t.Fatalf("want only the sports banner, got %v", ads)
+		}
+	}
+	boosted := 0
+	for i := 0; i < 100; i++ {
+		ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50", Tags: TagsBoost})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ads[0].ID == 1 {
+			boosted++
+		}
+		if ads[0].Weight != 0 {
+			t.Fatalf("boosted weight leaked into the response: %d", ads[0].Weight)
+		}
+	}
+	if boosted < 90 {
+		t.Errorf("want the sports banner to dominate, served %d of 100", boosted)
+	}
+	ads, err := svc.GetBanners(WithVisitor(context.Background(), Visitor{ID: "v2"}), BannerRequest{ClientID: 10, Size: "40*50", Tags: TagsRequire})
+	if err != nil || len(ads) != 0 {
+		t.Errorf("want no banners for a visitor without profile, got %v (%v)", ads, err)
+	}
+	if _, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50", Tags: "maybe"}); err != ErrInvalidTags {
+		t.Errorf("want ErrInvalidTags, got %v", err)
+	}
+}
+
+func TestClickUpdatesProfile(t *testing.T) {
+	svc, profiles, store := newTagService(TagsOff)
+	ads, err := svc.GetBanners(WithVisitor(context.Background(), Visitor{ID: "v1"}), BannerRequest{ClientID: 10, Size: "40*50", Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ad := range ads {
+		if ad.ID == 1 {
+			token := ad.ClickURL[len("/v1/click/"):]
+			if _, err := svc.RecordClick(context.Background(), token); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ctx := WithVisitor(context.Background(), Visitor{ID: "v3", Ephemeral: true})
+	if _, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50"}); err != nil {
+		t.Fatal(err)
+	}
+	profiles.Close(context.Background())
+	profile, err := store.GetVisitorProfile(context.Background(), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Scores["sports"] != 1 || profile.Scores["shoes"] != 1 {
+		t.Errorf("want banner and landing tags scored, got %v", profile.Scores)
+	}
+	if _, err := store.GetVisitorProfile(context.Background(), "v3"); err != models.ErrNotFound {
+		t.Errorf("want no profile for an ephemeral visitor, got %v", err)
+	}
+}
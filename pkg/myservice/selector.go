@@ -0,0 +1,86 @@
+package myservice
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"jf/adservice/models"
+)
+
+//Selector picks which banners to serve. Banners are grouped by Priority,
+//highest first, and inside a priority tier they are drawn at random in
+//proportion to their Weight without replacement. A weight of zero or less
+//counts as 1; set Status to stop serving a banner.
+//Selector is safe for concurrent use.
+type Selector struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+//NewSelector returns a Selector whose draws are fully determined by seed
+func NewSelector(seed int64) *Selector {
+	return &Selector{rnd: rand.New(rand.NewSource(seed))}
+}
+
+//newTimeSelector returns a Selector seeded from the clock
+func newTimeSelector() *Selector {
+	return NewSelector(time.Now().UnixNano())
+}
+
+//Select returns up to n distinct banners chosen from candidates.
+//candidates is not modified.
+func (s *Selector) Select(candidates []*models.Banner, n int) []*models.Banner {
+	if n <= 0 || len(candidates) == 0 {
+		return nil
+	}
+	pool := make([]*models.Banner, len(candidates))
+	copy(pool, candidates)
+	sort.SliceStable(pool, func(i, j int) bool { return pool[i].Priority > pool[j].Priority })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := n
+	if size > len(pool) {
+		size = len(pool)
+	}
+	selected := make([]*models.Banner, 0, size)
+	for start := 0; start < len(pool) && len(selected) < n; {
+		end := start
+		for end < len(pool) && pool[end].Priority == pool[start].Priority {
+			end++
+		}
+		selected = s.drawTier(pool[start:end], n-len(selected), selected)
+		start = end
+	}
+	return selected
+}
+
+//drawTier appends up to n weighted draws from tier to selected.
+//tier is reordered in place.
+func (s *Selector) drawTier(tier []*models.Banner, n int, selected []*models.Banner) []*models.Banner {
+	total := 0
+	for _, b := range tier {
+		total += weight(b)
+	}
+	for ; n > 0 && len(tier) > 0; n-- {
+		r := s.rnd.Intn(total)
+		i := 0
+		for ; r >= weight(tier[i]); i++ {
+			r -= weight(tier[i])
+		}
+		selected = append(selected, tier[i])
+		total -= weight(tier[i])
+		tier[i] = tier[len(tier)-1]
+		tier = tier[:len(tier)-1]
+	}
+	return selected
+}
+
+func weight(b *models.Banner) int {
+	if b.Weight <= 0 {
+		return 1
+	}
+	return b.Weight
+}
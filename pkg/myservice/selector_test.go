@@ -0,0 +1,60 @@
+package myservice
+
+import (
+	"math"
+	"testing"
+
+	"jf/adservice/models"
+)
+
+func TestSelectorWeightDistribution(t *testing.T) {
+	candidates := []*models.Banner{
+		{ID: 1, Weight: 1},
+		{ID: 2, Weight: 3},
+	}
+	sel := NewSelector(42)
+	counts := map[int]int{}
+	const draws = 20000
+	for i := 0; i < draws; i++ {
+		counts[sel.Select(candidates, 1)[0].ID]++
+	}
+	if share := float64(counts[2]) / draws; math.Abs(share-0.75) > 0.02 {
+		t.Errorf("want banner 2 served ~75%%, got %.3f", share)
+	}
+}
+
+func TestSelectorPriority(t *testing.T) {
+	candidates := []*models.Banner{
+		{ID: 1, Weight: 100, Priority: 0},
+		{ID: 2, Weight: 1, Priority: 5},
+		{ID: 3, Weight: 1, Priority: 5},
+	}
+	sel := NewSelector(7)
+	for i := 0; i < 100; i++ {
+		got := sel.Select(candidates, 2)
+		if len(got) != 2 || got[0].Priority != 5 || got[1].Priority != 5 || got[0].ID == got[1].ID {
+			t.Fatalf("want both priority 5 banners, got %v", got)
+		}
+	}
+	got := sel.Select(candidates, 5)
+	if len(got) != 3 || got[2].ID != 1 {
+		t.Errorf("want lower tier to fill the last slot, got %v", got)
+	}
+	if got := sel.Select(candidates, math.MaxInt64); len(got) != 3 {
+		t.Errorf("want every candidate for a huge count, got %v", got)
+	}
+}
+
+func TestSelectorDeterministic(t *testing.T) {
+	candidates := []*models.Banner{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	a, b := NewSelector(3), NewSelector(3)
+	for i := 0; i < 50; i++ {
+		x, y := a.Select(candidates, 2), b.Select(candidates, 2)
+		if x[0].ID != y[0].ID || x[1].ID != y[1].ID {
+			t.Fatalf("same seed diverged at draw %d", i)
+		}
+	}
+	if candidates[0].ID != 1 || candidates[3].ID != 4 {
+		t.Error("Select modified its input")
+	}
+}
@@ -2,76 +2,374 @@ package myservice
 
 import (
 	"context"
-	"reflect"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
 
 	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
 )
 
-//BannerService should supply interface to get banner/banners by size,website, group_id
-//And it's depend what param client send
-//condition1 : (group_id, size, en)
-//condition2 : (website, size, en)
-//condition3 : (UUID, website, size, en, )
-//condition3 : (UUID, website, size, en, )
-//condition3 : (UUID, website, size, en,website_title )
-//UUID should be get from transport like:
-//layer-http cookie or get param
-//gobuffer param token
-//thrift param token
-//Service will save all loading log and click log for specific UUID
-//Service will receive all stats and callback for all adv User
-//Service will save all tags for user
-//Banner tags, landing tags, attach to User tags
-//Or service should be split to 2 part or 3 parts
-//One is loading banner and calculate what banner to display
-//stats how much time user stay on the page and focus on Banners (should be heartbeat)
-//Client must get user whether active, and sent heartbeat every 3 seconds
-//It can help us to calculate put how much banners on it, and banner switch interval
+//AdService chooses banners for the visitors of client websites and tracks
+//what happens to them. Requests name the client directly or through its
+//website. The visitor comes from the transport: the cookie or uid parameter
+//over HTTP, the x-visitor-id metadata over gRPC and the visitor parameter
+//over Thrift. Served banners are logged as impressions (impression.go),
+//clicks go through RecordClick (click.go) and the time banners stay on
+//screen through Heartbeat (viewability.go).
 type AdService interface {
-	//GetBanner(ctx context.Context)
-	GetBanners(ctx context.Context)
+	GetBanner(ctx context.Context, id int) (models.Banner, error)
+	GetBanners(ctx context.Context, req BannerRequest) ([]Ad, error)
+	//RecordClick validates a click token, logs the click and returns the landing URL
+	RecordClick(ctx context.Context, token string) (string, error)
+	//Heartbeat reports that a served banner is still on screen, clients send it every 3 seconds
+	Heartbeat(ctx context.Context, req HeartbeatRequest) error
+	//Pacing reports how banners with a daily target are delivering today
+	Pacing(ctx context.Context) ([]PacingState, error)
+	//GetBannersBatch fills the ad slots of a page in one call
+	GetBannersBatch(ctx context.Context, req BannersRequest) ([]SlotAds, error)
+	//AdTag returns the configuration the JavaScript ad tag of a client is generated with
+	AdTag(ctx context.Context, clientID int) (AdTag, error)
+}
+
+//Ad is a banner chosen to be served, with the impression it was logged under
+type Ad struct {
+	models.Banner
+	ImpressionID string `json:"impression_id"`
+	//ClickURL is the tracking URL to link instead of URL, empty without click tracking
+	ClickURL string `json:"click_url,omitempty"`
+	//Locale is the language the banner was chosen for, empty for language neutral banners
+	Locale string `json:"locale,omitempty"`
+}
+
+var (
+	//ErrInvalidClient is returned when the client id is not positive
+	ErrInvalidClient = myerror.New(myerror.InvalidArgument, "invalid client id")
+	//ErrInvalidBanner is returned when the banner id is not positive
+	ErrInvalidBanner = myerror.New(myerror.InvalidArgument, "invalid banner id")
+	//ErrNotFound is returned when the banner or client does not exist
+	ErrNotFound = myerror.New(myerror.NotFound, "not found")
+	//ErrInvalidImpression is returned for heartbeats without impression or
+	//banner, or for an impression that was not served with the banner
+	ErrInvalidImpression = myerror.New(myerror.InvalidArgument, "invalid impression")
+	//ErrInvalidCount is returned when more than maxCount banners are asked for
+	ErrInvalidCount = myerror.New(myerror.InvalidArgument, "invalid count")
+)
+
+//maxCount bounds the banners of one request, and of the slots of one size
+//in a batch
+const maxCount = 50
+
+//storeError turns store errors into service errors. Errors the service does
+//not expect mean the store failed and are reported as unavailable.
+func storeError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case models.ErrNotFound:
+		return ErrNotFound
+	case models.ErrVersionConflict:
+		return ErrVersionConflict
+	case models.ErrInvalidTransition:
+		return ErrInvalidTransition
+	case models.ErrGroupInUse:
+		return ErrGroupInUse
+	}
+	return myerror.Wrap(myerror.Unavailable, err)
 }
 
 //BannerRequest convert request to struct BannerRequest
 type BannerRequest struct {
 	ClientID int    `p:"client_id"`
+	Website  string `p:"website"`
 	Size     string `p:"size"`
-	//Lang    string `json:"lang"`
+	//Match is how banners are matched against Size: exact (default), fit or aspect
+	Match string `p:"match"`
+	Lang  string `p:"lang"`
+	//AcceptLanguage is the Accept-Language header, used when Lang is empty
+	AcceptLanguage string
+	//Count is the number of distinct banners wanted, 1 when not positive and
+	//at most 50
+	Count int `p:"count"`
+	//Tags is the tag targeting mode: off, boost or require, empty for the service default
+	Tags string `p:"tags"`
 }
 
-//BannersRequest is
-type BannersRequest struct {
+//HeartbeatRequest reports the visibility of a served banner
+type HeartbeatRequest struct {
+	ImpressionID string `p:"impression_id"`
+	BannerID     int    `p:"banner_id"`
+	//Visible is whether the banner is on screen and the page has focus
+	Visible bool `p:"visible"`
 }
 
-type bannerService struct{}
+//Option configures the service returned by NewService
+type Option func(*bannerService)
 
-func (s bannerService) GetBanner(ctx context.Context) {
+//WithSelector makes the service choose banners with sel,
+//by default a Selector seeded from the clock is used
+func WithSelector(sel *Selector) Option {
+	return func(s *bannerService) {
+		s.selector = sel
+	}
+}
 
+//WithImpressions records every served banner to rec
+func WithImpressions(rec ImpressionRecorder) Option {
+	return func(s *bannerService) {
+		s.impressions = rec
+	}
 }
 
-//GetBanners is func
-func GetBanners(ctx context.Context, clientID int, size string) ([]*models.Banner, error) {
-	var banners []*models.Banner
-	if clientID <= 0 {
-		return banners, nil // create error
+//WithClickTracking gives every served banner a tracking URL signed by signer.
+//baseURL is prefixed to the /v1/click/{token} path, it may be empty for
+//relative links. The signer also signs impression IDs, heartbeats for
+//impressions it did not sign are rejected.
+func WithClickTracking(signer *ClickSigner, baseURL string) Option {
+	return func(s *bannerService) {
+		s.clicks = signer
+		s.clickBase = strings.TrimSuffix(baseURL, "/")
 	}
-	groupId := models.GetBannerGroupByClient(clientID)
+}
 
-	banners, err := GetBanners(size, groupId)
-	return banners, err
+//WithHeartbeats hands every heartbeat to rec, usually a ViewabilityAggregator
+func WithHeartbeats(rec HeartbeatRecorder) Option {
+	return func(s *bannerService) {
+		s.heartbeats = rec
+	}
 }
 
-func (s bannerService) getPopularBanner(ctx context.Context) {
+//WithLanguages resolves request languages through langs, by default only
+//the exact language asked for is served
+func WithLanguages(langs *Languages) Option {
+	return func(s *bannerService) {
+		s.languages = langs
+	}
+}
 
+//WithLogger logs the failures the service works around instead of failing
+//the request, by default they are discarded
+func WithLogger(logger log.Logger) Option {
+	return func(s *bannerService) {
+		s.logger = logger
+	}
 }
 
-// Check a variable if empty or not
-func isEmpty(a interface{}) bool {
-	v := reflect.ValueOf(a)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
+//NewService returns an AdService reading banners from store
+func NewService(store models.Store, options ...Option) AdService {
+	s := bannerService{store: store}
+	for _, option := range options {
+		option(&s)
+	}
+	if s.selector == nil {
+		s.selector = newTimeSelector()
+	}
+	if s.impressions == nil {
+		s.impressions = nopRecorder{}
+	}
+	if s.heartbeats == nil {
+		s.heartbeats = nopHeartbeats{}
+	}
+	if s.campaigns == nil {
+		s.campaigns = storeCampaigns{store}
+	}
+	if s.languages == nil {
+		s.languages = NewLanguages(nil, "")
+	}
+	if s.logger == nil {
+		s.logger = log.NewNopLogger()
+	}
+	if s.adTag.HeartbeatInterval <= 0 {
+		s.adTag.HeartbeatInterval = 3 * time.Second
 	}
-	return v.Interface() == reflect.Zero(v.Type()).Interface()
+	if s.now == nil {
+		s.now = time.Now
+	}
+	return s
 }
 
-//BannerLog url, banner_id
+type bannerService struct {
+	store       models.Store
+	selector    *Selector
+	impressions ImpressionRecorder
+	heartbeats  HeartbeatRecorder
+	campaigns   CampaignRecorder
+	clicks      *ClickSigner
+	clickBase   string
+
+	defaultGroup int
+	languages    *Languages
+
+	profiles *TagProfiles
+	tagMode  string
+	tagBoost float64
+
+	capper *FrequencyCapper
+	pacer  *Pacer
+	adTag  AdTagOptions
+	logger log.Logger
+	now    func() time.Time
+}
+
+//GetBanner returns a single banner by id
+func (s bannerService) GetBanner(ctx context.Context, id int) (models.Banner, error) {
+	if id <= 0 {
+		return models.Banner{}, ErrInvalidBanner
+	}
+	banner, err := s.store.GetBannerByID(ctx, id)
+	return banner, storeError(err)
+}
+
+//GetBanners chooses req.Count distinct banners with the given size from the
+//group of the requesting client or website
+func (s bannerService) GetBanners(ctx context.Context, req BannerRequest) ([]Ad, error) {
+	var ads []Ad
+	if req.Count > maxCount {
+		return ads, ErrInvalidCount
+	}
+	size, err := models.ParseSize(req.Size)
+	if err != nil {
+		return ads, ErrInvalidSize
+	}
+	clientID, groupID, err := s.resolveGroup(ctx, req)
+	if err != nil {
+		return ads, err
+	}
+	candidates, err := s.store.GetBannersByGroup(ctx, groupID)
+	if err != nil {
+		return ads, storeError(err)
+	}
+	now := s.now()
+	candidates, err = s.servable(ctx, candidates, now)
+	if err != nil {
+		return ads, storeError(err)
+	}
+	candidates, err = matchSize(candidates, size, req.Match)
+	if err != nil {
+		return ads, err
+	}
+	preferred := []string{req.Lang}
+	if req.Lang == "" {
+		preferred = ParseAcceptLanguage(req.AcceptLanguage)
+	}
+	candidates, locale := pickLanguage(candidates, s.languages.Chain(preferred...))
+	visitor := VisitorFromContext(ctx)
+	originals := make(map[int]*models.Banner, len(candidates))
+	for _, b := range candidates {
+		originals[b.ID] = b
+	}
+	capped := s.capper != nil && visitor.ID != "" && !visitor.Ephemeral
+	if capped {
+		if candidates, err = s.capper.Filter(ctx, visitor.ID, candidates); err != nil {
+			return ads, storeError(err)
+		}
+	}
+	if s.pacer != nil {
+		candidates = s.pacer.Filter(candidates)
+	}
+	candidates, err = s.targetTags(ctx, candidates, req.Tags, visitor)
+	if err != nil {
+		return ads, storeError(err)
+	}
+	count := req.Count
+	if count <= 0 {
+		count = 1
+	}
+	transport := TransportFromContext(ctx)
+	for _, b := range s.selector.Select(candidates, count) {
+		b = originals[b.ID]
+		ad := Ad{Banner: *b, Locale: locale}
+		if s.clicks == nil {
+			ad.ImpressionID = newID()
+		} else {
+			ad.ImpressionID = s.clicks.ImpressionID(b.ID)
+			ad.ClickURL = s.clickBase + "/v1/click/" + s.clicks.Sign(ClickToken{
+				BannerID:     b.ID,
+				ClientID:     clientID,
+				VisitorID:    visitor.ID,
+				ImpressionID: ad.ImpressionID,
+			})
+		}
+		s.impressions.Record(models.BannerLog{
+			ImpressionID: ad.ImpressionID,
+			BannerID:     b.ID,
+			ClientID:     clientID,
+			Size:         b.Size,
+			Language:     b.Language,
+			VisitorID:    visitor.ID,
+			Transport:    transport,
+			Date:         int(now.Unix()),
+		})
+		if s.profiles != nil && !visitor.Ephemeral {
+			s.profiles.Impression(visitor.ID, b.Tags)
+		}
+		if capped {
+			//The banner is served already, a lost count only lets it
+			//through its cap once more
+			if err := s.capper.Count(ctx, visitor.ID, b); err != nil {
+				s.logger.Log("banner", b.ID, "visitor", visitor.ID, "during", "frequency count", "err", err)
+			}
+		}
+		if s.pacer != nil {
+			s.pacer.Served(b)
+		}
+		if b.CampaignID != 0 {
+			s.campaigns.Served(b.CampaignID, now)
+		}
+		ads = append(ads, ad)
+	}
+	return ads, nil
+}
+
+//RecordClick implements AdService
+func (s bannerService) RecordClick(ctx context.Context, token string) (string, error) {
+	if s.clicks == nil {
+		return "", ErrInvalidToken
+	}
+	t, err := s.clicks.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	banner, err := s.store.GetBannerByID(ctx, t.BannerID)
+	if err != nil {
+		return "", storeError(err)
+	}
+	err = s.store.InsertClickLog(ctx, models.ClickLog{
+		ImpressionID: t.ImpressionID,
+		BannerID:     t.BannerID,
+		ClientID:     t.ClientID,
+		VisitorID:    t.VisitorID,
+		Date:         int(s.now().Unix()),
+	})
+	if err != nil {
+		return "", storeError(err)
+	}
+	if s.profiles != nil && !VisitorFromContext(ctx).Ephemeral {
+		s.profiles.Click(t.VisitorID, append(append([]string{}, banner.Tags...), banner.LandingTags...))
+	}
+	return banner.URL, nil
+}
+
+//Heartbeat implements AdService
+func (s bannerService) Heartbeat(ctx context.Context, req HeartbeatRequest) error {
+	if req.ImpressionID == "" || req.BannerID <= 0 {
+		return ErrInvalidImpression
+	}
+	if s.clicks != nil && !s.clicks.VerifyImpression(req.ImpressionID, req.BannerID) {
+		return ErrInvalidImpression
+	}
+	s.heartbeats.Beat(req.ImpressionID, req.BannerID, req.Visible)
+	return nil
+}
+
+//newID returns a random 128 bit identifier in hex
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
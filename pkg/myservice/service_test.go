@@ -0,0 +1,200 @@
+package myservice
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+func newTestService() AdService {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", URL: "http://a.example", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", URL: "http://b.example", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 3, GroupID: 2, Size: "40*50", URL: "http://c.example", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	return NewService(store, WithSelector(NewSelector(1)))
+}
+
+func TestGetBanners(t *testing.T) {
+	svc := newTestService()
+	banners, err := svc.GetBanners(context.Background(), BannerRequest{ClientID: 10, Size: "40*50", Count: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banners) != 2 {
+		t.Errorf("want 2 banners, got %d", len(banners))
+	}
+	banners, err = svc.GetBanners(context.Background(), BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banners) != 1 {
+		t.Errorf("want 1 banner by default, got %d", len(banners))
+	}
+}
+
+func TestGetBannersErrors(t *testing.T) {
+	svc := newTestService()
+	if _, err := svc.GetBanners(context.Background(), BannerRequest{Size: "40*50"}); err != ErrInvalidClient {
+		t.Errorf("want ErrInvalidClient, got %v", err)
+	}
+	if _, err := svc.GetBanners(context.Background(), BannerRequest{ClientID: 99, Size: "40*50"}); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := svc.GetBanners(context.Background(), BannerRequest{ClientID: 10, Size: "40*50", Count: math.MaxInt64}); err != ErrInvalidCount {
+		t.Errorf("want ErrInvalidCount, got %v", err)
+	}
+}
+
+//failingStore fails like a MySQL store that lost its connection
+type failingStore struct {
+	*models.MemoryStore
+}
+
+func (failingStore) GetBannersByGroup(ctx context.Context, groupID int) ([]*models.Banner, error) {
+	return nil, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+}
+
+func TestGetBannersStoreUnavailable(t *testing.T) {
+	store := failingStore{models.NewMemoryStore()}
+	store.SetClientGroup(10, 1)
+	_, err := NewService(store).GetBanners(context.Background(), BannerRequest{ClientID: 10, Size: "40*50"})
+	if myerror.CodeOf(err) != myerror.Unavailable {
+		t.Errorf("want unavailable, got %v", err)
+	}
+}
+
+func TestGetBanner(t *testing.T) {
+	svc := newTestService()
+	b, err := svc.GetBanner(context.Background(), 3)
+	if err != nil || b.ID != 3 {
+		t.Errorf("want banner 3, got %v (%v)", b, err)
+	}
+	if _, err := svc.GetBanner(context.Background(), 42); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
+
+type recorderFunc func(models.BannerLog)
+
+func (f recorderFunc) Record(l models.BannerLog) { f(l) }
+
+func TestGetBannersRecordsImpressions(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Language: "en", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	var logs []models.BannerLog
+	svc := NewService(store, WithImpressions(recorderFunc(func(l models.BannerLog) {
+		logs = append(logs, l)
+	})))
+	ctx := WithTransport(context.Background(), "test")
+	ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil || len(ads) != 1 {
+		t.Fatalf("want 1 ad, got %v (%v)", ads, err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("want 1 impression, got %d", len(logs))
+	}
+	l := logs[0]
+	if l.ImpressionID != ads[0].ImpressionID || l.BannerID != 1 || l.ClientID != 10 || l.Language != "en" || l.Transport != "test" || l.Date == 0 {
+		t.Errorf("unexpected impression %+v", l)
+	}
+}
+
+func TestRecordClick(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", URL: "http://landing.example", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	signer := NewClickSigner([]byte("secret"), time.Hour)
+	svc := NewService(store, WithClickTracking(signer, "http://ads.example/"))
+	ctx := context.Background()
+
+	ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil || len(ads) != 1 {
+		t.Fatalf("want 1 ad, got %v (%v)", ads, err)
+	}
+	prefix := "http://ads.example/v1/click/"
+	if !strings.HasPrefix(ads[0].ClickURL, prefix) {
+		t.Fatalf("unexpected click url %q", ads[0].ClickURL)
+	}
+	landing, err := svc.RecordClick(ctx, strings.TrimPrefix(ads[0].ClickURL, prefix))
+	if err != nil || landing != "http://landing.example" {
+		t.Fatalf("want landing url, got %q (%v)", landing, err)
+	}
+	clicks := store.ClickLogs()
+	if len(clicks) != 1 || clicks[0].BannerID != 1 || clicks[0].ClientID != 10 || clicks[0].ImpressionID != ads[0].ImpressionID {
+		t.Errorf("unexpected clicks %+v", clicks)
+	}
+	if _, err := svc.RecordClick(ctx, "forged.token"); err != ErrInvalidToken {
+		t.Errorf("want ErrInvalidToken, got %v", err)
+	}
+}
+
+type heartbeatFunc func(impressionID string, bannerID int, visible bool)
+
+func (f heartbeatFunc) Beat(impressionID string, bannerID int, visible bool) {
+	f(impressionID, bannerID, visible)
+}
+
+func TestHeartbeatChecksImpression(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	var beats []string
+	svc := NewService(store,
+		WithClickTracking(NewClickSigner([]byte("secret"), time.Hour), ""),
+		WithHeartbeats(heartbeatFunc(func(impressionID string, bannerID int, visible bool) {
+			beats = append(beats, impressionID)
+		})),
+	)
+	ctx := context.Background()
+	ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil || len(ads) != 1 {
+		t.Fatalf("want 1 ad, got %v (%v)", ads, err)
+	}
+	if err := svc.Heartbeat(ctx, HeartbeatRequest{ImpressionID: ads[0].ImpressionID, BannerID: 1, Visible: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []HeartbeatRequest{
+		{ImpressionID: "made-up", BannerID: 1},
+		{ImpressionID: newID(), BannerID: 1},
+		{ImpressionID: ads[0].ImpressionID, BannerID: 2},
+	} {
+		if err := svc.Heartbeat(ctx, req); err != ErrInvalidImpression {
+			t.Errorf("%+v: want ErrInvalidImpression, got %v", req, err)
+		}
+	}
+	if len(beats) != 1 || beats[0] != ads[0].ImpressionID {
+		t.Errorf("want only the served impression recorded, got %v", beats)
+	}
+}
+
+func TestGetBannersUsesVisitor(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	var logs []models.BannerLog
+	signer := NewClickSigner([]byte("secret"), time.Hour)
+	svc := NewService(store,
+		WithClickTracking(signer, ""),
+		WithImpressions(recorderFunc(func(l models.BannerLog) { logs = append(logs, l) })),
+	)
+	ctx := WithVisitor(context.Background(), Visitor{ID: "visitor-1"})
+	ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: "40*50"})
+	if err != nil || len(ads) != 1 {
+		t.Fatalf("want 1 ad, got %v (%v)", ads, err)
+	}
+	if len(logs) != 1 || logs[0].VisitorID != "visitor-1" {
+		t.Errorf("impression without visitor: %+v", logs)
+	}
+	token, err := signer.Verify(strings.TrimPrefix(ads[0].ClickURL, "/v1/click/"))
+	if err != nil || token.VisitorID != "visitor-1" {
+		t.Errorf("click token without visitor: %+v (%v)", token, err)
+	}
+}
@@ -0,0 +1,46 @@
+package myservice
+
+import (
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+//Size match modes of BannerRequest.Match
+const (
+	//MatchExact serves banners of exactly the requested size
+	MatchExact = "exact"
+	//MatchFit serves banners fitting inside the requested size, for responsive slots
+	MatchFit = "fit"
+	//MatchAspect serves banners of any size with the requested aspect ratio
+	MatchAspect = "aspect"
+)
+
+var (
+	//ErrInvalidSize is returned when the requested size cannot be parsed
+	ErrInvalidSize = myerror.New(myerror.InvalidArgument, "invalid size")
+	//ErrInvalidMatch is returned for an unknown size match mode
+	ErrInvalidMatch = myerror.New(myerror.InvalidArgument, "invalid size match mode")
+)
+
+//matchSize returns the candidates whose size matches want under mode.
+//Banners with a malformed size never match.
+func matchSize(candidates []*models.Banner, want models.Size, mode string) ([]*models.Banner, error) {
+	var match func(models.Size) bool
+	switch mode {
+	case "", MatchExact:
+		match = func(s models.Size) bool { return s == want }
+	case MatchFit:
+		match = func(s models.Size) bool { return s.Fits(want) }
+	case MatchAspect:
+		match = func(s models.Size) bool { return s.SameAspect(want) }
+	default:
+		return nil, ErrInvalidMatch
+	}
+	var matched []*models.Banner
+	for _, b := range candidates {
+		if size, err := b.ParsedSize(); err == nil && match(size) {
+			matched = append(matched, b)
+		}
+	}
+	return matched, nil
+}
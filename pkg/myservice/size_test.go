@@ -0,0 +1,54 @@
+package myservice
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"jf/adservice/models"
+)
+
+func TestGetBannersSizeMatch(t *testing.T) {
+	store := models.NewMemoryStore()
+	for id, size := range map[int]string{1: "300*250", 2: "300x250", 3: "120*60", 4: "600*500", 5: "728*90", 6: "junk"} {
+		store.PutBanner(models.Banner{ID: id, GroupID: 1, Size: size, Status: models.StatusActive})
+	}
+	store.SetClientGroup(10, 1)
+	svc := NewService(store)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		size, match string
+		want        []int
+		err         error
+	}{
+		{"300*250", "", []int{1, 2}, nil},
+		{"medium-rectangle", MatchExact, []int{1, 2}, nil},
+		{"300x250", MatchFit, []int{1, 2, 3}, nil},
+		{"300x250", MatchAspect, []int{1, 2, 4}, nil},
+		{"", "", nil, ErrInvalidSize},
+		{"huge", "", nil, ErrInvalidSize},
+		{"300x250", "nearest", nil, ErrInvalidMatch},
+	} {
+		ads, err := svc.GetBanners(ctx, BannerRequest{ClientID: 10, Size: tc.size, Match: tc.match, Count: 10})
+		if err != tc.err {
+			t.Errorf("%s/%s: want error %v, got %v", tc.size, tc.match, tc.err, err)
+			continue
+		}
+		var got []int
+		for _, ad := range ads {
+			got = append(got, ad.ID)
+		}
+		sort.Ints(got)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s/%s: want %v, got %v", tc.size, tc.match, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s/%s: want %v, got %v", tc.size, tc.match, tc.want, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package myservice
+
+import (
+	"context"
+
+	"jf/adservice/pkg/myerror"
+)
+
+//maxSlots bounds the slots of one BannersRequest
+const maxSlots = 20
+
+//ErrInvalidSlots is returned for batches without slots or with too many, or
+//asking for more than maxCount banners of one size
+var ErrInvalidSlots = myerror.New(myerror.InvalidArgument, "invalid slots")
+
+//BannersRequest asks for the banners of the ad slots of a page in one call.
+//Client, website, language and tag mode apply to every slot, a slot may
+//override the language.
+type BannersRequest struct {
+	ClientID int    `json:"client_id"`
+	Website  string `json:"website"`
+	Lang     string `json:"lang"`
+	//AcceptLanguage is the Accept-Language header, used when no language is set
+	AcceptLanguage string `json:"-"`
+	Tags           string `json:"tags"`
+	Slots          []Slot `json:"slots"`
+}
+
+//Slot is an ad slot of a page
+type Slot struct {
+	//ID names the slot in the response, it is chosen by the caller
+	ID    string `json:"id"`
+	Size  string `json:"size"`
+	Match string `json:"match"`
+	Lang  string `json:"lang"`
+	//Count is the number of distinct banners wanted, 1 when not positive
+	Count int `json:"count"`
+}
+
+//SlotAds are the banners chosen for a slot, Error is the message of the
+//error that left it empty
+type SlotAds struct {
+	ID      string `json:"id"`
+	Banners []Ad   `json:"banners"`
+	Error   string `json:"error,omitempty"`
+}
+
+//slotGroup is the slots of a batch filled by one GetBanners call
+type slotGroup struct {
+	req   BannerRequest
+	slots []int
+}
+
+//GetBannersBatch implements AdService. Slots with the same size, match and
+//language are filled by one GetBanners call, so they never show the same
+//banner. Errors of the request, like an invalid size, are reported per
+//slot, only failures of the store fail the whole batch.
+func (s bannerService) GetBannersBatch(ctx context.Context, req BannersRequest) ([]SlotAds, error) {
+	if len(req.Slots) == 0 || len(req.Slots) > maxSlots {
+		return nil, ErrInvalidSlots
+	}
+	var groups []*slotGroup
+	byKey := map[[3]string]*slotGroup{}
+	results := make([]SlotAds, len(req.Slots))
+	for i, slot := range req.Slots {
+		results[i] = SlotAds{ID: slot.ID, Banners: []Ad{}}
+		lang := slot.Lang
+		if lang == "" {
+			lang = req.Lang
+		}
+		key := [3]string{slot.Size, slot.Match, lang}
+		g, ok := byKey[key]
+		if !ok {
+			g = &slotGroup{req: BannerRequest{
+				ClientID:       req.ClientID,
+				Website:        req.Website,
+				Size:           slot.Size,
+				Match:          slot.Match,
+				Lang:           lang,
+				AcceptLanguage: req.AcceptLanguage,
+				Tags:           req.Tags,
+			}}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		if slot.Count > maxCount || g.req.Count+slotCount(slot) > maxCount {
+			return nil, ErrInvalidSlots
+		}
+		g.req.Count += slotCount(slot)
+		g.slots = append(g.slots, i)
+	}
+	for _, g := range groups {
+		ads, err := s.GetBanners(ctx, g.req)
+		if err != nil {
+			if code := myerror.CodeOf(err); code == myerror.Internal || code == myerror.Unavailable {
+				return nil, err
+			}
+			for _, i := range g.slots {
+				results[i].Error = myerror.Message(err)
+			}
+			continue
+		}
+		for _, i := range g.slots {
+			n := slotCount(req.Slots[i])
+			if n > len(ads) {
+				n = len(ads)
+			}
+			results[i].Banners = append(results[i].Banners, ads[:n]...)
+			ads = ads[n:]
+		}
+	}
+	return results, nil
+}
+
+func slotCount(slot Slot) int {
+	if slot.Count <= 0 {
+		return 1
+	}
+	return slot.Count
+}
@@ -0,0 +1,55 @@
+package myservice
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+func TestGetBannersBatch(t *testing.T) {
+	svc := newTestService()
+	slots, err := svc.GetBannersBatch(context.Background(), BannersRequest{ClientID: 10, Slots: []Slot{
+		{ID: "top", Size: "40*50"},
+		{ID: "side", Size: "40*50"},
+		{ID: "bad", Size: "huge"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 3 || slots[0].ID != "top" || slots[1].ID != "side" || slots[2].ID != "bad" {
+		t.Fatalf("unexpected slots %+v", slots)
+	}
+	if len(slots[0].Banners) != 1 || len(slots[1].Banners) != 1 || slots[0].Banners[0].ID == slots[1].Banners[0].ID {
+		t.Errorf("want distinct banners in slots of the same size, got %+v", slots[:2])
+	}
+	if len(slots[2].Banners) != 0 || slots[2].Error == "" {
+		t.Errorf("want an error for the invalid size, got %+v", slots[2])
+	}
+}
+
+func TestGetBannersBatchErrors(t *testing.T) {
+	svc := newTestService()
+	if _, err := svc.GetBannersBatch(context.Background(), BannersRequest{ClientID: 10}); err != ErrInvalidSlots {
+		t.Errorf("want ErrInvalidSlots without slots, got %v", err)
+	}
+	if _, err := svc.GetBannersBatch(context.Background(), BannersRequest{ClientID: 10, Slots: make([]Slot, maxSlots+1)}); err != ErrInvalidSlots {
+		t.Errorf("want ErrInvalidSlots for %d slots, got %v", maxSlots+1, err)
+	}
+	for _, slots := range [][]Slot{
+		{{ID: "top", Size: "40*50", Count: math.MaxInt64}},
+		{{ID: "top", Size: "40*50", Count: maxCount}, {ID: "side", Size: "40*50"}},
+	} {
+		if _, err := svc.GetBannersBatch(context.Background(), BannersRequest{ClientID: 10, Slots: slots}); err != ErrInvalidSlots {
+			t.Errorf("want ErrInvalidSlots for %+v, got %v", slots, err)
+		}
+	}
+	store := failingStore{models.NewMemoryStore()}
+	store.SetClientGroup(10, 1)
+	_, err := NewService(store).GetBannersBatch(context.Background(), BannersRequest{ClientID: 10, Slots: []Slot{{ID: "top", Size: "40*50"}}})
+	if myerror.CodeOf(err) != myerror.Unavailable {
+		t.Errorf("want the batch unavailable, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package myservice
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"jf/adservice/models"
+)
+
+//AdTagOptions tunes the JavaScript ad tags of all clients
+type AdTagOptions struct {
+	//HeartbeatInterval is how often tags report visible banners, 3s by default
+	HeartbeatInterval time.Duration
+	//RotateInterval is how long a visible slot shows its banners before the
+	//tag fetches new ones, 0 disables rotation
+	RotateInterval time.Duration
+	//MaxAge is how long browsers and proxies may cache a tag
+	MaxAge time.Duration
+}
+
+//AdTag is what the JavaScript ad tag of a client is generated with
+type AdTag struct {
+	AdTagOptions
+	ClientID int
+	//Sizes are the sizes of the banners the client can be served right now,
+	//formatted like "300*250". The tag does not ask for other sizes.
+	Sizes []string
+}
+
+//WithAdTag sets the options of the ad tags returned by AdTag
+func WithAdTag(o AdTagOptions) Option {
+	return func(s *bannerService) {
+		s.adTag = o
+	}
+}
+
+//AdTag implements AdService
+func (s bannerService) AdTag(ctx context.Context, clientID int) (AdTag, error) {
+	if clientID <= 0 {
+		return AdTag{}, ErrInvalidClient
+	}
+	groupID, err := s.clientGroup(ctx, clientID)
+	if err != nil {
+		return AdTag{}, err
+	}
+	banners, err := s.store.GetBannersByGroup(ctx, groupID)
+	if err != nil {
+		return AdTag{}, storeError(err)
+	}
+	banners, err = s.servable(ctx, banners, s.now())
+	if err != nil {
+		return AdTag{}, storeError(err)
+	}
+	sizes := []string{}
+	seen := map[string]bool{}
+	for _, b := range banners {
+		size, err := models.ParseSize(b.Size)
+		if err != nil || seen[size.String()] {
+			continue
+		}
+		seen[size.String()] = true
+		sizes = append(sizes, size.String())
+	}
+	sort.Strings(sizes)
+	return AdTag{AdTagOptions: s.adTag, ClientID: clientID, Sizes: sizes}, nil
+}
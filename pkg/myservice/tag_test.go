@@ -0,0 +1,36 @@
+package myservice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"jf/adservice/models"
+)
+
+func TestAdTag(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "300*250", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "300x250", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 3, GroupID: 1, Size: "40*50", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 4, GroupID: 1, Size: "728*90", Status: 0})
+	store.SetClientGroup(10, 1)
+	svc := NewService(store, WithAdTag(AdTagOptions{RotateInterval: time.Minute}))
+	tag, err := svc.AdTag(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"300*250", "40*50"}; !reflect.DeepEqual(tag.Sizes, want) {
+		t.Errorf("want sizes %v, got %v", want, tag.Sizes)
+	}
+	if tag.ClientID != 10 || tag.RotateInterval != time.Minute || tag.HeartbeatInterval != 3*time.Second {
+		t.Errorf("unexpected tag %+v", tag)
+	}
+	if _, err := svc.AdTag(context.Background(), 0); err != ErrInvalidClient {
+		t.Errorf("want ErrInvalidClient, got %v", err)
+	}
+	if _, err := svc.AdTag(context.Background(), 99); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package myservice
+
+import (
+	"context"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myerror"
+)
+
+//ErrInvalidWebsite is returned when a website does not belong to the requesting client
+var ErrInvalidWebsite = myerror.New(myerror.InvalidArgument, "website does not belong to client")
+
+//WithDefaultGroup serves groupID on websites that are not registered.
+//Without it requests for unknown websites fail with ErrNotFound.
+func WithDefaultGroup(groupID int) Option {
+	return func(s *bannerService) {
+		s.defaultGroup = groupID
+	}
+}
+
+//resolveGroup finds the client and banner group a request is served from.
+//condition1 : (client, size) uses the client's group
+//condition2 : (website, size) uses the website's group, or its client's
+//group, or the default group for unknown websites
+func (s bannerService) resolveGroup(ctx context.Context, req BannerRequest) (clientID, groupID int, err error) {
+	if req.Website == "" {
+		if req.ClientID <= 0 {
+			return 0, 0, ErrInvalidClient
+		}
+		groupID, err = s.clientGroup(ctx, req.ClientID)
+		return req.ClientID, groupID, err
+	}
+	if req.ClientID < 0 {
+		return 0, 0, ErrInvalidClient
+	}
+	website, err := s.store.GetWebsiteByDomain(ctx, req.Website)
+	if err == models.ErrNotFound {
+		if s.defaultGroup <= 0 {
+			return 0, 0, ErrNotFound
+		}
+		return req.ClientID, s.defaultGroup, nil
+	}
+	if err != nil {
+		return 0, 0, storeError(err)
+	}
+	if req.ClientID != 0 && req.ClientID != website.ClientID {
+		return 0, 0, ErrInvalidWebsite
+	}
+	if website.GroupID > 0 {
+		return website.ClientID, website.GroupID, nil
+	}
+	groupID, err = s.clientGroup(ctx, website.ClientID)
+	return website.ClientID, groupID, err
+}
+
+func (s bannerService) clientGroup(ctx context.Context, clientID int) (int, error) {
+	groupID, err := s.store.GetBannerGroupByClient(ctx, clientID)
+	return groupID, storeError(err)
+}
@@ -0,0 +1,53 @@
+package myservice
+
+import (
+	"context"
+	"testing"
+
+	"jf/adservice/models"
+)
+
+func TestGetBannersByWebsite(t *testing.T) {
+	store := models.NewMemoryStore()
+	for id, group := range map[int]int{1: 1, 2: 2, 3: 3} {
+		store.PutBanner(models.Banner{ID: id, GroupID: group, Size: "40*50", Status: models.StatusActive})
+	}
+	store.SetClientGroup(10, 1)
+	store.PutWebsite(models.Website{ID: 1, Domain: "news.example", ClientID: 10, GroupID: 2})
+	store.PutWebsite(models.Website{ID: 2, Domain: "blog.example", ClientID: 10})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		svc    AdService
+		req    BannerRequest
+		banner int
+		client int
+		err    error
+	}{
+		{"website group", NewService(store), BannerRequest{Website: "https://www.news.example/x", Size: "40*50"}, 2, 10, nil},
+		{"client group", NewService(store), BannerRequest{Website: "blog.example", Size: "40*50"}, 1, 10, nil},
+		{"matching client", NewService(store), BannerRequest{ClientID: 10, Website: "news.example", Size: "40*50"}, 2, 10, nil},
+		{"other client", NewService(store), BannerRequest{ClientID: 11, Website: "news.example", Size: "40*50"}, 0, 0, ErrInvalidWebsite},
+		{"unknown", NewService(store), BannerRequest{Website: "unknown.example", Size: "40*50"}, 0, 0, ErrNotFound},
+		{"default group", NewService(store, WithDefaultGroup(3)), BannerRequest{Website: "unknown.example", Size: "40*50"}, 3, 0, nil},
+	} {
+		var logs []models.BannerLog
+		svc := tc.svc.(bannerService)
+		svc.impressions = recorderFunc(func(l models.BannerLog) { logs = append(logs, l) })
+		ads, err := svc.GetBanners(ctx, tc.req)
+		if err != tc.err {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(ads) != 1 || ads[0].ID != tc.banner {
+			t.Errorf("%s: want banner %d, got %+v", tc.name, tc.banner, ads)
+		}
+		if len(logs) != 1 || logs[0].ClientID != tc.client {
+			t.Errorf("%s: want impression for client %d, got %+v", tc.name, tc.client, logs)
+		}
+	}
+}
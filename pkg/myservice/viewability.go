@@ -0,0 +1,179 @@
+package myservice
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+)
+
+//HeartbeatRecorder receives heartbeats of served banners. Beat must not block.
+type HeartbeatRecorder interface {
+	Beat(impressionID string, bannerID int, visible bool)
+}
+
+type nopHeartbeats struct{}
+
+func (nopHeartbeats) Beat(string, int, bool) {}
+
+//ViewabilityConfig tunes a ViewabilityAggregator
+type ViewabilityConfig struct {
+	//HeartbeatInterval is how often clients send heartbeats, 3s by the client contract.
+	//Visible time between two heartbeats is capped at twice this interval.
+	HeartbeatInterval time.Duration
+	//IdleTimeout ends a session that has not sent a heartbeat for this long
+	IdleTimeout time.Duration
+	//ViewableThreshold is the visible time after which an impression counts as viewable
+	ViewableThreshold time.Duration
+	//MaxSessions bounds memory, heartbeats of new sessions are ignored above it
+	MaxSessions int
+}
+
+//viewSession tracks the heartbeats of one impression
+type viewSession struct {
+	bannerID int
+	started  time.Time
+	last     time.Time
+	visible  bool
+	viewable time.Duration
+}
+
+//ViewabilityAggregator turns heartbeats into viewable time per impression
+//and writes per banner daily totals to a ViewabilityStore once sessions end.
+type ViewabilityAggregator struct {
+	store  models.ViewabilityStore
+	logger log.Logger
+	cfg    ViewabilityConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*viewSession
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+//NewViewabilityAggregator starts an aggregator that ends idle sessions in the background
+func NewViewabilityAggregator(store models.ViewabilityStore, logger log.Logger, cfg ViewabilityConfig) *ViewabilityAggregator {
+	a := newViewabilityAggregator(store, logger, cfg, time.Now)
+	go a.run()
+	return a
+}
+
+func newViewabilityAggregator(store models.ViewabilityStore, logger log.Logger, cfg ViewabilityConfig, now func() time.Time) *ViewabilityAggregator {
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 3 * time.Second
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 10 * cfg.HeartbeatInterval
+	}
+	if cfg.ViewableThreshold <= 0 {
+		cfg.ViewableThreshold = time.Second
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 100000
+	}
+	return &ViewabilityAggregator{
+		store:    store,
+		logger:   logger,
+		cfg:      cfg,
+		now:      now,
+		sessions: make(map[string]*viewSession),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+//Beat implements HeartbeatRecorder. The first heartbeat of an impression
+//starts its session, later ones add the time since the previous heartbeat
+//to the viewable time if the banner was visible then.
+func (a *ViewabilityAggregator) Beat(impressionID string, bannerID int, visible bool) {
+	now := a.now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[impressionID]
+	if !ok {
+		if len(a.sessions) >= a.cfg.MaxSessions {
+			return
+		}
+		a.sessions[impressionID] = &viewSession{bannerID: bannerID, started: now, last: now, visible: visible}
+		return
+	}
+	if s.bannerID != bannerID {
+		return
+	}
+	if s.visible {
+		gap := now.Sub(s.last)
+		if limit := 2 * a.cfg.HeartbeatInterval; gap > limit {
+			gap = limit
+		}
+		s.viewable += gap
+	}
+	s.last = now
+	s.visible = visible
+}
+
+//Close ends every open session and writes the totals
+func (a *ViewabilityAggregator) Close(ctx context.Context) error {
+	close(a.stop)
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *ViewabilityAggregator) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.sweep(false)
+		case <-a.stop:
+			a.sweep(true)
+			return
+		}
+	}
+}
+
+//sweep ends sessions idle for longer than IdleTimeout, or all of them, and
+//writes their totals to the store
+func (a *ViewabilityAggregator) sweep(all bool) {
+	now := a.now()
+	totals := map[[2]int]*models.BannerViewability{}
+	a.mu.Lock()
+	for id, s := range a.sessions {
+		if !all && now.Sub(s.last) <= a.cfg.IdleTimeout {
+			continue
+		}
+		delete(a.sessions, id)
+		day := int(s.started.UTC().Truncate(24 * time.Hour).Unix())
+		key := [2]int{s.bannerID, day}
+		t, ok := totals[key]
+		if !ok {
+			t = &models.BannerViewability{BannerID: s.bannerID, Day: day}
+			totals[key] = t
+		}
+		t.Sessions++
+		if s.viewable >= a.cfg.ViewableThreshold {
+			t.ViewableSessions++
+		}
+		t.ViewableMillis += int64(s.viewable / time.Millisecond)
+	}
+	a.mu.Unlock()
+	if len(totals) == 0 {
+		return
+	}
+	records := make([]models.BannerViewability, 0, len(totals))
+	for _, t := range totals {
+		records = append(records, *t)
+	}
+	if err := a.store.AddViewability(context.Background(), records); err != nil {
+		a.logger.Log("component", "viewability", "records", len(records), "err", err)
+	}
+}
@@ -0,0 +1,73 @@
+package myservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+)
+
+func TestViewabilityAggregator(t *testing.T) {
+	store := models.NewMemoryStore()
+	now := time.Date(2017, 11, 20, 10, 0, 0, 0, time.UTC)
+	a := newViewabilityAggregator(store, log.NewNopLogger(), ViewabilityConfig{
+		HeartbeatInterval: 3 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}, func() time.Time { return now })
+
+	// impression a: visible for 6s, hidden for 3s, then 20s gap capped to 6s
+	a.Beat("a", 1, true)
+	now = now.Add(3 * time.Second)
+	a.Beat("a", 1, true)
+	now = now.Add(3 * time.Second)
+	a.Beat("a", 1, false)
+	now = now.Add(3 * time.Second)
+	a.Beat("a", 1, true)
+	now = now.Add(20 * time.Second)
+	a.Beat("a", 1, false)
+	// impression b: never visible
+	a.Beat("b", 1, false)
+	now = now.Add(3 * time.Second)
+	a.Beat("b", 1, false)
+	// heartbeat claiming another banner for a is ignored
+	a.Beat("a", 2, true)
+
+	a.sweep(false)
+	if records, _ := store.GetViewability(context.Background(), 1); len(records) != 0 {
+		t.Fatalf("sessions ended before idle timeout: %+v", records)
+	}
+	now = now.Add(31 * time.Second)
+	a.sweep(false)
+
+	records, err := store.GetViewability(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.BannerViewability{
+		BannerID:         1,
+		Day:              int(time.Date(2017, 11, 20, 0, 0, 0, 0, time.UTC).Unix()),
+		Sessions:         2,
+		ViewableSessions: 1,
+		ViewableMillis:   12000,
+	}
+	if len(records) != 1 || records[0] != want {
+		t.Errorf("want %+v, got %+v", want, records)
+	}
+}
+
+func TestViewabilityAggregatorCloseFlushes(t *testing.T) {
+	store := models.NewMemoryStore()
+	a := NewViewabilityAggregator(store, log.NewNopLogger(), ViewabilityConfig{MaxSessions: 1})
+	a.Beat("a", 1, true)
+	a.Beat("b", 1, true)
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := store.GetViewability(context.Background(), 1)
+	if len(records) != 1 || records[0].Sessions != 1 {
+		t.Errorf("want the one session below MaxSessions flushed, got %+v", records)
+	}
+}
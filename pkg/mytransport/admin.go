@@ -0,0 +1,415 @@
+package mytransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myerror"
+	"jf/adservice/pkg/myservice"
+)
+
+// ErrBadBody is returned when a request body is not the JSON expected.
+var ErrBadBody = myerror.New(myerror.InvalidArgument, "malformed request body")
+
+// NewAdminHTTPHandler returns an HTTP handler serving the admin endpoints
+// under /v1/admin. Requests for other paths are handed to next.
+func NewAdminHTTPHandler(endpoints myendpoint.AdminSet, next http.Handler, logger log.Logger) http.Handler {
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(errorEncoder),
+		httptransport.ServerErrorLogger(logger),
+		httptransport.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+			return myservice.WithTransport(ctx, "http")
+		}),
+		httptransport.ServerBefore(tokenToContext),
+	}
+	server := func(e endpoint.Endpoint, dec httptransport.DecodeRequestFunc, enc httptransport.EncodeResponseFunc) http.Handler {
+		return httptransport.NewServer(e, dec, enc, options...)
+	}
+	r := mux.NewRouter()
+	admin := r.PathPrefix("/v1/admin").Subrouter()
+	admin.Methods("POST").Path("/banners").Handler(server(endpoints.CreateBannerEndpoint, decodeHTTPBanner(false), encodeHTTPGenericResponse))
+	admin.Methods("PUT").Path("/banners/{id}").Handler(server(endpoints.UpdateBannerEndpoint, decodeHTTPBanner(true), encodeHTTPGenericResponse))
+	admin.Methods("POST").Path("/banners/{id}/activate").Handler(server(endpoints.SetBannerStatusEndpoint, decodeHTTPSetBannerStatusRequest(true), encodeHTTPGenericResponse))
+	admin.Methods("POST").Path("/banners/{id}/deactivate").Handler(server(endpoints.SetBannerStatusEndpoint, decodeHTTPSetBannerStatusRequest(false), encodeHTTPGenericResponse))
+	admin.Methods("DELETE").Path("/banners/{id}").Handler(server(endpoints.DeleteBannerEndpoint, decodeHTTPDeleteRequest, encodeHTTPNoContentResponse))
+	admin.Methods("GET").Path("/groups/{id}").Handler(server(endpoints.GetGroupEndpoint, decodeHTTPGetRequest, encodeHTTPGenericResponse))
+	admin.Methods("POST").Path("/groups").Handler(server(endpoints.CreateGroupEndpoint, decodeHTTPGroup(false), encodeHTTPGenericResponse))
+	admin.Methods("PUT").Path("/groups/{id}").Handler(server(endpoints.UpdateGroupEndpoint, decodeHTTPGroup(true), encodeHTTPGenericResponse))
+	admin.Methods("DELETE").Path("/groups/{id}").Handler(server(endpoints.DeleteGroupEndpoint, decodeHTTPDeleteRequest, encodeHTTPNoContentResponse))
+	admin.Methods("GET").Path("/clients/{id}").Handler(server(endpoints.GetClientEndpoint, decodeHTTPGetRequest, encodeHTTPGenericResponse))
+	admin.Methods("POST").Path("/clients").Handler(server(endpoints.CreateClientEndpoint, decodeHTTPClient(false), encodeHTTPGenericResponse))
+	admin.Methods("PUT").Path("/clients/{id}").Handler(server(endpoints.UpdateClientEndpoint, decodeHTTPClient(true), encodeHTTPGenericResponse))
+	admin.Methods("DELETE").Path("/clients/{id}").Handler(server(endpoints.DeleteClientEndpoint, decodeHTTPDeleteRequest, encodeHTTPNoContentResponse))
+	admin.Methods("GET").Path("/campaigns/{id}").Handler(server(endpoints.GetCampaignEndpoint, decodeHTTPGetRequest, encodeHTTPGenericResponse))
+	admin.Methods("POST").Path("/campaigns").Handler(server(endpoints.CreateCampaignEndpoint, decodeHTTPCampaign(false), encodeHTTPGenericResponse))
+	admin.Methods("PUT").Path("/campaigns/{id}").Handler(server(endpoints.UpdateCampaignEndpoint, decodeHTTPCampaign(true), encodeHTTPGenericResponse))
+	for status, action := range campaignActions {
+		admin.Methods("POST").Path("/campaigns/{id}/" + action).Handler(server(endpoints.SetCampaignStatusEndpoint, decodeHTTPSetCampaignStatusRequest(status), encodeHTTPGenericResponse))
+	}
+	admin.Methods("GET").Path("/audit").Handler(server(endpoints.AuditLogEndpoint, decodeHTTPAuditLogRequest, encodeHTTPGenericResponse))
+	r.NotFoundHandler = next
+	return r
+}
+
+// campaignActions maps the states a campaign can be moved to by hand to
+// the last element of their path.
+var campaignActions = map[string]string{
+	models.CampaignActive:   "activate",
+	models.CampaignPaused:   "pause",
+	models.CampaignFinished: "finish",
+}
+
+// tokenToContext is a transport/http.RequestFunc that stores the bearer
+// token of the Authorization header in the context.
+func tokenToContext(ctx context.Context, r *http.Request) context.Context {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ctx
+	}
+	return myservice.WithToken(ctx, strings.TrimSpace(auth[len(prefix):]))
+}
+
+// decodeHTTPBanner returns a transport/http.DecodeRequestFunc decoding a
+// JSON banner from the body. With withID the {id} route variable sets its
+// ID, otherwise any ID in the body is dropped.
+func decodeHTTPBanner(withID bool) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var b models.Banner
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			return nil, ErrBadBody
+		}
+		id, err := routeID(r, withID)
+		b.ID = id
+		return b, err
+	}
+}
+
+// decodeHTTPGroup is decodeHTTPBanner for banner groups.
+func decodeHTTPGroup(withID bool) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var g models.BannerGroup
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			return nil, ErrBadBody
+		}
+		id, err := routeID(r, withID)
+		g.ID = id
+		return g, err
+	}
+}
+
+// decodeHTTPClient is decodeHTTPBanner for clients.
+func decodeHTTPClient(withID bool) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var c models.Client
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			return nil, ErrBadBody
+		}
+		id, err := routeID(r, withID)
+		c.ID = id
+		return c, err
+	}
+}
+
+// decodeHTTPCampaign is decodeHTTPBanner for campaigns.
+func decodeHTTPCampaign(withID bool) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var c models.Campaign
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			return nil, ErrBadBody
+		}
+		id, err := routeID(r, withID)
+		c.ID = id
+		return c, err
+	}
+}
+
+// decodeHTTPSetCampaignStatusRequest returns a transport/http.DecodeRequestFunc
+// for the POST /v1/admin/campaigns/{id}/activate, pause and finish routes.
+func decodeHTTPSetCampaignStatusRequest(status string) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		id, err := routeID(r, true)
+		return myendpoint.SetCampaignStatusRequest{ID: id, Status: status}, err
+	}
+}
+
+// decodeHTTPAuditLogRequest is a transport/http.DecodeRequestFunc that
+// decodes the query string of GET /v1/admin/audit into an AuditRequest.
+func decodeHTTPAuditLogRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req myservice.AuditRequest
+	if err := decodeQuery(r.URL.Query(), &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeHTTPSetBannerStatusRequest returns a transport/http.DecodeRequestFunc
+// for POST /v1/admin/banners/{id}/activate and deactivate, which name the
+// version in the query string.
+func decodeHTTPSetBannerStatusRequest(active bool) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		id, err := routeID(r, true)
+		if err != nil {
+			return nil, err
+		}
+		version, err := queryVersion(r)
+		if err != nil {
+			return nil, err
+		}
+		return myendpoint.SetBannerStatusRequest{ID: id, Version: version, Active: active}, nil
+	}
+}
+
+// decodeHTTPDeleteRequest is a transport/http.DecodeRequestFunc decoding
+// the {id} route variable and the version query parameter.
+func decodeHTTPDeleteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := routeID(r, true)
+	if err != nil {
+		return nil, err
+	}
+	version, err := queryVersion(r)
+	if err != nil {
+		return nil, err
+	}
+	return myendpoint.DeleteRequest{ID: id, Version: version}, nil
+}
+
+// decodeHTTPGetRequest is a transport/http.DecodeRequestFunc decoding the
+// {id} route variable.
+func decodeHTTPGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := routeID(r, true)
+	return myendpoint.GetRequest{ID: id}, err
+}
+
+// routeID returns the {id} route variable, or 0 when want is false.
+func routeID(r *http.Request, want bool) (int, error) {
+	if !want {
+		return 0, nil
+	}
+	raw, ok := mux.Vars(r)["id"]
+	if !ok {
+		return 0, ErrBadRouting
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, queryError{"id", raw}
+	}
+	return id, nil
+}
+
+func queryVersion(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("version")
+	version, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, queryError{"version", raw}
+	}
+	return version, nil
+}
+
+// NewHTTPAdminClient returns an AdminService backed by an HTTP server
+// living at the remote instance, authenticating with token.
+func NewHTTPAdminClient(instance, token string, logger log.Logger) (myservice.AdminService, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(func(ctx context.Context, r *http.Request) context.Context {
+			r.Header.Set("Authorization", "Bearer "+token)
+			return ctx
+		}),
+	}
+	client := func(method, path string, enc httptransport.EncodeRequestFunc, dec httptransport.DecodeResponseFunc) endpoint.Endpoint {
+		return httptransport.NewClient(method, copyURL(u, "/v1/admin"+path), enc, dec, options...).Endpoint()
+	}
+	return myendpoint.AdminSet{
+		CreateBannerEndpoint:      client("POST", "/banners", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.BannerResponse{})),
+		UpdateBannerEndpoint:      client("PUT", "/banners", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.BannerResponse{})),
+		SetBannerStatusEndpoint:   client("POST", "/banners", encodeHTTPSetBannerStatusRequest, decodeHTTPAdminResponse(myendpoint.BannerResponse{})),
+		DeleteBannerEndpoint:      client("DELETE", "/banners", encodeHTTPDeleteRequest, decodeHTTPAdminResponse(myendpoint.DeleteResponse{})),
+		GetGroupEndpoint:          client("GET", "/groups", encodeHTTPGetRequest, decodeHTTPAdminResponse(myendpoint.GroupResponse{})),
+		CreateGroupEndpoint:       client("POST", "/groups", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.GroupResponse{})),
+		UpdateGroupEndpoint:       client("PUT", "/groups", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.GroupResponse{})),
+		DeleteGroupEndpoint:       client("DELETE", "/groups", encodeHTTPDeleteRequest, decodeHTTPAdminResponse(myendpoint.DeleteResponse{})),
+		GetClientEndpoint:         client("GET", "/clients", encodeHTTPGetRequest, decodeHTTPAdminResponse(myendpoint.ClientResponse{})),
+		CreateClientEndpoint:      client("POST", "/clients", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.ClientResponse{})),
+		UpdateClientEndpoint:      client("PUT", "/clients", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.ClientResponse{})),
+		DeleteClientEndpoint:      client("DELETE", "/clients", encodeHTTPDeleteRequest, decodeHTTPAdminResponse(myendpoint.DeleteResponse{})),
+		GetCampaignEndpoint:       client("GET", "/campaigns", encodeHTTPGetRequest, decodeHTTPAdminResponse(myendpoint.CampaignResponse{})),
+		CreateCampaignEndpoint:    client("POST", "/campaigns", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.CampaignResponse{})),
+		UpdateCampaignEndpoint:    client("PUT", "/campaigns", encodeHTTPJSONRequest, decodeHTTPAdminResponse(myendpoint.CampaignResponse{})),
+		SetCampaignStatusEndpoint: client("POST", "/campaigns", encodeHTTPSetCampaignStatusRequest, decodeHTTPAdminResponse(myendpoint.CampaignResponse{})),
+		AuditLogEndpoint:          client("GET", "/audit", encodeHTTPAuditLogRequest, decodeHTTPAdminResponse(myendpoint.AuditLogResponse{})),
+	}, nil
+}
+
+// encodeHTTPJSONRequest is a transport/http.EncodeRequestFunc that sends a
+// banner, group, client or campaign as JSON, appending its ID to the path
+// on updates.
+func encodeHTTPJSONRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var id int
+	switch req := request.(type) {
+	case models.Banner:
+		id = req.ID
+	case models.BannerGroup:
+		id = req.ID
+	case models.Client:
+		id = req.ID
+	case models.Campaign:
+		id = req.ID
+	}
+	if r.Method == "PUT" {
+		r.URL.Path = r.URL.Path + "/" + strconv.Itoa(id)
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.ContentLength = int64(buf.Len())
+	r.Body = ioutil.NopCloser(&buf)
+	return nil
+}
+
+// encodeHTTPSetBannerStatusRequest is a transport/http.EncodeRequestFunc
+// that picks the activate or deactivate path of a banner.
+func encodeHTTPSetBannerStatusRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myendpoint.SetBannerStatusRequest)
+	action := "/deactivate"
+	if req.Active {
+		action = "/activate"
+	}
+	r.URL.Path = r.URL.Path + "/" + strconv.Itoa(req.ID) + action
+	r.URL.RawQuery = url.Values{"version": {strconv.Itoa(req.Version)}}.Encode()
+	return nil
+}
+
+// encodeHTTPSetCampaignStatusRequest is a transport/http.EncodeRequestFunc
+// that picks the path moving a campaign to the requested state.
+func encodeHTTPSetCampaignStatusRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myendpoint.SetCampaignStatusRequest)
+	action, ok := campaignActions[req.Status]
+	if !ok {
+		return myservice.ErrInvalidTransition
+	}
+	r.URL.Path = r.URL.Path + "/" + strconv.Itoa(req.ID) + "/" + action
+	return nil
+}
+
+// encodeHTTPAuditLogRequest is a transport/http.EncodeRequestFunc that
+// encodes an AuditRequest into the query string.
+func encodeHTTPAuditLogRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myservice.AuditRequest)
+	q := url.Values{}
+	if req.Entity != "" {
+		q.Set("entity", req.Entity)
+	}
+	for name, v := range map[string]int{"entity_id": req.EntityID, "from": req.From, "to": req.To, "limit": req.Limit} {
+		if v != 0 {
+			q.Set(name, strconv.Itoa(v))
+		}
+	}
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+// encodeHTTPDeleteRequest is a transport/http.EncodeRequestFunc that puts
+// the ID in the path and the version in the query string.
+func encodeHTTPDeleteRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myendpoint.DeleteRequest)
+	r.URL.Path = r.URL.Path + "/" + strconv.Itoa(req.ID)
+	r.URL.RawQuery = url.Values{"version": {strconv.Itoa(req.Version)}}.Encode()
+	return nil
+}
+
+// encodeHTTPGetRequest is a transport/http.EncodeRequestFunc that appends
+// the ID to the path.
+func encodeHTTPGetRequest(_ context.Context, r *http.Request, request interface{}) error {
+	r.URL.Path = r.URL.Path + "/" + strconv.Itoa(request.(myendpoint.GetRequest).ID)
+	return nil
+}
+
+// decodeHTTPAdminResponse returns a transport/http.DecodeResponseFunc that
+// decodes a JSON response of the type of zero. Error bodies become the
+// response's Err.
+func decodeHTTPAdminResponse(zero interface{}) httptransport.DecodeResponseFunc {
+	return func(_ context.Context, r *http.Response) (interface{}, error) {
+		if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusNoContent {
+			err := decodeHTTPError(r)
+			if _, ok := err.(transportError); ok {
+				return nil, err
+			}
+			return withErr(zero, err), nil
+		}
+		switch zero.(type) {
+		case myendpoint.BannerResponse:
+			var resp myendpoint.BannerResponse
+			err := json.NewDecoder(r.Body).Decode(&resp)
+			return resp, err
+		case myendpoint.GroupResponse:
+			var resp myendpoint.GroupResponse
+			err := json.NewDecoder(r.Body).Decode(&resp)
+			return resp, err
+		case myendpoint.ClientResponse:
+			var resp myendpoint.ClientResponse
+			err := json.NewDecoder(r.Body).Decode(&resp)
+			return resp, err
+		case myendpoint.CampaignResponse:
+			var resp myendpoint.CampaignResponse
+			err := json.NewDecoder(r.Body).Decode(&resp)
+			return resp, err
+		case myendpoint.AuditLogResponse:
+			var resp myendpoint.AuditLogResponse
+			err := json.NewDecoder(r.Body).Decode(&resp)
+			return resp, err
+		}
+		return zero, nil
+	}
+}
+
+// withErr returns the response zero carrying err.
+func withErr(zero interface{}, err error) interface{} {
+	switch resp := zero.(type) {
+	case myendpoint.GetBannersResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.GetBannerResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.RecordClickResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.BannerResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.GroupResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.ClientResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.CampaignResponse:
+		resp.Err = err
+		return resp
+	case myendpoint.AuditLogResponse:
+		resp.Err = err
+		return resp
+	}
+	return myendpoint.DeleteResponse{Err: err}
+}
@@ -0,0 +1,138 @@
+package mytransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myservice"
+)
+
+const testToken = "0123456789abcdef"
+
+func newAdminTestServer() (*httptest.Server, *models.MemoryStore) {
+	store := models.NewMemoryStore()
+	auth := myservice.NewAuthenticator(map[string]string{"ops": testToken})
+	admin := myendpoint.NewAdmin(myservice.NewAdminService(store), auth, log.NewNopLogger(), discard.NewHistogram())
+	endpoints := myendpoint.New(myservice.NewService(store), log.NewNopLogger(), discard.NewHistogram())
+	return httptest.NewServer(NewAdminHTTPHandler(admin, NewHTTPHandler(endpoints, log.NewNopLogger()), log.NewNopLogger())), store
+}
+
+func TestHTTPAdminClient(t *testing.T) {
+	srv, store := newAdminTestServer()
+	defer srv.Close()
+	client, err := NewHTTPAdminClient(srv.URL, testToken, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	g, err := client.CreateGroup(ctx, models.BannerGroup{Name: "home"})
+	if err != nil || g.ID == 0 || g.Version != 1 {
+		t.Fatalf("create group: %+v (%v)", g, err)
+	}
+	b, err := client.CreateBanner(ctx, models.Banner{GroupID: g.ID, Name: "a", Size: "40*50", URL: "http://a.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = client.SetBannerStatus(ctx, b.ID, b.Version, true); err != nil || b.Status != models.StatusActive {
+		t.Fatalf("activate: %+v (%v)", b, err)
+	}
+	stale := b
+	b.Name = "renamed"
+	if b, err = client.UpdateBanner(ctx, b); err != nil || b.Version != 3 {
+		t.Fatalf("update: %+v (%v)", b, err)
+	}
+	if _, err := client.UpdateBanner(ctx, stale); err != myservice.ErrVersionConflict {
+		t.Errorf("want ErrVersionConflict, got %v", err)
+	}
+	if err := client.DeleteGroup(ctx, g.ID, g.Version); err != myservice.ErrGroupInUse {
+		t.Errorf("want ErrGroupInUse, got %v", err)
+	}
+	if err := client.DeleteBanner(ctx, b.ID, b.Version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetClient(ctx, 99); err != myservice.ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if n := len(store.AuditEntries()); n != 5 {
+		t.Errorf("want 5 audit entries, got %d", n)
+	}
+	if actor := store.AuditEntries()[0].Actor; actor != "ops" {
+		t.Errorf("want actor ops, got %q", actor)
+	}
+}
+
+func TestHTTPAdminErrors(t *testing.T) {
+	srv, _ := newAdminTestServer()
+	defer srv.Close()
+	for _, c := range []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{"POST", "/v1/admin/groups", "", `{"name":"home"}`, http.StatusUnauthorized},
+		{"POST", "/v1/admin/groups", "wrong", `{"name":"home"}`, http.StatusUnauthorized},
+		{"GET", "/v1/admin/pacing", "", "", http.StatusOK},
+		{"POST", "/v1/admin/groups", testToken, `{"name":`, http.StatusBadRequest},
+		{"POST", "/v1/admin/groups", testToken, `{"name":""}`, http.StatusBadRequest},
+		{"POST", "/v1/admin/banners", testToken, `{"name":"a","url":"ftp://a.example","size":"40*50","group_id":1}`, http.StatusBadRequest},
+		{"DELETE", "/v1/admin/groups/1", testToken, "", http.StatusBadRequest},
+		{"GET", "/v1/admin/groups/1", testToken, "", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(c.method, srv.URL+c.path, strings.NewReader(c.body))
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("%s %s: want %d, got %d", c.method, c.path, c.want, resp.StatusCode)
+		}
+		if c.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s %s: missing WWW-Authenticate", c.method, c.path)
+		}
+	}
+}
+
+func TestHTTPAdminCampaignsAndAudit(t *testing.T) {
+	srv, _ := newAdminTestServer()
+	defer srv.Close()
+	client, err := NewHTTPAdminClient(srv.URL, testToken, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c, err := client.CreateCampaign(ctx, models.Campaign{Name: "spring"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err = client.SetCampaignStatus(ctx, c.ID, models.CampaignActive); err != nil || c.Status != models.CampaignActive {
+		t.Fatalf("activate: %+v (%v)", c, err)
+	}
+	if _, err := client.SetCampaignStatus(ctx, c.ID, models.CampaignActive); err != myservice.ErrInvalidTransition {
+		t.Errorf("want ErrInvalidTransition, got %v", err)
+	}
+	c.Name = "summer"
+	if c, err = client.UpdateCampaign(ctx, c); err != nil || c.Version != 2 {
+		t.Fatalf("update: %+v (%v)", c, err)
+	}
+	entries, err := client.AuditLog(ctx, myservice.AuditRequest{Entity: models.AuditCampaign, EntityID: c.ID, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "update" || entries[1].Action != "activate" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if ch := entries[0].Changes; len(ch) != 1 || string(ch[0].After) != `"summer"` {
+		t.Errorf("unexpected diff %+v", ch)
+	}
+}
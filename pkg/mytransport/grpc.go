@@ -0,0 +1,378 @@
+package mytransport
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myerror"
+	"jf/adservice/pkg/myservice"
+	"jf/adservice/pkg/pb"
+)
+
+// visitorMetadata is the gRPC metadata key carrying the visitor UUID.
+var visitorMetadata = strings.ToLower(VisitorHeader)
+
+type grpcServer struct {
+	getBanners  grpctransport.Handler
+	getBanner   grpctransport.Handler
+	recordClick grpctransport.Handler
+}
+
+// NewGRPCServer makes a set of endpoints available as a gRPC AdServiceServer.
+func NewGRPCServer(endpoints myendpoint.Set, logger log.Logger) pb.AdServiceServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorLogger(logger),
+		grpctransport.ServerBefore(func(ctx context.Context, _ metadata.MD) context.Context {
+			return myservice.WithTransport(ctx, "grpc")
+		}),
+		grpctransport.ServerBefore(visitorFromMetadata),
+	}
+	return &grpcServer{
+		getBanners: grpctransport.NewServer(
+			endpoints.GetBannersEndpoint,
+			decodeGRPCGetBannersRequest,
+			encodeGRPCGetBannersResponse,
+			options...,
+		),
+		getBanner: grpctransport.NewServer(
+			endpoints.GetBannerEndpoint,
+			decodeGRPCGetBannerRequest,
+			encodeGRPCGetBannerResponse,
+			options...,
+		),
+		recordClick: grpctransport.NewServer(
+			endpoints.RecordClickEndpoint,
+			decodeGRPCRecordClickRequest,
+			encodeGRPCRecordClickResponse,
+			options...,
+		),
+	}
+}
+
+func (s *grpcServer) GetBanners(ctx context.Context, req *pb.GetBannersRequest) (*pb.GetBannersReply, error) {
+	_, rep, err := s.getBanners.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.GetBannersReply), nil
+}
+
+func (s *grpcServer) GetBanner(ctx context.Context, req *pb.GetBannerRequest) (*pb.GetBannerReply, error) {
+	_, rep, err := s.getBanner.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.GetBannerReply), nil
+}
+
+func (s *grpcServer) RecordClick(ctx context.Context, req *pb.RecordClickRequest) (*pb.RecordClickReply, error) {
+	_, rep, err := s.recordClick.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.RecordClickReply), nil
+}
+
+// visitorFromMetadata is a transport/grpc.ServerRequestFunc that stores the
+// visitor named in the request metadata in the context for the service.
+// Requests without a valid visitor UUID are served without a visitor.
+func visitorFromMetadata(ctx context.Context, md metadata.MD) context.Context {
+	if ids := md.Get(visitorMetadata); len(ids) > 0 && validUUID(ids[0]) {
+		return myservice.WithVisitor(ctx, myservice.Visitor{ID: ids[0]})
+	}
+	return ctx
+}
+
+// visitorToMetadata is a transport/grpc.ClientRequestFunc that sends the
+// visitor of the context, if any, in the request metadata.
+func visitorToMetadata(ctx context.Context, md *metadata.MD) context.Context {
+	if id := myservice.VisitorFromContext(ctx).ID; id != "" {
+		(*md)[visitorMetadata] = []string{id}
+	}
+	return ctx
+}
+
+// grpcCodes maps error codes to gRPC status codes.
+var grpcCodes = map[myerror.Code]codes.Code{
+	myerror.Internal:        codes.Internal,
+	myerror.InvalidArgument: codes.InvalidArgument,
+	myerror.NotFound:        codes.NotFound,
+	myerror.Conflict:        codes.Aborted,
+	myerror.Expired:         codes.FailedPrecondition,
+	myerror.Unauthorized:    codes.Unauthenticated,
+	myerror.RateLimited:     codes.ResourceExhausted,
+	myerror.Unavailable:     codes.Unavailable,
+}
+
+// grpcError turns err into a gRPC status error with the code mapped from its
+// myerror.Code. Errors that already are status errors are returned as-is.
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code, ok := grpcCodes[myerror.CodeOf(err)]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, myerror.Message(err))
+}
+
+// decodeGRPCError turns a status error back into the service error it was
+// encoded from, or an error with the myerror.Code of its status code.
+func decodeGRPCError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, serr := range serviceErrors {
+		if st.Message() == serr.Error() {
+			return serr
+		}
+	}
+	for code, grpcCode := range grpcCodes {
+		if grpcCode == st.Code() {
+			return &myerror.Error{Code: code, Msg: st.Message()}
+		}
+	}
+	return &myerror.Error{Code: myerror.Internal, Msg: st.Message()}
+}
+
+// NewGRPCClient returns an AdService backed by a gRPC server at the other end
+// of the conn. The caller is responsible for constructing the conn, and
+// eventually closing the underlying transport. Heartbeat, Pacing,
+// GetBannersBatch and AdTag are not served over gRPC and always fail.
+func NewGRPCClient(conn *grpc.ClientConn, logger log.Logger) myservice.AdService {
+	options := []grpctransport.ClientOption{
+		grpctransport.ClientBefore(visitorToMetadata),
+	}
+
+	var getBannersEndpoint endpoint.Endpoint
+	{
+		getBannersEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.AdService",
+			"GetBanners",
+			encodeGRPCGetBannersRequest,
+			decodeGRPCGetBannersResponse,
+			pb.GetBannersReply{},
+			options...,
+		).Endpoint()
+		getBannersEndpoint = clientErrors(myendpoint.GetBannersResponse{}, decodeGRPCError)(getBannersEndpoint)
+		getBannersEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBanners"))(getBannersEndpoint)
+	}
+	var getBannerEndpoint endpoint.Endpoint
+	{
+		getBannerEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.AdService",
+			"GetBanner",
+			encodeGRPCGetBannerRequest,
+			decodeGRPCGetBannerResponse,
+			pb.GetBannerReply{},
+			options...,
+		).Endpoint()
+		getBannerEndpoint = clientErrors(myendpoint.GetBannerResponse{}, decodeGRPCError)(getBannerEndpoint)
+		getBannerEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBanner"))(getBannerEndpoint)
+	}
+	var recordClickEndpoint endpoint.Endpoint
+	{
+		recordClickEndpoint = grpctransport.NewClient(
+			conn,
+			"pb.AdService",
+			"RecordClick",
+			encodeGRPCRecordClickRequest,
+			decodeGRPCRecordClickResponse,
+			pb.RecordClickReply{},
+			options...,
+		).Endpoint()
+		recordClickEndpoint = clientErrors(myendpoint.RecordClickResponse{}, decodeGRPCError)(recordClickEndpoint)
+		recordClickEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "RecordClick"))(recordClickEndpoint)
+	}
+	return myendpoint.Set{
+		GetBannersEndpoint:      getBannersEndpoint,
+		GetBannerEndpoint:       getBannerEndpoint,
+		RecordClickEndpoint:     recordClickEndpoint,
+		HeartbeatEndpoint:       notServed("Heartbeat", "gRPC"),
+		PacingEndpoint:          notServed("Pacing", "gRPC"),
+		GetBannersBatchEndpoint: notServed("GetBannersBatch", "gRPC"),
+		AdTagEndpoint:           notServed("AdTag", "gRPC"),
+	}
+}
+
+// decodeGRPCGetBannersRequest is a transport/grpc.DecodeRequestFunc that
+// converts a gRPC GetBanners request to a user-domain BannerRequest.
+func decodeGRPCGetBannersRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetBannersRequest)
+	return myservice.BannerRequest{
+		ClientID:       int(req.ClientId),
+		Website:        req.Website,
+		Size:           req.Size,
+		Match:          req.Match,
+		Lang:           req.Lang,
+		AcceptLanguage: req.AcceptLanguage,
+		Count:          int(req.Count),
+		Tags:           req.Tags,
+	}, nil
+}
+
+// decodeGRPCGetBannerRequest is a transport/grpc.DecodeRequestFunc that
+// converts a gRPC GetBanner request to a user-domain GetBanner request.
+func decodeGRPCGetBannerRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetBannerRequest)
+	return myendpoint.GetBannerRequest{ID: int(req.Id)}, nil
+}
+
+// decodeGRPCRecordClickRequest is a transport/grpc.DecodeRequestFunc that
+// converts a gRPC RecordClick request to a user-domain RecordClick request.
+func decodeGRPCRecordClickRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RecordClickRequest)
+	return myendpoint.RecordClickRequest{Token: req.Token}, nil
+}
+
+// encodeGRPCGetBannersResponse is a transport/grpc.EncodeResponseFunc that
+// converts a user-domain GetBanners response to a gRPC reply. Business
+// errors become status errors.
+func encodeGRPCGetBannersResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(myendpoint.GetBannersResponse)
+	if resp.Err != nil {
+		return nil, grpcError(resp.Err)
+	}
+	reply := &pb.GetBannersReply{Locale: resp.Locale}
+	for _, ad := range resp.Banners {
+		reply.Banners = append(reply.Banners, &pb.Ad{
+			Banner:       pbBanner(ad.Banner),
+			ImpressionId: ad.ImpressionID,
+			ClickUrl:     ad.ClickURL,
+			Locale:       ad.Locale,
+		})
+	}
+	return reply, nil
+}
+
+// encodeGRPCGetBannerResponse is a transport/grpc.EncodeResponseFunc that
+// converts a user-domain GetBanner response to a gRPC reply.
+func encodeGRPCGetBannerResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(myendpoint.GetBannerResponse)
+	if resp.Err != nil {
+		return nil, grpcError(resp.Err)
+	}
+	return &pb.GetBannerReply{Banner: pbBanner(resp.Banner)}, nil
+}
+
+// encodeGRPCRecordClickResponse is a transport/grpc.EncodeResponseFunc that
+// converts a user-domain RecordClick response to a gRPC reply.
+func encodeGRPCRecordClickResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(myendpoint.RecordClickResponse)
+	if resp.Err != nil {
+		return nil, grpcError(resp.Err)
+	}
+	return &pb.RecordClickReply{Url: resp.URL}, nil
+}
+
+// encodeGRPCGetBannersRequest is a transport/grpc.EncodeRequestFunc that
+// converts a user-domain BannerRequest to a gRPC GetBanners request.
+func encodeGRPCGetBannersRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(myservice.BannerRequest)
+	return &pb.GetBannersRequest{
+		ClientId:       int64(req.ClientID),
+		Website:        req.Website,
+		Size:           req.Size,
+		Match:          req.Match,
+		Lang:           req.Lang,
+		AcceptLanguage: req.AcceptLanguage,
+		Count:          int32(req.Count),
+		Tags:           req.Tags,
+	}, nil
+}
+
+// encodeGRPCGetBannerRequest is a transport/grpc.EncodeRequestFunc that
+// converts a user-domain GetBanner request to a gRPC GetBanner request.
+func encodeGRPCGetBannerRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(myendpoint.GetBannerRequest)
+	return &pb.GetBannerRequest{Id: int64(req.ID)}, nil
+}
+
+// encodeGRPCRecordClickRequest is a transport/grpc.EncodeRequestFunc that
+// converts a user-domain RecordClick request to a gRPC RecordClick request.
+func encodeGRPCRecordClickRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(myendpoint.RecordClickRequest)
+	return &pb.RecordClickRequest{Token: req.Token}, nil
+}
+
+// decodeGRPCGetBannersResponse is a transport/grpc.DecodeResponseFunc that
+// converts a gRPC GetBanners reply to a user-domain GetBanners response.
+func decodeGRPCGetBannersResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GetBannersReply)
+	resp := myendpoint.GetBannersResponse{Locale: reply.Locale}
+	for _, ad := range reply.Banners {
+		resp.Banners = append(resp.Banners, myservice.Ad{
+			Banner:       modelBanner(ad.Banner),
+			ImpressionID: ad.ImpressionId,
+			ClickURL:     ad.ClickUrl,
+			Locale:       ad.Locale,
+		})
+	}
+	return resp, nil
+}
+
+// decodeGRPCGetBannerResponse is a transport/grpc.DecodeResponseFunc that
+// converts a gRPC GetBanner reply to a user-domain GetBanner response.
+func decodeGRPCGetBannerResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GetBannerReply)
+	return myendpoint.GetBannerResponse{Banner: modelBanner(reply.Banner)}, nil
+}
+
+// decodeGRPCRecordClickResponse is a transport/grpc.DecodeResponseFunc that
+// converts a gRPC RecordClick reply to a user-domain RecordClick response.
+func decodeGRPCRecordClickResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.RecordClickReply)
+	return myendpoint.RecordClickResponse{URL: reply.Url}, nil
+}
+
+func pbBanner(b models.Banner) *pb.Banner {
+	return &pb.Banner{
+		Id:          int64(b.ID),
+		GroupId:     int64(b.GroupID),
+		Name:        b.Name,
+		Language:    b.Language,
+		Size:        b.Size,
+		Url:         b.URL,
+		Status:      int32(b.Status),
+		Weight:      int32(b.Weight),
+		Priority:    int32(b.Priority),
+		Tags:        b.Tags,
+		LandingTags: b.LandingTags,
+		CampaignId:  int64(b.CampaignID),
+		DailyTarget: int64(b.DailyTarget),
+		Version:     int64(b.Version),
+	}
+}
+
+func modelBanner(b *pb.Banner) models.Banner {
+	return models.Banner{
+		ID:          int(b.GetId()),
+		GroupID:     int(b.GetGroupId()),
+		Name:        b.GetName(),
+		Language:    b.GetLanguage(),
+		Size:        b.GetSize(),
+		URL:         b.GetUrl(),
+		Status:      int(b.GetStatus()),
+		Weight:      int(b.GetWeight()),
+		Priority:    int(b.GetPriority()),
+		Tags:        b.GetTags(),
+		LandingTags: b.GetLandingTags(),
+		CampaignID:  int(b.GetCampaignId()),
+		DailyTarget: int(b.GetDailyTarget()),
+		Version:     int(b.GetVersion()),
+	}
+}
@@ -0,0 +1,116 @@
+package mytransport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myservice"
+	"jf/adservice/pkg/pb"
+)
+
+type impressionLog []models.BannerLog
+
+func (l *impressionLog) Record(b models.BannerLog) { *l = append(*l, b) }
+
+// newGRPCTestConn serves svc over an in-memory listener and returns a
+// connection to it, closing both stops the server.
+func newGRPCTestConn(t *testing.T, svc myservice.AdService) (*grpc.ClientConn, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	endpoints := myendpoint.New(svc, log.NewNopLogger(), discard.NewHistogram())
+	pb.RegisterAdServiceServer(server, NewGRPCServer(endpoints, log.NewNopLogger()))
+	go server.Serve(lis)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestGRPCClient(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Name: "a", Size: "40*50", URL: "http://a.example", Status: models.StatusActive, Tags: []string{"cars"}})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Name: "b", Size: "40*50", URL: "http://b.example", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	impressions := &impressionLog{}
+	svc := myservice.NewService(store,
+		myservice.WithImpressions(impressions),
+		myservice.WithClickTracking(myservice.NewClickSigner([]byte("secret"), time.Hour), ""),
+	)
+	conn, stop := newGRPCTestConn(t, svc)
+	defer stop()
+	client := NewGRPCClient(conn, log.NewNopLogger())
+
+	visitor := "0f8fad5b-d9cb-469f-a165-70867728950e"
+	ctx := myservice.WithVisitor(context.Background(), myservice.Visitor{ID: visitor})
+	ads, err := client.GetBanners(ctx, myservice.BannerRequest{ClientID: 10, Size: "40*50", Count: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 2 || ads[0].ImpressionID == "" || ads[0].ClickURL == "" {
+		t.Fatalf("unexpected ads %+v", ads)
+	}
+	if len(*impressions) != 2 || (*impressions)[0].VisitorID != visitor || (*impressions)[0].Transport != "grpc" {
+		t.Errorf("want impressions of the visitor over grpc, got %+v", *impressions)
+	}
+	banner, err := client.GetBanner(ctx, 1)
+	if err != nil || banner.URL != "http://a.example" || len(banner.Tags) != 1 {
+		t.Errorf("unexpected banner %+v (%v)", banner, err)
+	}
+	if _, err := client.GetBanner(ctx, 42); err != myservice.ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := client.GetBanners(ctx, myservice.BannerRequest{ClientID: -1, Size: "40*50"}); err != myservice.ErrInvalidClient {
+		t.Errorf("want ErrInvalidClient, got %v", err)
+	}
+	landing, err := client.RecordClick(ctx, ads[0].ClickURL[len("/v1/click/"):])
+	if err != nil || landing != ads[0].URL {
+		t.Errorf("want %s, got %q (%v)", ads[0].URL, landing, err)
+	}
+	if _, err := client.RecordClick(ctx, "forged.token"); err != myservice.ErrInvalidToken {
+		t.Errorf("want ErrInvalidToken, got %v", err)
+	}
+	if err := client.Heartbeat(ctx, myservice.HeartbeatRequest{ImpressionID: "abc", BannerID: 1}); err == nil {
+		t.Error("want Heartbeat to fail over gRPC")
+	}
+}
+
+func TestGRPCStatusCodes(t *testing.T) {
+	conn, stop := newGRPCTestConn(t, myservice.NewService(failingStore{models.NewMemoryStore()}))
+	defer stop()
+	client := pb.NewAdServiceClient(conn)
+	ctx := context.Background()
+	for _, c := range []struct {
+		id   int64
+		code codes.Code
+		msg  string
+	}{
+		{0, codes.InvalidArgument, myservice.ErrInvalidBanner.Error()},
+		{1, codes.Unavailable, "unavailable"},
+	} {
+		_, err := client.GetBanner(ctx, &pb.GetBannerRequest{Id: c.id})
+		if st, _ := status.FromError(err); st.Code() != c.code || st.Message() != c.msg {
+			t.Errorf("banner %d: want %s %q, got %v", c.id, c.code, c.msg, err)
+		}
+	}
+	_, err := client.GetBanners(ctx, &pb.GetBannersRequest{ClientId: 10, Size: "huge"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("want InvalidArgument, got %v", err)
+	}
+}
@@ -1,3 +1,601 @@
 package mytransport
 
-func NewHTTPHandler(endpoints addendpoint.set)
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myerror"
+	"jf/adservice/pkg/myservice"
+)
+
+// ErrBadRouting is returned when a route variable is missing or malformed.
+var ErrBadRouting = myerror.New(myerror.InvalidArgument, "inconsistent mapping between route and handler")
+
+// NewHTTPHandler returns an HTTP handler that makes a set of endpoints
+// available on predefined paths under /v1.
+func NewHTTPHandler(endpoints myendpoint.Set, logger log.Logger) http.Handler {
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(errorEncoder),
+		httptransport.ServerErrorLogger(logger),
+		httptransport.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+			return myservice.WithTransport(ctx, "http")
+		}),
+		httptransport.ServerBefore(visitorToContext),
+		httptransport.ServerBefore(tokenToContext),
+		httptransport.ServerAfter(visitorCookie),
+	}
+	// The tag is cached by browsers and proxies, so it must not identify the
+	// visitor or set a cookie.
+	tagOptions := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(errorEncoder),
+		httptransport.ServerErrorLogger(logger),
+		httptransport.ServerBefore(ifNoneMatchToContext),
+	}
+	r := mux.NewRouter()
+	v1 := r.PathPrefix("/v1").Subrouter()
+	v1.Methods("GET").Path("/banners").Handler(httptransport.NewServer(
+		endpoints.GetBannersEndpoint,
+		decodeHTTPGetBannersRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+	v1.Methods("GET").Path("/banners/{id}").Handler(httptransport.NewServer(
+		endpoints.GetBannerEndpoint,
+		decodeHTTPGetBannerRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+	v1.Methods("GET").Path("/click/{token}").Handler(httptransport.NewServer(
+		endpoints.RecordClickEndpoint,
+		decodeHTTPRecordClickRequest,
+		encodeHTTPRedirectResponse,
+		options...,
+	))
+	v1.Methods("GET", "POST").Path("/heartbeat").Handler(httptransport.NewServer(
+		endpoints.HeartbeatEndpoint,
+		decodeHTTPHeartbeatRequest,
+		encodeHTTPNoContentResponse,
+		options...,
+	))
+	v1.Methods("POST", "OPTIONS").Path("/slots").Handler(allowOrigin(httptransport.NewServer(
+		endpoints.GetBannersBatchEndpoint,
+		decodeHTTPGetBannersBatchRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	)))
+	v1.Methods("GET").Path("/tag.js").Handler(httptransport.NewServer(
+		endpoints.AdTagEndpoint,
+		decodeHTTPTagRequest,
+		encodeHTTPTagResponse,
+		tagOptions...,
+	))
+	v1.Methods("GET").Path("/admin/pacing").Handler(httptransport.NewServer(
+		endpoints.PacingEndpoint,
+		decodeHTTPPacingRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no such route")
+	})
+	return r
+}
+
+// decodeHTTPGetBannersRequest is a transport/http.DecodeRequestFunc that
+// decodes the query string of GET /v1/banners into a BannerRequest.
+func decodeHTTPGetBannersRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req myservice.BannerRequest
+	if err := decodeQuery(r.URL.Query(), &req); err != nil {
+		return nil, err
+	}
+	req.AcceptLanguage = r.Header.Get("Accept-Language")
+	return req, nil
+}
+
+// decodeHTTPGetBannerRequest is a transport/http.DecodeRequestFunc that
+// decodes the {id} route variable of GET /v1/banners/{id}.
+func decodeHTTPGetBannerRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, queryError{"id", id}
+	}
+	return myendpoint.GetBannerRequest{ID: n}, nil
+}
+
+// decodeHTTPRecordClickRequest is a transport/http.DecodeRequestFunc that
+// decodes the {token} route variable of GET /v1/click/{token}.
+func decodeHTTPRecordClickRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	token, ok := mux.Vars(r)["token"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	return myendpoint.RecordClickRequest{Token: token}, nil
+}
+
+// decodeHTTPHeartbeatRequest is a transport/http.DecodeRequestFunc that
+// decodes a heartbeat from the query string or a form body, so browsers can
+// send it with navigator.sendBeacon.
+func decodeHTTPHeartbeatRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req myservice.HeartbeatRequest
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	if err := decodeQuery(r.Form, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeHTTPPacingRequest is a transport/http.DecodeRequestFunc for
+// GET /v1/admin/pacing, which takes no parameters.
+func decodeHTTPPacingRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return myendpoint.PacingRequest{}, nil
+}
+
+// encodeHTTPNoContentResponse is a transport/http.EncodeResponseFunc for
+// endpoints that return nothing but an error.
+func encodeHTTPNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if err := responseError(response); err != nil {
+		errorEncoder(ctx, err, w)
+		return nil
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// encodeHTTPRedirectResponse is a transport/http.EncodeResponseFunc that
+// redirects the browser to the landing URL of a clicked banner.
+func encodeHTTPRedirectResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if err := responseError(response); err != nil {
+		errorEncoder(ctx, err, w)
+		return nil
+	}
+	resp := response.(myendpoint.RecordClickResponse)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", resp.URL)
+	w.WriteHeader(http.StatusFound)
+	return nil
+}
+
+// encodeHTTPGenericResponse is a transport/http.EncodeResponseFunc that encodes
+// the response as JSON to the response writer. Business errors carried in the
+// response are handed to the error encoder instead.
+func encodeHTTPGenericResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if err := responseError(response); err != nil {
+		errorEncoder(ctx, err, w)
+		return nil
+	}
+	if resp, ok := response.(myendpoint.GetBannersResponse); ok && resp.Locale != "" {
+		w.Header().Set("Content-Language", resp.Locale)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+// responseError returns the business error carried by response, if any.
+func responseError(response interface{}) error {
+	if f, ok := response.(myendpoint.Failer); ok {
+		return f.Failed()
+	}
+	return nil
+}
+
+// errorEncoder writes err as a JSON error body with the status code mapped
+// from its myerror.Code.
+func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+	code := err2code(err)
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeError(w, code, myerror.Message(err))
+}
+
+// httpStatus maps error codes to HTTP status codes.
+var httpStatus = map[myerror.Code]int{
+	myerror.Internal:        http.StatusInternalServerError,
+	myerror.InvalidArgument: http.StatusBadRequest,
+	myerror.NotFound:        http.StatusNotFound,
+	myerror.Conflict:        http.StatusConflict,
+	myerror.Expired:         http.StatusGone,
+	myerror.Unauthorized:    http.StatusUnauthorized,
+	myerror.RateLimited:     http.StatusTooManyRequests,
+	myerror.Unavailable:     http.StatusServiceUnavailable,
+}
+
+func err2code(err error) int {
+	if code, ok := httpStatus[myerror.CodeOf(err)]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// errorWrapper is the body of every error response.
+type errorWrapper struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(errorWrapper{Error: msg})
+}
+
+// NewHTTPClient returns an AdService backed by an HTTP server living at the
+// remote instance. We expect instance to come from a service discovery
+// system, so likely of the form "host:port".
+func NewHTTPClient(instance string, logger log.Logger) (myservice.AdService, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+	options := []httptransport.ClientOption{}
+
+	var getBannersEndpoint endpoint.Endpoint
+	{
+		getBannersEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/v1/banners"),
+			encodeHTTPGetBannersRequest,
+			decodeHTTPGetBannersResponse,
+			options...,
+		).Endpoint()
+		getBannersEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBanners"))(getBannersEndpoint)
+	}
+	var getBannerEndpoint endpoint.Endpoint
+	{
+		getBannerEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/v1/banners"),
+			encodeHTTPGetBannerRequest,
+			decodeHTTPGetBannerResponse,
+			options...,
+		).Endpoint()
+		getBannerEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBanner"))(getBannerEndpoint)
+	}
+	var recordClickEndpoint endpoint.Endpoint
+	{
+		// The client wants the landing URL, not the landing page.
+		noRedirect := &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		recordClickEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/v1/click"),
+			encodeHTTPRecordClickRequest,
+			decodeHTTPRecordClickResponse,
+			append(options, httptransport.SetClient(noRedirect))...,
+		).Endpoint()
+		recordClickEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "RecordClick"))(recordClickEndpoint)
+	}
+	var heartbeatEndpoint endpoint.Endpoint
+	{
+		heartbeatEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/v1/heartbeat"),
+			encodeHTTPHeartbeatRequest,
+			decodeHTTPHeartbeatResponse,
+			options...,
+		).Endpoint()
+	}
+	var pacingEndpoint endpoint.Endpoint
+	{
+		pacingEndpoint = httptransport.NewClient(
+			"GET",
+			copyURL(u, "/v1/admin/pacing"),
+			encodeHTTPEmptyRequest,
+			decodeHTTPPacingResponse,
+			options...,
+		).Endpoint()
+		pacingEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "Pacing"))(pacingEndpoint)
+	}
+	var getBannersBatchEndpoint endpoint.Endpoint
+	{
+		getBannersBatchEndpoint = httptransport.NewClient(
+			"POST",
+			copyURL(u, "/v1/slots"),
+			encodeHTTPGetBannersBatchRequest,
+			decodeHTTPGetBannersBatchResponse,
+			options...,
+		).Endpoint()
+		getBannersBatchEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBannersBatch"))(getBannersBatchEndpoint)
+	}
+	return myendpoint.Set{
+		GetBannersEndpoint:      getBannersEndpoint,
+		GetBannerEndpoint:       getBannerEndpoint,
+		RecordClickEndpoint:     recordClickEndpoint,
+		HeartbeatEndpoint:       heartbeatEndpoint,
+		PacingEndpoint:          pacingEndpoint,
+		GetBannersBatchEndpoint: getBannersBatchEndpoint,
+		// The tag is a script for browsers, there is nothing to decode.
+		AdTagEndpoint: notServed("AdTag", "HTTP"),
+	}, nil
+}
+
+func copyURL(base *url.URL, path string) *url.URL {
+	next := *base
+	next.Path = path
+	return &next
+}
+
+// encodeHTTPGetBannersRequest is a transport/http.EncodeRequestFunc that
+// encodes a BannerRequest into the query string.
+func encodeHTTPGetBannersRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myservice.BannerRequest)
+	q := r.URL.Query()
+	if req.ClientID != 0 {
+		q.Set("client_id", strconv.Itoa(req.ClientID))
+	}
+	if req.Website != "" {
+		q.Set("website", req.Website)
+	}
+	q.Set("size", req.Size)
+	if req.Match != "" {
+		q.Set("match", req.Match)
+	}
+	if req.Lang != "" {
+		q.Set("lang", req.Lang)
+	}
+	if req.Count > 0 {
+		q.Set("count", strconv.Itoa(req.Count))
+	}
+	if req.Tags != "" {
+		q.Set("tags", req.Tags)
+	}
+	if req.AcceptLanguage != "" {
+		r.Header.Set("Accept-Language", req.AcceptLanguage)
+	}
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+// encodeHTTPGetBannerRequest is a transport/http.EncodeRequestFunc that
+// appends the banner id to the request path.
+func encodeHTTPGetBannerRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myendpoint.GetBannerRequest)
+	r.URL.Path = r.URL.Path + "/" + strconv.Itoa(req.ID)
+	return nil
+}
+
+// encodeHTTPRecordClickRequest is a transport/http.EncodeRequestFunc that
+// appends the click token to the request path.
+func encodeHTTPRecordClickRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myendpoint.RecordClickRequest)
+	r.URL.Path = r.URL.Path + "/" + req.Token
+	return nil
+}
+
+// encodeHTTPHeartbeatRequest is a transport/http.EncodeRequestFunc that
+// form-encodes a HeartbeatRequest into the request body.
+func encodeHTTPHeartbeatRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(myservice.HeartbeatRequest)
+	form := url.Values{}
+	form.Set("impression_id", req.ImpressionID)
+	form.Set("banner_id", strconv.Itoa(req.BannerID))
+	form.Set("visible", strconv.FormatBool(req.Visible))
+	body := form.Encode()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ContentLength = int64(len(body))
+	r.Body = ioutil.NopCloser(strings.NewReader(body))
+	return nil
+}
+
+// encodeHTTPGetBannersBatchRequest is a transport/http.EncodeRequestFunc
+// that encodes a BannersRequest as a JSON body, with its AcceptLanguage in
+// the Accept-Language header.
+func encodeHTTPGetBannersBatchRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(myservice.BannersRequest)
+	if req.AcceptLanguage != "" {
+		r.Header.Set("Accept-Language", req.AcceptLanguage)
+	}
+	return httptransport.EncodeJSONRequest(ctx, r, req)
+}
+
+// encodeHTTPEmptyRequest is a transport/http.EncodeRequestFunc for requests
+// without parameters.
+func encodeHTTPEmptyRequest(_ context.Context, _ *http.Request, _ interface{}) error {
+	return nil
+}
+
+// decodeHTTPGetBannersResponse is a transport/http.DecodeResponseFunc that
+// decodes a JSON GetBannersResponse. Error bodies become the response's Err.
+func decodeHTTPGetBannersResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp myendpoint.GetBannersResponse
+	if r.StatusCode != http.StatusOK {
+		err := decodeHTTPError(r)
+		if _, ok := err.(transportError); ok {
+			return nil, err
+		}
+		resp.Err = err
+		return resp, nil
+	}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPGetBannerResponse is a transport/http.DecodeResponseFunc that
+// decodes a JSON GetBannerResponse. Error bodies become the response's Err.
+func decodeHTTPGetBannerResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp myendpoint.GetBannerResponse
+	if r.StatusCode != http.StatusOK {
+		err := decodeHTTPError(r)
+		if _, ok := err.(transportError); ok {
+			return nil, err
+		}
+		resp.Err = err
+		return resp, nil
+	}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPRecordClickResponse is a transport/http.DecodeResponseFunc that
+// reads the landing URL from the redirect. Error bodies become the response's Err.
+func decodeHTTPRecordClickResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp myendpoint.RecordClickResponse
+	if r.StatusCode != http.StatusFound {
+		err := decodeHTTPError(r)
+		if _, ok := err.(transportError); ok {
+			return nil, err
+		}
+		resp.Err = err
+		return resp, nil
+	}
+	resp.URL = r.Header.Get("Location")
+	return resp, nil
+}
+
+// decodeHTTPHeartbeatResponse is a transport/http.DecodeResponseFunc for
+// the empty heartbeat response. Error bodies become the response's Err.
+func decodeHTTPHeartbeatResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp myendpoint.HeartbeatResponse
+	if r.StatusCode != http.StatusNoContent {
+		err := decodeHTTPError(r)
+		if _, ok := err.(transportError); ok {
+			return nil, err
+		}
+		resp.Err = err
+	}
+	return resp, nil
+}
+
+// decodeHTTPGetBannersBatchResponse is a transport/http.DecodeResponseFunc
+// that decodes a JSON GetBannersBatchResponse. Error bodies become the
+// response's Err.
+func decodeHTTPGetBannersBatchResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp myendpoint.GetBannersBatchResponse
+	if r.StatusCode != http.StatusOK {
+		err := decodeHTTPError(r)
+		if _, ok := err.(transportError); ok {
+			return nil, err
+		}
+		resp.Err = err
+		return resp, nil
+	}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPPacingResponse is a transport/http.DecodeResponseFunc that
+// decodes a JSON PacingResponse. Error bodies become the response's Err.
+func decodeHTTPPacingResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp myendpoint.PacingResponse
+	if r.StatusCode != http.StatusOK {
+		err := decodeHTTPError(r)
+		if _, ok := err.(transportError); ok {
+			return nil, err
+		}
+		resp.Err = err
+		return resp, nil
+	}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// transportError is returned by clients when the server failed for reasons
+// that are not part of the service's business errors.
+type transportError struct {
+	code int
+	msg  string
+}
+
+func (e transportError) Error() string {
+	return fmt.Sprintf("http %d: %s", e.code, e.msg)
+}
+
+// ErrorCode implements myerror.Coder, so clients see the same codes as the
+// server did.
+func (e transportError) ErrorCode() myerror.Code {
+	for code, status := range httpStatus {
+		if status == e.code {
+			return code
+		}
+	}
+	return myerror.Internal
+}
+
+// serviceErrors are the business errors clients recognise by message.
+var serviceErrors = []error{
+	myservice.ErrInvalidClient,
+	myservice.ErrInvalidBanner,
+	myservice.ErrNotFound,
+	myservice.ErrInvalidToken,
+	myservice.ErrTokenExpired,
+	myservice.ErrInvalidImpression,
+	myservice.ErrInvalidWebsite,
+	myservice.ErrInvalidSize,
+	myservice.ErrInvalidMatch,
+	myservice.ErrInvalidTags,
+	myservice.ErrUnauthorized,
+	myservice.ErrVersionConflict,
+	myservice.ErrGroupInUse,
+	myservice.ErrInvalidTransition,
+	myservice.ErrInvalidSlots,
+	ErrBadBody,
+}
+
+// clientErrors is an endpoint middleware for RPC clients that carries
+// service errors in a response like zero, as the HTTP client does. decode
+// turns the errors of the RPC framework back into service errors, other
+// errors are returned as endpoint errors.
+func clientErrors(zero interface{}, decode func(error) error) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := next(ctx, request)
+			if err == nil {
+				return response, nil
+			}
+			err = decode(err)
+			for _, serr := range serviceErrors {
+				if err == serr {
+					return withErr(zero, err), nil
+				}
+			}
+			return nil, err
+		}
+	}
+}
+
+// notServed is the client endpoint of methods a transport does not serve to
+// its clients.
+func notServed(method, transport string) endpoint.Endpoint {
+	return func(context.Context, interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("%s is not served to %s clients", method, transport)
+	}
+}
+
+// decodeHTTPError turns an error body back into the service error it was
+// encoded from, or a transportError if it is not a known service error.
+func decodeHTTPError(r *http.Response) error {
+	var w errorWrapper
+	if err := json.NewDecoder(r.Body).Decode(&w); err != nil || w.Error == "" {
+		return transportError{r.StatusCode, http.StatusText(r.StatusCode)}
+	}
+	for _, err := range serviceErrors {
+		if w.Error == err.Error() {
+			return err
+		}
+	}
+	return transportError{r.StatusCode, w.Error}
+}
@@ -0,0 +1,275 @@
+package mytransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myerror"
+	"jf/adservice/pkg/myservice"
+)
+
+func newTestServer() *httptest.Server {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Name: "a", Size: "40*50", URL: "http://a.example", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Name: "b", Size: "40*50", URL: "http://b.example", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	store.PutWebsite(models.Website{ID: 1, Domain: "site.example", ClientID: 10})
+	svc := myservice.NewService(store, myservice.WithClickTracking(myservice.NewClickSigner([]byte("secret"), time.Hour), ""))
+	endpoints := myendpoint.New(svc, log.NewNopLogger(), discard.NewHistogram())
+	return httptest.NewServer(NewHTTPHandler(endpoints, log.NewNopLogger()))
+}
+
+func get(t *testing.T, url string, v interface{}) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("%s: unexpected content type %q", url, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s: %v", url, err)
+	}
+	return resp.StatusCode
+}
+
+func TestHTTPGetBanners(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	var body struct {
+		Banners []myservice.Ad `json:"banners"`
+	}
+	code := get(t, srv.URL+"/v1/banners?client_id=10&size=40*50&lang=en&count=2", &body)
+	if code != http.StatusOK {
+		t.Fatalf("want 200, got %d", code)
+	}
+	if len(body.Banners) != 2 || body.Banners[0].ID == body.Banners[1].ID {
+		t.Errorf("unexpected banners %+v", body.Banners)
+	}
+	for _, ad := range body.Banners {
+		if ad.ImpressionID == "" || ad.URL == "" {
+			t.Errorf("incomplete ad %+v", ad)
+		}
+	}
+}
+
+func TestHTTPGetBanner(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	var body struct {
+		Banner models.Banner `json:"banner"`
+	}
+	if code := get(t, srv.URL+"/v1/banners/2", &body); code != http.StatusOK {
+		t.Fatalf("want 200, got %d", code)
+	}
+	if body.Banner.ID != 2 || body.Banner.Name != "b" {
+		t.Errorf("unexpected banner %+v", body.Banner)
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/v1/banners?size=40*50", http.StatusBadRequest},
+		{"/v1/banners?client_id=abc", http.StatusBadRequest},
+		{"/v1/banners?client_id=10&size=big", http.StatusBadRequest},
+		{"/v1/banners?client_id=99&size=40*50", http.StatusNotFound},
+		{"/v1/banners?website=other.example&size=40*50", http.StatusNotFound},
+		{"/v1/banners?client_id=11&website=site.example&size=40*50", http.StatusBadRequest},
+		{"/v1/banners/abc", http.StatusBadRequest},
+		{"/v1/banners/0", http.StatusBadRequest},
+		{"/v1/banners/42", http.StatusNotFound},
+		{"/v2/banners", http.StatusNotFound},
+		{"/v1/click/forged.token", http.StatusBadRequest},
+	} {
+		var body errorWrapper
+		if code := get(t, srv.URL+tc.path, &body); code != tc.code {
+			t.Errorf("%s: want %d, got %d", tc.path, tc.code, code)
+		}
+		if body.Error == "" {
+			t.Errorf("%s: empty error body", tc.path)
+		}
+	}
+}
+
+type failingStore struct {
+	*models.MemoryStore
+}
+
+func (failingStore) GetBannerByID(ctx context.Context, id int) (models.Banner, error) {
+	return models.Banner{}, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+}
+
+func TestHTTPUnavailable(t *testing.T) {
+	endpoints := myendpoint.New(myservice.NewService(failingStore{models.NewMemoryStore()}), log.NewNopLogger(), discard.NewHistogram())
+	srv := httptest.NewServer(NewHTTPHandler(endpoints, log.NewNopLogger()))
+	defer srv.Close()
+	var body errorWrapper
+	if code := get(t, srv.URL+"/v1/banners/1", &body); code != http.StatusServiceUnavailable {
+		t.Errorf("want 503, got %d", code)
+	}
+	if body.Error != "unavailable" {
+		t.Errorf("want the cause hidden, got %q", body.Error)
+	}
+	client, err := NewHTTPClient(srv.URL, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetBanner(context.Background(), 1); myerror.CodeOf(err) != myerror.Unavailable {
+		t.Errorf("want unavailable from the client, got %v", err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	client, err := NewHTTPClient(srv.URL, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	banners, err := client.GetBanners(ctx, myservice.BannerRequest{ClientID: 10, Size: "40*50", Count: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banners) != 2 {
+		t.Errorf("unexpected banners %+v", banners)
+	}
+	banner, err := client.GetBanner(ctx, 1)
+	if err != nil || banner.URL != "http://a.example" {
+		t.Errorf("unexpected banner %+v (%v)", banner, err)
+	}
+	if _, err := client.GetBanner(ctx, 42); err != myservice.ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := client.GetBanners(ctx, myservice.BannerRequest{ClientID: -1, Size: "40*50"}); err != myservice.ErrInvalidClient {
+		t.Errorf("want ErrInvalidClient, got %v", err)
+	}
+	if states, err := client.Pacing(ctx); err != nil || len(states) != 0 {
+		t.Errorf("want no paced banners, got %v (%v)", states, err)
+	}
+}
+
+func TestHTTPClickRedirect(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	var body struct {
+		Banners []myservice.Ad `json:"banners"`
+	}
+	get(t, srv.URL+"/v1/banners?client_id=10&size=40*50", &body)
+	if len(body.Banners) != 1 || !strings.HasPrefix(body.Banners[0].ClickURL, "/v1/click/") {
+		t.Fatalf("want a relative click url, got %+v", body.Banners)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(srv.URL + body.Banners[0].ClickURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != body.Banners[0].URL {
+		t.Errorf("want redirect to %s, got %d %s", body.Banners[0].URL, resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	c, err := NewHTTPClient(srv.URL, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	landing, err := c.RecordClick(context.Background(), strings.TrimPrefix(body.Banners[0].ClickURL, "/v1/click/"))
+	if err != nil || landing != body.Banners[0].URL {
+		t.Errorf("want %s, got %q (%v)", body.Banners[0].URL, landing, err)
+	}
+	if _, err := c.RecordClick(context.Background(), "forged.token"); err != myservice.ErrInvalidToken {
+		t.Errorf("want ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestHTTPHeartbeat(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	var served struct {
+		Banners []myservice.Ad `json:"banners"`
+	}
+	get(t, srv.URL+"/v1/banners?client_id=10&size=40*50", &served)
+	if len(served.Banners) != 1 {
+		t.Fatalf("unexpected banners %+v", served.Banners)
+	}
+	impression, bannerID := served.Banners[0].ImpressionID, served.Banners[0].ID
+	resp, err := http.PostForm(srv.URL+"/v1/heartbeat", url.Values{
+		"impression_id": {impression},
+		"banner_id":     {strconv.Itoa(bannerID)},
+		"visible":       {"true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("want 204, got %d", resp.StatusCode)
+	}
+	var body errorWrapper
+	if code := get(t, srv.URL+"/v1/heartbeat?banner_id=1", &body); code != http.StatusBadRequest {
+		t.Errorf("want 400 without impression, got %d", code)
+	}
+
+	c, err := NewHTTPClient(srv.URL, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Heartbeat(context.Background(), myservice.HeartbeatRequest{ImpressionID: impression, BannerID: bannerID}); err != nil {
+		t.Error(err)
+	}
+	if err := c.Heartbeat(context.Background(), myservice.HeartbeatRequest{ImpressionID: "abc", BannerID: 1}); err != myservice.ErrInvalidImpression {
+		t.Errorf("want ErrInvalidImpression for a made-up impression, got %v", err)
+	}
+	if err := c.Heartbeat(context.Background(), myservice.HeartbeatRequest{BannerID: 1}); err != myservice.ErrInvalidImpression {
+		t.Errorf("want ErrInvalidImpression, got %v", err)
+	}
+}
+
+func TestHTTPGetBannersAcceptLanguage(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Size: "40*50", Language: "en", Status: models.StatusActive})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Size: "40*50", Language: "zh", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	svc := myservice.NewService(store, myservice.WithLanguages(myservice.NewLanguages(nil, "en")))
+	srv := httptest.NewServer(NewHTTPHandler(myendpoint.New(svc, log.NewNopLogger(), discard.NewHistogram()), log.NewNopLogger()))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/v1/banners?client_id=10&size=40*50", nil)
+	req.Header.Set("Accept-Language", "zh-TW,zh;q=0.9")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Banners []myservice.Ad `json:"banners"`
+		Locale  string         `json:"locale"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Locale != "zh" || resp.Header.Get("Content-Language") != "zh" || len(body.Banners) != 1 || body.Banners[0].ID != 2 {
+		t.Errorf("want the zh banner, got %+v (Content-Language %q)", body, resp.Header.Get("Content-Language"))
+	}
+}
@@ -0,0 +1,63 @@
+package mytransport
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"jf/adservice/pkg/myerror"
+)
+
+// queryError reports a query parameter that could not be decoded.
+type queryError struct {
+	param string
+	value string
+}
+
+func (e queryError) Error() string {
+	return fmt.Sprintf("invalid value %q for parameter %s", e.value, e.param)
+}
+
+// ErrorCode implements myerror.Coder.
+func (e queryError) ErrorCode() myerror.Code {
+	return myerror.InvalidArgument
+}
+
+// decodeQuery fills the fields of the struct pointed to by dst from values,
+// using the `p:"name"` tag of each field as the parameter name. Only string,
+// int and bool fields are supported; missing parameters leave the field alone.
+func decodeQuery(values url.Values, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("p")
+		if name == "" {
+			continue
+		}
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(raw)
+		case reflect.Int, reflect.Int64, reflect.Int32:
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return queryError{name, raw}
+			}
+			f.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return queryError{name, raw}
+			}
+			f.SetBool(b)
+		default:
+			return fmt.Errorf("mytransport: unsupported field type %s for parameter %s", f.Kind(), name)
+		}
+	}
+	return nil
+}
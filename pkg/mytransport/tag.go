@@ -0,0 +1,407 @@
+package mytransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myservice"
+)
+
+// TagVersion is the version of the ad tag script served under /v1/tag.js.
+// Bump it with every change of the script that pages may notice.
+const TagVersion = "1.0.0"
+
+// maxSlotsBody bounds the body of POST /v1/slots.
+const maxSlotsBody = 64 << 10
+
+type tagContextKey int
+
+const ifNoneMatchKey tagContextKey = 0
+
+// ifNoneMatchToContext is a transport/http.RequestFunc that stores the
+// If-None-Match header in the context, so the tag can be revalidated.
+func ifNoneMatchToContext(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, ifNoneMatchKey, r.Header.Get("If-None-Match"))
+}
+
+// allowOrigin lets scripts of any origin call next with the visitor cookie.
+// Preflight requests are answered directly.
+func allowOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeHTTPGetBannersBatchRequest is a transport/http.DecodeRequestFunc
+// that decodes the JSON body of POST /v1/slots into a BannersRequest. Any
+// content type is accepted, so that the ad tag can send text/plain and skip
+// the CORS preflight.
+func decodeHTTPGetBannersBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req myservice.BannersRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxSlotsBody)).Decode(&req); err != nil {
+		return nil, ErrBadBody
+	}
+	req.AcceptLanguage = r.Header.Get("Accept-Language")
+	return req, nil
+}
+
+// decodeHTTPTagRequest is a transport/http.DecodeRequestFunc that decodes
+// the client_id query parameter of GET /v1/tag.js.
+func decodeHTTPTagRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req myendpoint.AdTagRequest
+	raw := r.URL.Query().Get("client_id")
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, queryError{"client_id", raw}
+	}
+	req.ClientID = id
+	return req, nil
+}
+
+// encodeHTTPTagResponse is a transport/http.EncodeResponseFunc that writes
+// the ad tag script of a client. The script is cacheable for the MaxAge of
+// the tag and revalidated by its ETag.
+func encodeHTTPTagResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if err := responseError(response); err != nil {
+		errorEncoder(ctx, err, w)
+		return nil
+	}
+	tag := response.(myendpoint.AdTagResponse).AdTag
+	script, err := renderTag(tag)
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum(script)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(tag.MaxAge/time.Second)))
+	w.Header().Set("ETag", etag)
+	if match, _ := ctx.Value(ifNoneMatchKey).(string); match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	_, err = w.Write(script)
+	return err
+}
+
+// tagScriptConfig is the configuration generated into the ad tag script.
+type tagScriptConfig struct {
+	Version     string   `json:"version"`
+	ClientID    int      `json:"client_id"`
+	Sizes       []string `json:"sizes"`
+	HeartbeatMS int64    `json:"heartbeat_ms"`
+	RotateMS    int64    `json:"rotate_ms"`
+}
+
+// renderTag returns the ad tag script with the configuration of tag. The
+// JSON encoder escapes <, > and &, so the configuration cannot end the
+// script element the tag is loaded by.
+func renderTag(tag myservice.AdTag) ([]byte, error) {
+	cfg, err := json.Marshal(tagScriptConfig{
+		Version:     TagVersion,
+		ClientID:    tag.ClientID,
+		Sizes:       tag.Sizes,
+		HeartbeatMS: int64(tag.HeartbeatInterval / time.Millisecond),
+		RotateMS:    int64(tag.RotateInterval / time.Millisecond),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString("/* adservice tag " + TagVersion + " */\n")
+	b.WriteString(tagScript)
+	b.WriteString("(window, document, ")
+	b.Write(cfg)
+	b.WriteString(");\n")
+	return b.Bytes(), nil
+}
+
+// tagScript is the ad tag, a function of the window, the document and the
+// generated configuration. It is plain ES5 so that it runs in every browser
+// still visiting our sites.
+//
+// The tag finds the slots of a page by their data-adv-size attribute and
+// fills them with one POST /v1/slots. A slot may also set data-adv-client,
+// data-adv-lang, data-adv-match and data-adv-count; slots of other clients
+// are left to their own tag, and slots of a size the client has no banner
+// for are not asked for. Banners have no creative of their own yet, a slot
+// shows a link sized like the banner, named after it.
+//
+// The impression is logged when the banner is served. Once rendered, the tag
+// sends a heartbeat right away, one whenever the banner comes into or goes
+// out of view, and one every heartbeat_ms while it stays in view, which is
+// what viewability is computed from. With rotate_ms set, slots that were in
+// view that long are refilled together in one call.
+const tagScript = `(function (window, document, config) {
+	"use strict";
+
+	var script = document.currentScript;
+	var base = script && script.src ? script.src.replace(/\/v1\/tag\.js(\?.*)?$/, "") : "";
+	var slots = [];
+	var observer = null;
+	var uid = visitor();
+
+	function visitor() {
+		var id = null;
+		if (navigator.doNotTrack === "1") {
+			return id;
+		}
+		try {
+			id = window.localStorage.getItem("adv_uid");
+			if (!id && window.crypto && window.crypto.getRandomValues) {
+				var b = new Uint8Array(16);
+				window.crypto.getRandomValues(b);
+				b[6] = b[6] & 0x0f | 0x40;
+				b[8] = b[8] & 0x3f | 0x80;
+				var hex = "";
+				for (var i = 0; i < 16; i++) {
+					hex += (b[i] + 0x100).toString(16).slice(1);
+				}
+				id = hex.slice(0, 8) + "-" + hex.slice(8, 12) + "-" + hex.slice(12, 16) + "-" + hex.slice(16, 20) + "-" + hex.slice(20);
+				window.localStorage.setItem("adv_uid", id);
+			}
+		} catch (e) {}
+		return id;
+	}
+
+	function url(path) {
+		return base + path + (uid ? (path.indexOf("?") < 0 ? "?" : "&") + "uid=" + uid : "");
+	}
+
+	function attr(el, name) {
+		return el.getAttribute("data-adv-" + name) || "";
+	}
+
+	function parseSize(size) {
+		var m = /^\s*(\d+)\s*[x*]\s*(\d+)\s*$/i.exec(size);
+		return m ? [m[1], m[2]] : null;
+	}
+
+	function servable(size, match) {
+		var wh = parseSize(size);
+		if (!wh || (match && match !== "exact")) {
+			return true;
+		}
+		for (var i = 0; i < config.sizes.length; i++) {
+			if (config.sizes[i] === wh[0] + "*" + wh[1]) {
+				return true;
+			}
+		}
+		return false;
+	}
+
+	function discover() {
+		var found = document.querySelectorAll("[data-adv-size]");
+		for (var i = 0; i < found.length; i++) {
+			var el = found[i];
+			var client = attr(el, "client");
+			if (attr(el, "state") || (client && client !== String(config.client_id))) {
+				continue;
+			}
+			if (!servable(attr(el, "size"), attr(el, "match"))) {
+				el.setAttribute("data-adv-state", "empty");
+				continue;
+			}
+			el.setAttribute("data-adv-state", "loading");
+			slots.push({
+				id: el.id || "adv-slot-" + slots.length,
+				el: el,
+				size: attr(el, "size"),
+				match: attr(el, "match"),
+				lang: attr(el, "lang"),
+				count: parseInt(attr(el, "count"), 10) || 1,
+				ads: [],
+				inView: false,
+				shown: 0
+			});
+		}
+	}
+
+	function fill(batch) {
+		if (!batch.length) {
+			return;
+		}
+		var req = {client_id: config.client_id, slots: []};
+		for (var i = 0; i < batch.length; i++) {
+			var s = batch[i];
+			req.slots.push({id: s.id, size: s.size, match: s.match, lang: s.lang, count: s.count});
+		}
+		var xhr = new XMLHttpRequest();
+		xhr.open("POST", url("/v1/slots"));
+		xhr.withCredentials = true;
+		xhr.setRequestHeader("Content-Type", "text/plain");
+		xhr.onload = function () {
+			var resp = null;
+			try {
+				resp = JSON.parse(xhr.responseText);
+			} catch (e) {}
+			for (var i = 0; i < batch.length; i++) {
+				var result = resp && resp.slots ? resp.slots[i] : null;
+				if (xhr.status !== 200 || !result || result.error) {
+					batch[i].el.setAttribute("data-adv-state", "error");
+					continue;
+				}
+				render(batch[i], result.banners);
+			}
+		};
+		xhr.send(JSON.stringify(req));
+	}
+
+	function render(slot, banners) {
+		hide(slot);
+		slot.ads = banners;
+		slot.shown = 0;
+		while (slot.el.firstChild) {
+			slot.el.removeChild(slot.el.firstChild);
+		}
+		for (var i = 0; i < banners.length; i++) {
+			var ad = banners[i];
+			var wh = parseSize(ad.size);
+			var a = document.createElement("a");
+			a.className = "adv-banner";
+			a.href = ad.click_url ? (ad.click_url.charAt(0) === "/" ? base + ad.click_url : ad.click_url) : ad.url;
+			a.target = "_blank";
+			a.rel = "noopener";
+			a.style.display = "inline-block";
+			if (wh) {
+				a.style.width = wh[0] + "px";
+				a.style.height = wh[1] + "px";
+			}
+			a.setAttribute("data-adv-banner", ad.id);
+			if (ad.locale) {
+				a.lang = ad.locale;
+			}
+			a.appendChild(document.createTextNode(ad.name));
+			slot.el.appendChild(a);
+		}
+		slot.el.setAttribute("data-adv-state", banners.length ? "filled" : "empty");
+		slot.wasVisible = visible(slot);
+		beat(slot, slot.wasVisible);
+	}
+
+	function beat(slot, isVisible) {
+		for (var i = 0; i < slot.ads.length; i++) {
+			var body = "impression_id=" + encodeURIComponent(slot.ads[i].impression_id) +
+				"&banner_id=" + slot.ads[i].id + "&visible=" + isVisible;
+			var target = url("/v1/heartbeat");
+			if (navigator.sendBeacon) {
+				navigator.sendBeacon(target, new Blob([body], {type: "application/x-www-form-urlencoded"}));
+				continue;
+			}
+			var xhr = new XMLHttpRequest();
+			xhr.open("POST", target);
+			xhr.withCredentials = true;
+			xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+			xhr.send(body);
+		}
+	}
+
+	function hide(slot) {
+		if (slot.ads.length && slot.wasVisible) {
+			beat(slot, false);
+		}
+		slot.wasVisible = false;
+	}
+
+	function inViewport(el) {
+		var r = el.getBoundingClientRect();
+		var h = window.innerHeight || document.documentElement.clientHeight;
+		var w = window.innerWidth || document.documentElement.clientWidth;
+		return r.bottom > 0 && r.right > 0 && r.top < h && r.left < w;
+	}
+
+	function visible(slot) {
+		var inView = observer ? slot.inView : inViewport(slot.el);
+		return inView && document.visibilityState !== "hidden" && document.hasFocus();
+	}
+
+	function update(slot) {
+		var isVisible = visible(slot);
+		if (slot.ads.length && isVisible !== !!slot.wasVisible) {
+			beat(slot, isVisible);
+		}
+		slot.wasVisible = isVisible;
+	}
+
+	function tick() {
+		var rotate = [];
+		for (var i = 0; i < slots.length; i++) {
+			var slot = slots[i];
+			var was = slot.wasVisible;
+			update(slot);
+			if (!slot.wasVisible || !slot.ads.length) {
+				continue;
+			}
+			if (was) {
+				beat(slot, true);
+			}
+			slot.shown += config.heartbeat_ms;
+			if (config.rotate_ms > 0 && slot.shown >= config.rotate_ms) {
+				slot.shown = 0;
+				rotate.push(slot);
+			}
+		}
+		fill(rotate);
+	}
+
+	function updateAll() {
+		for (var i = 0; i < slots.length; i++) {
+			update(slots[i]);
+		}
+	}
+
+	function start() {
+		discover();
+		if (window.IntersectionObserver) {
+			observer = new window.IntersectionObserver(function (entries) {
+				for (var i = 0; i < entries.length; i++) {
+					for (var j = 0; j < slots.length; j++) {
+						if (slots[j].el === entries[i].target) {
+							slots[j].inView = entries[i].intersectionRatio >= 0.5;
+							update(slots[j]);
+						}
+					}
+				}
+			}, {threshold: [0, 0.5, 1]});
+			for (var i = 0; i < slots.length; i++) {
+				observer.observe(slots[i].el);
+			}
+		}
+		fill(slots);
+		document.addEventListener("visibilitychange", updateAll);
+		window.addEventListener("focus", updateAll);
+		window.addEventListener("blur", updateAll);
+		window.addEventListener("pagehide", function () {
+			for (var i = 0; i < slots.length; i++) {
+				hide(slots[i]);
+			}
+		});
+		window.setInterval(tick, config.heartbeat_ms);
+	}
+
+	if (document.readyState === "loading") {
+		document.addEventListener("DOMContentLoaded", start);
+	} else {
+		start();
+	}
+})`
@@ -0,0 +1,110 @@
+package mytransport
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/pkg/myservice"
+)
+
+func TestHTTPAdTag(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/v1/tag.js?client_id=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Cache-Control"), "public, max-age=") {
+		t.Errorf("want a cacheable tag, got %q", resp.Header.Get("Cache-Control"))
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		t.Errorf("want no cookie on a cacheable tag, got %q", resp.Header.Get("Set-Cookie"))
+	}
+	if !strings.Contains(string(script), `"client_id":10,"sizes":["40*50"]`) {
+		t.Errorf("want the configuration in the tag, got %s", script)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("want an ETag")
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/v1/tag.js?client_id=10", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("want 304 for a matching ETag, got %d", resp.StatusCode)
+	}
+
+	var body errorWrapper
+	if code := get(t, srv.URL+"/v1/tag.js?client_id=x", &body); code != http.StatusBadRequest {
+		t.Errorf("want 400 for a bad client_id, got %d", code)
+	}
+}
+
+func TestHTTPGetBannersBatch(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("OPTIONS", srv.URL+"/v1/slots", nil)
+	req.Header.Set("Origin", "http://site.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "http://site.example" {
+		t.Errorf("unexpected preflight response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	req, _ = http.NewRequest("POST", srv.URL+"/v1/slots", strings.NewReader(
+		`{"client_id":10,"slots":[{"id":"top","size":"40*50"},{"id":"side","size":"40*50"}]}`))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Origin", "http://site.example")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("want credentials allowed, got %v", resp.Header)
+	}
+	var body struct {
+		Slots []myservice.SlotAds `json:"slots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Slots) != 2 || len(body.Slots[0].Banners) != 1 || len(body.Slots[1].Banners) != 1 {
+		t.Fatalf("unexpected slots %+v", body.Slots)
+	}
+
+	client, err := NewHTTPClient(srv.URL, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slots, err := client.GetBannersBatch(context.Background(), myservice.BannersRequest{ClientID: 10, Slots: []myservice.Slot{{ID: "top", Size: "40*50", Count: 2}}})
+	if err != nil || len(slots) != 1 || len(slots[0].Banners) != 2 {
+		t.Errorf("unexpected slots %+v (%v)", slots, err)
+	}
+	if _, err := client.GetBannersBatch(context.Background(), myservice.BannersRequest{ClientID: 10}); err != myservice.ErrInvalidSlots {
+		t.Errorf("want ErrInvalidSlots, got %v", err)
+	}
+}
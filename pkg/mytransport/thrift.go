@@ -0,0 +1,271 @@
+package mytransport
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myerror"
+	"jf/adservice/pkg/myservice"
+	adthrift "jf/adservice/pkg/thrift/gen-go/adservice"
+)
+
+type thriftServer struct {
+	endpoints myendpoint.Set
+}
+
+// NewThriftServer makes a set of endpoints available as a Thrift service.
+func NewThriftServer(endpoints myendpoint.Set) adthrift.AdService {
+	return &thriftServer{
+		endpoints: endpoints,
+	}
+}
+
+func (s *thriftServer) GetBanners(ctx context.Context, req *adthrift.GetBannersRequest, visitor string) (*adthrift.GetBannersReply, error) {
+	if req == nil {
+		req = adthrift.NewGetBannersRequest()
+	}
+	request := myservice.BannerRequest{
+		ClientID:       int(req.ClientId),
+		Website:        req.Website,
+		Size:           req.Size,
+		Match:          req.Match,
+		Lang:           req.Lang,
+		AcceptLanguage: req.AcceptLanguage,
+		Count:          int(req.Count),
+		Tags:           req.Tags,
+	}
+	response, err := s.endpoints.GetBannersEndpoint(thriftContext(ctx, visitor), request)
+	if err != nil {
+		return nil, thriftError(err)
+	}
+	resp := response.(myendpoint.GetBannersResponse)
+	if resp.Err != nil {
+		return nil, thriftError(resp.Err)
+	}
+	reply := &adthrift.GetBannersReply{Locale: resp.Locale}
+	for _, ad := range resp.Banners {
+		reply.Banners = append(reply.Banners, &adthrift.Ad{
+			Banner:       thriftBanner(ad.Banner),
+			ImpressionId: ad.ImpressionID,
+			ClickUrl:     ad.ClickURL,
+			Locale:       ad.Locale,
+		})
+	}
+	return reply, nil
+}
+
+func (s *thriftServer) GetBanner(ctx context.Context, id int64, visitor string) (*adthrift.Banner, error) {
+	request := myendpoint.GetBannerRequest{ID: int(id)}
+	response, err := s.endpoints.GetBannerEndpoint(thriftContext(ctx, visitor), request)
+	if err != nil {
+		return nil, thriftError(err)
+	}
+	resp := response.(myendpoint.GetBannerResponse)
+	if resp.Err != nil {
+		return nil, thriftError(resp.Err)
+	}
+	return thriftBanner(resp.Banner), nil
+}
+
+func (s *thriftServer) RecordClick(ctx context.Context, token string, visitor string) (string, error) {
+	request := myendpoint.RecordClickRequest{Token: token}
+	response, err := s.endpoints.RecordClickEndpoint(thriftContext(ctx, visitor), request)
+	if err != nil {
+		return "", thriftError(err)
+	}
+	resp := response.(myendpoint.RecordClickResponse)
+	if resp.Err != nil {
+		return "", thriftError(resp.Err)
+	}
+	return resp.URL, nil
+}
+
+// thriftContext returns the context a Thrift call is served in. The visitor
+// is the UUID passed as the last parameter of every call, calls without a
+// valid one are served without a visitor.
+func thriftContext(ctx context.Context, visitor string) context.Context {
+	ctx = myservice.WithTransport(ctx, "thrift")
+	if validUUID(visitor) {
+		ctx = myservice.WithVisitor(ctx, myservice.Visitor{ID: visitor})
+	}
+	return ctx
+}
+
+// thriftCodes maps error codes to the codes of Thrift ServiceErrors.
+var thriftCodes = map[myerror.Code]adthrift.ErrorCode{
+	myerror.Internal:        adthrift.ErrorCode_INTERNAL,
+	myerror.InvalidArgument: adthrift.ErrorCode_INVALID_ARGUMENT,
+	myerror.NotFound:        adthrift.ErrorCode_NOT_FOUND,
+	myerror.Conflict:        adthrift.ErrorCode_CONFLICT,
+	myerror.Expired:         adthrift.ErrorCode_EXPIRED,
+	myerror.Unauthorized:    adthrift.ErrorCode_UNAUTHORIZED,
+	myerror.RateLimited:     adthrift.ErrorCode_RATE_LIMITED,
+	myerror.Unavailable:     adthrift.ErrorCode_UNAVAILABLE,
+}
+
+// thriftError turns err into the ServiceError thrown to Thrift clients.
+// Any other error would reach the client as an application exception with
+// its full text.
+func thriftError(err error) error {
+	code, ok := thriftCodes[myerror.CodeOf(err)]
+	if !ok {
+		code = adthrift.ErrorCode_INTERNAL
+	}
+	return &adthrift.ServiceError{Code: code, Message: myerror.Message(err)}
+}
+
+// decodeThriftError turns a ServiceError back into the service error it was
+// encoded from, or an error with the myerror.Code of its code.
+func decodeThriftError(err error) error {
+	serr, ok := err.(*adthrift.ServiceError)
+	if !ok {
+		return err
+	}
+	for _, e := range serviceErrors {
+		if serr.Message == e.Error() {
+			return e
+		}
+	}
+	for code, thriftCode := range thriftCodes {
+		if thriftCode == serr.Code {
+			return &myerror.Error{Code: code, Msg: serr.Message}
+		}
+	}
+	return &myerror.Error{Code: myerror.Internal, Msg: serr.Message}
+}
+
+// NewThriftClient returns an AdService backed by a Thrift server described by
+// the provided client. The caller is responsible for constructing the client,
+// and eventually closing the underlying transport. Heartbeat, Pacing,
+// GetBannersBatch and AdTag are not served over Thrift and always fail.
+func NewThriftClient(client *adthrift.AdServiceClient, logger log.Logger) myservice.AdService {
+	var getBannersEndpoint endpoint.Endpoint
+	{
+		getBannersEndpoint = MakeThriftGetBannersEndpoint(client)
+		getBannersEndpoint = clientErrors(myendpoint.GetBannersResponse{}, decodeThriftError)(getBannersEndpoint)
+		getBannersEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBanners"))(getBannersEndpoint)
+	}
+	var getBannerEndpoint endpoint.Endpoint
+	{
+		getBannerEndpoint = MakeThriftGetBannerEndpoint(client)
+		getBannerEndpoint = clientErrors(myendpoint.GetBannerResponse{}, decodeThriftError)(getBannerEndpoint)
+		getBannerEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "GetBanner"))(getBannerEndpoint)
+	}
+	var recordClickEndpoint endpoint.Endpoint
+	{
+		recordClickEndpoint = MakeThriftRecordClickEndpoint(client)
+		recordClickEndpoint = clientErrors(myendpoint.RecordClickResponse{}, decodeThriftError)(recordClickEndpoint)
+		recordClickEndpoint = myendpoint.LoggingMiddleware(log.With(logger, "method", "RecordClick"))(recordClickEndpoint)
+	}
+	return myendpoint.Set{
+		GetBannersEndpoint:      getBannersEndpoint,
+		GetBannerEndpoint:       getBannerEndpoint,
+		RecordClickEndpoint:     recordClickEndpoint,
+		HeartbeatEndpoint:       notServed("Heartbeat", "Thrift"),
+		PacingEndpoint:          notServed("Pacing", "Thrift"),
+		GetBannersBatchEndpoint: notServed("GetBannersBatch", "Thrift"),
+		AdTagEndpoint:           notServed("AdTag", "Thrift"),
+	}
+}
+
+// MakeThriftGetBannersEndpoint returns an endpoint that invokes the passed
+// Thrift client. Useful only in clients.
+func MakeThriftGetBannersEndpoint(client *adthrift.AdServiceClient) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(myservice.BannerRequest)
+		reply, err := client.GetBanners(ctx, &adthrift.GetBannersRequest{
+			ClientId:       int64(req.ClientID),
+			Website:        req.Website,
+			Size:           req.Size,
+			Match:          req.Match,
+			Lang:           req.Lang,
+			AcceptLanguage: req.AcceptLanguage,
+			Count:          int32(req.Count),
+			Tags:           req.Tags,
+		}, myservice.VisitorFromContext(ctx).ID)
+		if err != nil {
+			return nil, err
+		}
+		resp := myendpoint.GetBannersResponse{Locale: reply.Locale}
+		for _, ad := range reply.Banners {
+			resp.Banners = append(resp.Banners, myservice.Ad{
+				Banner:       modelThriftBanner(ad.Banner),
+				ImpressionID: ad.ImpressionId,
+				ClickURL:     ad.ClickUrl,
+				Locale:       ad.Locale,
+			})
+		}
+		return resp, nil
+	}
+}
+
+// MakeThriftGetBannerEndpoint returns an endpoint that invokes the passed
+// Thrift client. Useful only in clients.
+func MakeThriftGetBannerEndpoint(client *adthrift.AdServiceClient) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(myendpoint.GetBannerRequest)
+		reply, err := client.GetBanner(ctx, int64(req.ID), myservice.VisitorFromContext(ctx).ID)
+		if err != nil {
+			return nil, err
+		}
+		return myendpoint.GetBannerResponse{Banner: modelThriftBanner(reply)}, nil
+	}
+}
+
+// MakeThriftRecordClickEndpoint returns an endpoint that invokes the passed
+// Thrift client. Useful only in clients.
+func MakeThriftRecordClickEndpoint(client *adthrift.AdServiceClient) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(myendpoint.RecordClickRequest)
+		url, err := client.RecordClick(ctx, req.Token, myservice.VisitorFromContext(ctx).ID)
+		if err != nil {
+			return nil, err
+		}
+		return myendpoint.RecordClickResponse{URL: url}, nil
+	}
+}
+
+func thriftBanner(b models.Banner) *adthrift.Banner {
+	return &adthrift.Banner{
+		Id:          int64(b.ID),
+		GroupId:     int64(b.GroupID),
+		Name:        b.Name,
+		Language:    b.Language,
+		Size:        b.Size,
+		Url:         b.URL,
+		Status:      int32(b.Status),
+		Weight:      int32(b.Weight),
+		Priority:    int32(b.Priority),
+		Tags:        b.Tags,
+		LandingTags: b.LandingTags,
+		CampaignId:  int64(b.CampaignID),
+		DailyTarget: int64(b.DailyTarget),
+		Version:     int64(b.Version),
+	}
+}
+
+func modelThriftBanner(b *adthrift.Banner) models.Banner {
+	if b == nil {
+		return models.Banner{}
+	}
+	return models.Banner{
+		ID:          int(b.Id),
+		GroupID:     int(b.GroupId),
+		Name:        b.Name,
+		Language:    b.Language,
+		Size:        b.Size,
+		URL:         b.Url,
+		Status:      int(b.Status),
+		Weight:      int(b.Weight),
+		Priority:    int(b.Priority),
+		Tags:        b.Tags,
+		LandingTags: b.LandingTags,
+		CampaignID:  int(b.CampaignId),
+		DailyTarget: int(b.DailyTarget),
+		Version:     int(b.Version),
+	}
+}
@@ -0,0 +1,112 @@
+package mytransport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+
+	"jf/adservice/models"
+	"jf/adservice/pkg/myendpoint"
+	"jf/adservice/pkg/myservice"
+	adthrift "jf/adservice/pkg/thrift/gen-go/adservice"
+)
+
+// newThriftTestClient serves svc over an in-memory pipe, framed and binary
+// encoded like the service does, and returns a client of it. Closing the
+// client stops the server.
+func newThriftTestClient(t *testing.T, svc myservice.AdService) (*adthrift.AdServiceClient, func()) {
+	serverConn, clientConn := net.Pipe()
+	endpoints := myendpoint.New(svc, log.NewNopLogger(), discard.NewHistogram())
+	processor := adthrift.NewAdServiceProcessor(NewThriftServer(endpoints))
+	protocolFactory := thrift.NewTBinaryProtocolFactoryDefault()
+	go func() {
+		trans := thrift.NewTFramedTransport(thrift.NewTSocketFromConnTimeout(serverConn, 0))
+		in, out := protocolFactory.GetProtocol(trans), protocolFactory.GetProtocol(trans)
+		for {
+			if ok, err := processor.Process(context.Background(), in, out); !ok || err != nil {
+				serverConn.Close()
+				return
+			}
+		}
+	}()
+	trans := thrift.NewTFramedTransport(thrift.NewTSocketFromConnTimeout(clientConn, 0))
+	client := adthrift.NewAdServiceClientFactory(trans, protocolFactory)
+	return client, func() { trans.Close() }
+}
+
+func TestThriftClient(t *testing.T) {
+	store := models.NewMemoryStore()
+	store.PutBanner(models.Banner{ID: 1, GroupID: 1, Name: "a", Size: "40*50", URL: "http://a.example", Status: models.StatusActive, Tags: []string{"cars"}})
+	store.PutBanner(models.Banner{ID: 2, GroupID: 1, Name: "b", Size: "40*50", URL: "http://b.example", Status: models.StatusActive})
+	store.SetClientGroup(10, 1)
+	impressions := &impressionLog{}
+	svc := myservice.NewService(store,
+		myservice.WithImpressions(impressions),
+		myservice.WithClickTracking(myservice.NewClickSigner([]byte("secret"), time.Hour), ""),
+	)
+	thriftClient, stop := newThriftTestClient(t, svc)
+	defer stop()
+	client := NewThriftClient(thriftClient, log.NewNopLogger())
+
+	visitor := "0f8fad5b-d9cb-469f-a165-70867728950e"
+	ctx := myservice.WithVisitor(context.Background(), myservice.Visitor{ID: visitor})
+	ads, err := client.GetBanners(ctx, myservice.BannerRequest{ClientID: 10, Size: "40*50", Count: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 2 || ads[0].ImpressionID == "" || ads[0].ClickURL == "" {
+		t.Fatalf("unexpected ads %+v", ads)
+	}
+	if len(*impressions) != 2 || (*impressions)[0].VisitorID != visitor || (*impressions)[0].Transport != "thrift" {
+		t.Errorf("want impressions of the visitor over thrift, got %+v", *impressions)
+	}
+	banner, err := client.GetBanner(ctx, 1)
+	if err != nil || banner.URL != "http://a.example" || len(banner.Tags) != 1 {
+		t.Errorf("unexpected banner %+v (%v)", banner, err)
+	}
+	if _, err := client.GetBanner(ctx, 42); err != myservice.ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if _, err := client.GetBanners(ctx, myservice.BannerRequest{ClientID: -1, Size: "40*50"}); err != myservice.ErrInvalidClient {
+		t.Errorf("want ErrInvalidClient, got %v", err)
+	}
+	landing, err := client.RecordClick(ctx, ads[0].ClickURL[len("/v1/click/"):])
+	if err != nil || landing != ads[0].URL {
+		t.Errorf("want %s, got %q (%v)", ads[0].URL, landing, err)
+	}
+	if _, err := client.RecordClick(ctx, "forged.token"); err != myservice.ErrInvalidToken {
+		t.Errorf("want ErrInvalidToken, got %v", err)
+	}
+	if _, err := client.Pacing(ctx); err == nil {
+		t.Error("want Pacing to fail over Thrift")
+	}
+}
+
+func TestThriftServiceErrors(t *testing.T) {
+	client, stop := newThriftTestClient(t, myservice.NewService(failingStore{models.NewMemoryStore()}))
+	defer stop()
+	ctx := context.Background()
+	for _, c := range []struct {
+		id   int64
+		code adthrift.ErrorCode
+		msg  string
+	}{
+		{0, adthrift.ErrorCode_INVALID_ARGUMENT, myservice.ErrInvalidBanner.Error()},
+		{1, adthrift.ErrorCode_UNAVAILABLE, "unavailable"},
+	} {
+		_, err := client.GetBanner(ctx, c.id, "")
+		if serr, ok := err.(*adthrift.ServiceError); !ok || serr.Code != c.code || serr.Message != c.msg {
+			t.Errorf("banner %d: want %s %q, got %v", c.id, c.code, c.msg, err)
+		}
+	}
+	// A call without a request struct is an invalid request, not a crash.
+	_, err := client.GetBanners(ctx, nil, "")
+	if serr, ok := err.(*adthrift.ServiceError); !ok || serr.Code != adthrift.ErrorCode_INVALID_ARGUMENT {
+		t.Errorf("want INVALID_ARGUMENT, got %v", err)
+	}
+}
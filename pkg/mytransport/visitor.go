@@ -0,0 +1,88 @@
+package mytransport
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"jf/adservice/pkg/myservice"
+)
+
+// Where the HTTP transport looks for the visitor UUID, in order.
+const (
+	VisitorCookie = "adv_uid"
+	VisitorParam  = "uid"
+	VisitorHeader = "X-Visitor-ID"
+)
+
+// visitorCookieMaxAge is how long a minted visitor cookie lives.
+const visitorCookieMaxAge = 2 * 365 * 24 * time.Hour
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+type visitorContextKey int
+
+const mintedVisitorKey visitorContextKey = 0
+
+// identifyVisitor returns the visitor making r and whether its ID was minted
+// for this request. Visitors sending "DNT: 1" get a fresh ephemeral ID that
+// is never stored in a cookie.
+func identifyVisitor(r *http.Request) (v myservice.Visitor, minted bool) {
+	if r.Header.Get("DNT") == "1" {
+		return myservice.Visitor{ID: newUUID(), Ephemeral: true}, false
+	}
+	if c, err := r.Cookie(VisitorCookie); err == nil && validUUID(c.Value) {
+		return myservice.Visitor{ID: c.Value}, false
+	}
+	if id := r.URL.Query().Get(VisitorParam); validUUID(id) {
+		return myservice.Visitor{ID: id}, true
+	}
+	if id := r.Header.Get(VisitorHeader); validUUID(id) {
+		return myservice.Visitor{ID: id}, true
+	}
+	return myservice.Visitor{ID: newUUID()}, true
+}
+
+// visitorToContext is a transport/http.RequestFunc that identifies the
+// visitor and stores it in the context for the service.
+func visitorToContext(ctx context.Context, r *http.Request) context.Context {
+	v, minted := identifyVisitor(r)
+	ctx = myservice.WithVisitor(ctx, v)
+	if minted {
+		ctx = context.WithValue(ctx, mintedVisitorKey, true)
+	}
+	return ctx
+}
+
+// visitorCookie is a transport/http.ServerResponseFunc that sets the visitor
+// cookie when the request did not carry one.
+func visitorCookie(ctx context.Context, w http.ResponseWriter) context.Context {
+	if minted, _ := ctx.Value(mintedVisitorKey).(bool); minted {
+		http.SetCookie(w, &http.Cookie{
+			Name:     VisitorCookie,
+			Value:    myservice.VisitorFromContext(ctx).ID,
+			Path:     "/",
+			MaxAge:   int(visitorCookieMaxAge.Seconds()),
+			HttpOnly: true,
+		})
+	}
+	return ctx
+}
+
+func validUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
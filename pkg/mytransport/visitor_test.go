@@ -0,0 +1,71 @@
+package mytransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jf/adservice/pkg/myservice"
+)
+
+const testUUID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func TestIdentifyVisitor(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		setup     func(r *http.Request)
+		id        string
+		minted    bool
+		ephemeral bool
+	}{
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: VisitorCookie, Value: testUUID}) }, testUUID, false, false},
+		{"query", func(r *http.Request) { r.URL.RawQuery = VisitorParam + "=" + testUUID }, testUUID, true, false},
+		{"header", func(r *http.Request) { r.Header.Set(VisitorHeader, testUUID) }, testUUID, true, false},
+		{"invalid", func(r *http.Request) { r.Header.Set(VisitorHeader, "<script>") }, "", true, false},
+		{"none", func(r *http.Request) {}, "", true, false},
+		{"dnt", func(r *http.Request) {
+			r.Header.Set("DNT", "1")
+			r.AddCookie(&http.Cookie{Name: VisitorCookie, Value: testUUID})
+		}, "", false, true},
+	} {
+		r := httptest.NewRequest("GET", "/v1/banners", nil)
+		tc.setup(r)
+		v, minted := identifyVisitor(r)
+		if !validUUID(v.ID) {
+			t.Errorf("%s: invalid id %q", tc.name, v.ID)
+		}
+		if tc.id != "" && v.ID != tc.id {
+			t.Errorf("%s: want id %s, got %s", tc.name, tc.id, v.ID)
+		}
+		if tc.id == "" && v.ID == testUUID {
+			t.Errorf("%s: want a fresh id", tc.name)
+		}
+		if minted != tc.minted || v.Ephemeral != tc.ephemeral {
+			t.Errorf("%s: want minted=%v ephemeral=%v, got %v %v", tc.name, tc.minted, tc.ephemeral, minted, v.Ephemeral)
+		}
+	}
+}
+
+func TestVisitorCookie(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/banners", nil)
+	ctx := visitorToContext(context.Background(), r)
+	w := httptest.NewRecorder()
+	visitorCookie(ctx, w)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != myservice.VisitorFromContext(ctx).ID {
+		t.Fatalf("want minted visitor cookie, got %v", cookies)
+	}
+
+	r = httptest.NewRequest("GET", "/v1/banners", nil)
+	r.Header.Set("DNT", "1")
+	ctx = visitorToContext(context.Background(), r)
+	w = httptest.NewRecorder()
+	visitorCookie(ctx, w)
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("do-not-track visitors must not get a cookie")
+	}
+	if !myservice.VisitorFromContext(ctx).Ephemeral {
+		t.Error("want ephemeral visitor in context")
+	}
+}
@@ -0,0 +1,742 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: adservice.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Banner is an ad creative. Schedules are not exposed over gRPC.
+type Banner struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	GroupId              int64    `protobuf:"varint,2,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Language             string   `protobuf:"bytes,4,opt,name=language,proto3" json:"language,omitempty"`
+	Size                 string   `protobuf:"bytes,5,opt,name=size,proto3" json:"size,omitempty"`
+	Url                  string   `protobuf:"bytes,6,opt,name=url,proto3" json:"url,omitempty"`
+	Status               int32    `protobuf:"varint,7,opt,name=status,proto3" json:"status,omitempty"`
+	Weight               int32    `protobuf:"varint,8,opt,name=weight,proto3" json:"weight,omitempty"`
+	Priority             int32    `protobuf:"varint,9,opt,name=priority,proto3" json:"priority,omitempty"`
+	Tags                 []string `protobuf:"bytes,10,rep,name=tags,proto3" json:"tags,omitempty"`
+	LandingTags          []string `protobuf:"bytes,11,rep,name=landing_tags,json=landingTags,proto3" json:"landing_tags,omitempty"`
+	CampaignId           int64    `protobuf:"varint,12,opt,name=campaign_id,json=campaignId,proto3" json:"campaign_id,omitempty"`
+	DailyTarget          int64    `protobuf:"varint,13,opt,name=daily_target,json=dailyTarget,proto3" json:"daily_target,omitempty"`
+	Version              int64    `protobuf:"varint,14,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Banner) Reset()         { *m = Banner{} }
+func (m *Banner) String() string { return proto.CompactTextString(m) }
+func (*Banner) ProtoMessage()    {}
+func (*Banner) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{0}
+}
+
+func (m *Banner) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Banner.Unmarshal(m, b)
+}
+func (m *Banner) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Banner.Marshal(b, m, deterministic)
+}
+func (m *Banner) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Banner.Merge(m, src)
+}
+func (m *Banner) XXX_Size() int {
+	return xxx_messageInfo_Banner.Size(m)
+}
+func (m *Banner) XXX_DiscardUnknown() {
+	xxx_messageInfo_Banner.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Banner proto.InternalMessageInfo
+
+func (m *Banner) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Banner) GetGroupId() int64 {
+	if m != nil {
+		return m.GroupId
+	}
+	return 0
+}
+
+func (m *Banner) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Banner) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *Banner) GetSize() string {
+	if m != nil {
+		return m.Size
+	}
+	return ""
+}
+
+func (m *Banner) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *Banner) GetStatus() int32 {
+	if m != nil {
+		return m.Status
+	}
+	return 0
+}
+
+func (m *Banner) GetWeight() int32 {
+	if m != nil {
+		return m.Weight
+	}
+	return 0
+}
+
+func (m *Banner) GetPriority() int32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *Banner) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Banner) GetLandingTags() []string {
+	if m != nil {
+		return m.LandingTags
+	}
+	return nil
+}
+
+func (m *Banner) GetCampaignId() int64 {
+	if m != nil {
+		return m.CampaignId
+	}
+	return 0
+}
+
+func (m *Banner) GetDailyTarget() int64 {
+	if m != nil {
+		return m.DailyTarget
+	}
+	return 0
+}
+
+func (m *Banner) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+// Ad is a banner chosen to be served, with the impression it was logged
+// under.
+type Ad struct {
+	Banner       *Banner `protobuf:"bytes,1,opt,name=banner,proto3" json:"banner,omitempty"`
+	ImpressionId string  `protobuf:"bytes,2,opt,name=impression_id,json=impressionId,proto3" json:"impression_id,omitempty"`
+	// click_url is the tracking URL to link instead of the banner url, empty
+	// without click tracking.
+	ClickUrl string `protobuf:"bytes,3,opt,name=click_url,json=clickUrl,proto3" json:"click_url,omitempty"`
+	// locale is the language the banner was chosen for.
+	Locale               string   `protobuf:"bytes,4,opt,name=locale,proto3" json:"locale,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Ad) Reset()         { *m = Ad{} }
+func (m *Ad) String() string { return proto.CompactTextString(m) }
+func (*Ad) ProtoMessage()    {}
+func (*Ad) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{1}
+}
+
+func (m *Ad) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Ad.Unmarshal(m, b)
+}
+func (m *Ad) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Ad.Marshal(b, m, deterministic)
+}
+func (m *Ad) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Ad.Merge(m, src)
+}
+func (m *Ad) XXX_Size() int {
+	return xxx_messageInfo_Ad.Size(m)
+}
+func (m *Ad) XXX_DiscardUnknown() {
+	xxx_messageInfo_Ad.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Ad proto.InternalMessageInfo
+
+func (m *Ad) GetBanner() *Banner {
+	if m != nil {
+		return m.Banner
+	}
+	return nil
+}
+
+func (m *Ad) GetImpressionId() string {
+	if m != nil {
+		return m.ImpressionId
+	}
+	return ""
+}
+
+func (m *Ad) GetClickUrl() string {
+	if m != nil {
+		return m.ClickUrl
+	}
+	return ""
+}
+
+func (m *Ad) GetLocale() string {
+	if m != nil {
+		return m.Locale
+	}
+	return ""
+}
+
+// GetBannersRequest names the client or website, the slot size and the
+// targeting options. Either client_id or website must be set.
+type GetBannersRequest struct {
+	ClientId int64  `protobuf:"varint,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Website  string `protobuf:"bytes,2,opt,name=website,proto3" json:"website,omitempty"`
+	Size     string `protobuf:"bytes,3,opt,name=size,proto3" json:"size,omitempty"`
+	// match is how banners are matched against size: exact (default), fit
+	// or aspect.
+	Match string `protobuf:"bytes,4,opt,name=match,proto3" json:"match,omitempty"`
+	Lang  string `protobuf:"bytes,5,opt,name=lang,proto3" json:"lang,omitempty"`
+	// accept_language is used when lang is empty.
+	AcceptLanguage string `protobuf:"bytes,6,opt,name=accept_language,json=acceptLanguage,proto3" json:"accept_language,omitempty"`
+	// count is the number of distinct banners wanted, 1 when not positive.
+	Count int32 `protobuf:"varint,7,opt,name=count,proto3" json:"count,omitempty"`
+	// tags is the tag targeting mode: off, boost or require.
+	Tags                 string   `protobuf:"bytes,8,opt,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBannersRequest) Reset()         { *m = GetBannersRequest{} }
+func (m *GetBannersRequest) String() string { return proto.CompactTextString(m) }
+func (*GetBannersRequest) ProtoMessage()    {}
+func (*GetBannersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{2}
+}
+
+func (m *GetBannersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBannersRequest.Unmarshal(m, b)
+}
+func (m *GetBannersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBannersRequest.Marshal(b, m, deterministic)
+}
+func (m *GetBannersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBannersRequest.Merge(m, src)
+}
+func (m *GetBannersRequest) XXX_Size() int {
+	return xxx_messageInfo_GetBannersRequest.Size(m)
+}
+func (m *GetBannersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBannersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBannersRequest proto.InternalMessageInfo
+
+func (m *GetBannersRequest) GetClientId() int64 {
+	if m != nil {
+		return m.ClientId
+	}
+	return 0
+}
+
+func (m *GetBannersRequest) GetWebsite() string {
+	if m != nil {
+		return m.Website
+	}
+	return ""
+}
+
+func (m *GetBannersRequest) GetSize() string {
+	if m != nil {
+		return m.Size
+	}
+	return ""
+}
+
+func (m *GetBannersRequest) GetMatch() string {
+	if m != nil {
+		return m.Match
+	}
+	return ""
+}
+
+func (m *GetBannersRequest) GetLang() string {
+	if m != nil {
+		return m.Lang
+	}
+	return ""
+}
+
+func (m *GetBannersRequest) GetAcceptLanguage() string {
+	if m != nil {
+		return m.AcceptLanguage
+	}
+	return ""
+}
+
+func (m *GetBannersRequest) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *GetBannersRequest) GetTags() string {
+	if m != nil {
+		return m.Tags
+	}
+	return ""
+}
+
+type GetBannersReply struct {
+	Banners []*Ad `protobuf:"bytes,1,rep,name=banners,proto3" json:"banners,omitempty"`
+	// locale is the language the banners were chosen for.
+	Locale               string   `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBannersReply) Reset()         { *m = GetBannersReply{} }
+func (m *GetBannersReply) String() string { return proto.CompactTextString(m) }
+func (*GetBannersReply) ProtoMessage()    {}
+func (*GetBannersReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{3}
+}
+
+func (m *GetBannersReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBannersReply.Unmarshal(m, b)
+}
+func (m *GetBannersReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBannersReply.Marshal(b, m, deterministic)
+}
+func (m *GetBannersReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBannersReply.Merge(m, src)
+}
+func (m *GetBannersReply) XXX_Size() int {
+	return xxx_messageInfo_GetBannersReply.Size(m)
+}
+func (m *GetBannersReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBannersReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBannersReply proto.InternalMessageInfo
+
+func (m *GetBannersReply) GetBanners() []*Ad {
+	if m != nil {
+		return m.Banners
+	}
+	return nil
+}
+
+func (m *GetBannersReply) GetLocale() string {
+	if m != nil {
+		return m.Locale
+	}
+	return ""
+}
+
+type GetBannerRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBannerRequest) Reset()         { *m = GetBannerRequest{} }
+func (m *GetBannerRequest) String() string { return proto.CompactTextString(m) }
+func (*GetBannerRequest) ProtoMessage()    {}
+func (*GetBannerRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{4}
+}
+
+func (m *GetBannerRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBannerRequest.Unmarshal(m, b)
+}
+func (m *GetBannerRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBannerRequest.Marshal(b, m, deterministic)
+}
+func (m *GetBannerRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBannerRequest.Merge(m, src)
+}
+func (m *GetBannerRequest) XXX_Size() int {
+	return xxx_messageInfo_GetBannerRequest.Size(m)
+}
+func (m *GetBannerRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBannerRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBannerRequest proto.InternalMessageInfo
+
+func (m *GetBannerRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type GetBannerReply struct {
+	Banner               *Banner  `protobuf:"bytes,1,opt,name=banner,proto3" json:"banner,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBannerReply) Reset()         { *m = GetBannerReply{} }
+func (m *GetBannerReply) String() string { return proto.CompactTextString(m) }
+func (*GetBannerReply) ProtoMessage()    {}
+func (*GetBannerReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{5}
+}
+
+func (m *GetBannerReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBannerReply.Unmarshal(m, b)
+}
+func (m *GetBannerReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBannerReply.Marshal(b, m, deterministic)
+}
+func (m *GetBannerReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBannerReply.Merge(m, src)
+}
+func (m *GetBannerReply) XXX_Size() int {
+	return xxx_messageInfo_GetBannerReply.Size(m)
+}
+func (m *GetBannerReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBannerReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBannerReply proto.InternalMessageInfo
+
+func (m *GetBannerReply) GetBanner() *Banner {
+	if m != nil {
+		return m.Banner
+	}
+	return nil
+}
+
+type RecordClickRequest struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RecordClickRequest) Reset()         { *m = RecordClickRequest{} }
+func (m *RecordClickRequest) String() string { return proto.CompactTextString(m) }
+func (*RecordClickRequest) ProtoMessage()    {}
+func (*RecordClickRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{6}
+}
+
+func (m *RecordClickRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RecordClickRequest.Unmarshal(m, b)
+}
+func (m *RecordClickRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RecordClickRequest.Marshal(b, m, deterministic)
+}
+func (m *RecordClickRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RecordClickRequest.Merge(m, src)
+}
+func (m *RecordClickRequest) XXX_Size() int {
+	return xxx_messageInfo_RecordClickRequest.Size(m)
+}
+func (m *RecordClickRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RecordClickRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RecordClickRequest proto.InternalMessageInfo
+
+func (m *RecordClickRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type RecordClickReply struct {
+	Url                  string   `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RecordClickReply) Reset()         { *m = RecordClickReply{} }
+func (m *RecordClickReply) String() string { return proto.CompactTextString(m) }
+func (*RecordClickReply) ProtoMessage()    {}
+func (*RecordClickReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4ba94785ed4ae54f, []int{7}
+}
+
+func (m *RecordClickReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RecordClickReply.Unmarshal(m, b)
+}
+func (m *RecordClickReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RecordClickReply.Marshal(b, m, deterministic)
+}
+func (m *RecordClickReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RecordClickReply.Merge(m, src)
+}
+func (m *RecordClickReply) XXX_Size() int {
+	return xxx_messageInfo_RecordClickReply.Size(m)
+}
+func (m *RecordClickReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_RecordClickReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RecordClickReply proto.InternalMessageInfo
+
+func (m *RecordClickReply) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Banner)(nil), "pb.Banner")
+	proto.RegisterType((*Ad)(nil), "pb.Ad")
+	proto.RegisterType((*GetBannersRequest)(nil), "pb.GetBannersRequest")
+	proto.RegisterType((*GetBannersReply)(nil), "pb.GetBannersReply")
+	proto.RegisterType((*GetBannerRequest)(nil), "pb.GetBannerRequest")
+	proto.RegisterType((*GetBannerReply)(nil), "pb.GetBannerReply")
+	proto.RegisterType((*RecordClickRequest)(nil), "pb.RecordClickRequest")
+	proto.RegisterType((*RecordClickReply)(nil), "pb.RecordClickReply")
+}
+
+func init() { proto.RegisterFile("adservice.proto", fileDescriptor_4ba94785ed4ae54f) }
+
+var fileDescriptor_4ba94785ed4ae54f = []byte{
+	// 598 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xc1, 0x6e, 0xd4, 0x30,
+	0x10, 0x25, 0x49, 0x37, 0x9b, 0x4c, 0xda, 0x6d, 0x31, 0x4b, 0x65, 0xca, 0x81, 0x10, 0x90, 0x58,
+	0x71, 0xe8, 0xa1, 0x20, 0x21, 0x21, 0x71, 0x68, 0x39, 0xa0, 0x15, 0x9c, 0x42, 0xb9, 0x70, 0x89,
+	0x9c, 0xd8, 0x4a, 0xad, 0x66, 0x93, 0x90, 0x38, 0xad, 0x96, 0x23, 0x3f, 0xc6, 0x87, 0xf0, 0x07,
+	0x7c, 0x05, 0x1a, 0x3b, 0x49, 0xb7, 0xbb, 0x17, 0x6e, 0xf3, 0x9e, 0xc7, 0x33, 0x99, 0xf7, 0xc6,
+	0x81, 0x43, 0xc6, 0x5b, 0xd1, 0xdc, 0xc8, 0x4c, 0x9c, 0xd6, 0x4d, 0xa5, 0x2a, 0x62, 0xd7, 0x69,
+	0xf4, 0xd7, 0x06, 0xf7, 0x82, 0x95, 0xa5, 0x68, 0xc8, 0x0c, 0x6c, 0xc9, 0xa9, 0x15, 0x5a, 0x0b,
+	0x27, 0xb6, 0x25, 0x27, 0x4f, 0xc0, 0xcb, 0x9b, 0xaa, 0xab, 0x13, 0xc9, 0xa9, 0xad, 0xd9, 0xa9,
+	0xc6, 0x4b, 0x4e, 0x08, 0xec, 0x95, 0x6c, 0x25, 0xa8, 0x13, 0x5a, 0x0b, 0x3f, 0xd6, 0x31, 0x39,
+	0x01, 0xaf, 0x60, 0x65, 0xde, 0xb1, 0x5c, 0xd0, 0x3d, 0xcd, 0x8f, 0x18, 0xf3, 0x5b, 0xf9, 0x53,
+	0xd0, 0x89, 0xc9, 0xc7, 0x98, 0x1c, 0x81, 0xd3, 0x35, 0x05, 0x75, 0x35, 0x85, 0x21, 0x39, 0x06,
+	0xb7, 0x55, 0x4c, 0x75, 0x2d, 0x9d, 0x86, 0xd6, 0x62, 0x12, 0xf7, 0x08, 0xf9, 0x5b, 0x21, 0xf3,
+	0x2b, 0x45, 0x3d, 0xc3, 0x1b, 0x84, 0x1d, 0xeb, 0x46, 0x56, 0x8d, 0x54, 0x6b, 0xea, 0xeb, 0x93,
+	0x11, 0x63, 0x47, 0xc5, 0xf2, 0x96, 0x42, 0xe8, 0x60, 0x47, 0x8c, 0xc9, 0x73, 0xd8, 0x2f, 0x58,
+	0xc9, 0x65, 0x99, 0x27, 0xfa, 0x2c, 0xd0, 0x67, 0x41, 0xcf, 0x5d, 0x62, 0xca, 0x33, 0x08, 0x32,
+	0xb6, 0xaa, 0x99, 0xcc, 0x4b, 0x1c, 0x7b, 0x5f, 0x8f, 0x0d, 0x03, 0xb5, 0xe4, 0x58, 0x83, 0x33,
+	0x59, 0xac, 0x13, 0xc5, 0x9a, 0x5c, 0x28, 0x7a, 0xa0, 0x33, 0x02, 0xcd, 0x5d, 0x6a, 0x8a, 0x50,
+	0x98, 0xde, 0x88, 0xa6, 0x95, 0x55, 0x49, 0x67, 0x46, 0xb6, 0x1e, 0x46, 0xbf, 0x2c, 0xb0, 0xcf,
+	0x39, 0x89, 0xc0, 0x4d, 0xb5, 0xe4, 0x5a, 0xec, 0xe0, 0x0c, 0x4e, 0xeb, 0xf4, 0xd4, 0x98, 0x10,
+	0xf7, 0x27, 0xe4, 0x05, 0x1c, 0xc8, 0x55, 0xdd, 0x88, 0x16, 0x2f, 0x0e, 0x0e, 0xf8, 0xf1, 0xfe,
+	0x1d, 0xb9, 0xe4, 0xe4, 0x29, 0xf8, 0x59, 0x21, 0xb3, 0xeb, 0x04, 0x85, 0x34, 0x5e, 0x78, 0x9a,
+	0xf8, 0x66, 0xd4, 0x2c, 0xaa, 0x8c, 0x15, 0x83, 0x1b, 0x3d, 0x8a, 0xfe, 0x58, 0xf0, 0xf0, 0x93,
+	0x50, 0xa6, 0x5f, 0x1b, 0x8b, 0x1f, 0x9d, 0x68, 0x55, 0x5f, 0x4a, 0x94, 0x2a, 0x19, 0x77, 0xc0,
+	0x33, 0xc4, 0x92, 0xe3, 0x44, 0xb7, 0x22, 0x6d, 0xa5, 0x12, 0xfd, 0x67, 0x0c, 0x70, 0x34, 0xd6,
+	0xd9, 0x30, 0x76, 0x0e, 0x93, 0x15, 0x53, 0xd9, 0x55, 0xdf, 0xd7, 0x00, 0xcc, 0xc4, 0x75, 0x18,
+	0x56, 0x00, 0x63, 0xf2, 0x0a, 0x0e, 0x59, 0x96, 0x89, 0x5a, 0x25, 0xe3, 0xe6, 0x98, 0x75, 0x98,
+	0x19, 0xfa, 0x4b, 0xcf, 0x62, 0xc9, 0xac, 0xea, 0x4a, 0xd5, 0x2f, 0x86, 0x01, 0xa3, 0xc7, 0x9e,
+	0x29, 0x89, 0x71, 0xf4, 0x19, 0x0e, 0x37, 0x87, 0xab, 0x8b, 0x35, 0x09, 0x61, 0x6a, 0x44, 0x6d,
+	0xa9, 0x15, 0x3a, 0x8b, 0xe0, 0xcc, 0x45, 0xbd, 0xcf, 0x79, 0x3c, 0xd0, 0x1b, 0x52, 0xd9, 0xf7,
+	0xa4, 0x8a, 0xe0, 0x68, 0x2c, 0x36, 0x08, 0xb5, 0xf5, 0x4a, 0xa2, 0xb7, 0x30, 0xdb, 0xc8, 0xc1,
+	0x7e, 0xff, 0x61, 0x6f, 0xf4, 0x1a, 0x48, 0x2c, 0xb2, 0xaa, 0xe1, 0x1f, 0xd1, 0xae, 0xa1, 0xf6,
+	0x1c, 0x26, 0xaa, 0xba, 0x16, 0xa5, 0xbe, 0xe8, 0xc7, 0x06, 0x44, 0x2f, 0xe1, 0xe8, 0x5e, 0x2e,
+	0xf6, 0xe8, 0x1f, 0x8f, 0x35, 0x3e, 0x9e, 0xb3, 0xdf, 0x16, 0xf8, 0xe7, 0xfc, 0xab, 0x79, 0xe0,
+	0xe4, 0x3d, 0xc0, 0x9d, 0x0c, 0xe4, 0x31, 0x7e, 0xc1, 0x8e, 0xe7, 0x27, 0x8f, 0xb6, 0xe9, 0xba,
+	0x58, 0x47, 0x0f, 0xc8, 0x3b, 0xf0, 0x47, 0x92, 0xcc, 0xef, 0xe5, 0x0c, 0x37, 0xc9, 0x16, 0x6b,
+	0x2e, 0x7e, 0x80, 0x60, 0xe3, 0x43, 0xc9, 0x31, 0x26, 0xed, 0x4e, 0x79, 0x32, 0xdf, 0xe1, 0xf5,
+	0xf5, 0x8b, 0xbd, 0xef, 0x76, 0x9d, 0xa6, 0xae, 0xfe, 0x37, 0xbd, 0xf9, 0x37, 0x00, 0xc9, 0x06,
+	0x0e, 0x32, 0xae, 0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// AdServiceClient is the client API for AdService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type AdServiceClient interface {
+	// GetBanners chooses distinct banners of a size for a client or website.
+	GetBanners(ctx context.Context, in *GetBannersRequest, opts ...grpc.CallOption) (*GetBannersReply, error)
+	// GetBanner returns a single banner by id.
+	GetBanner(ctx context.Context, in *GetBannerRequest, opts ...grpc.CallOption) (*GetBannerReply, error)
+	// RecordClick validates a click token, logs the click and returns the
+	// landing URL.
+	RecordClick(ctx context.Context, in *RecordClickRequest, opts ...grpc.CallOption) (*RecordClickReply, error)
+}
+
+type adServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdServiceClient(cc *grpc.ClientConn) AdServiceClient {
+	return &adServiceClient{cc}
+}
+
+func (c *adServiceClient) GetBanners(ctx context.Context, in *GetBannersRequest, opts ...grpc.CallOption) (*GetBannersReply, error) {
+	out := new(GetBannersReply)
+	err := c.cc.Invoke(ctx, "/pb.AdService/GetBanners", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) GetBanner(ctx context.Context, in *GetBannerRequest, opts ...grpc.CallOption) (*GetBannerReply, error) {
+	out := new(GetBannerReply)
+	err := c.cc.Invoke(ctx, "/pb.AdService/GetBanner", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) RecordClick(ctx context.Context, in *RecordClickRequest, opts ...grpc.CallOption) (*RecordClickReply, error) {
+	out := new(RecordClickReply)
+	err := c.cc.Invoke(ctx, "/pb.AdService/RecordClick", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdServiceServer is the server API for AdService service.
+type AdServiceServer interface {
+	// GetBanners chooses distinct banners of a size for a client or website.
+	GetBanners(context.Context, *GetBannersRequest) (*GetBannersReply, error)
+	// GetBanner returns a single banner by id.
+	GetBanner(context.Context, *GetBannerRequest) (*GetBannerReply, error)
+	// RecordClick validates a click token, logs the click and returns the
+	// landing URL.
+	RecordClick(context.Context, *RecordClickRequest) (*RecordClickReply, error)
+}
+
+func RegisterAdServiceServer(s *grpc.Server, srv AdServiceServer) {
+	s.RegisterService(&_AdService_serviceDesc, srv)
+}
+
+func _AdService_GetBanners_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBannersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).GetBanners(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdService/GetBanners",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).GetBanners(ctx, req.(*GetBannersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_GetBanner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBannerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).GetBanner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdService/GetBanner",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).GetBanner(ctx, req.(*GetBannerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_RecordClick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordClickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).RecordClick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.AdService/RecordClick",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).RecordClick(ctx, req.(*RecordClickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _AdService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.AdService",
+	HandlerType: (*AdServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBanners",
+			Handler:    _AdService_GetBanners_Handler,
+		},
+		{
+			MethodName: "GetBanner",
+			Handler:    _AdService_GetBanner_Handler,
+		},
+		{
+			MethodName: "RecordClick",
+			Handler:    _AdService_RecordClick_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "adservice.proto",
+}
@@ -0,0 +1,75 @@
+namespace go adservice
+
+// ErrorCode classifies a ServiceError, it mirrors myerror.Code.
+enum ErrorCode {
+	INTERNAL = 0
+	INVALID_ARGUMENT = 1
+	NOT_FOUND = 2
+	CONFLICT = 3
+	EXPIRED = 4
+	UNAUTHORIZED = 5
+	RATE_LIMITED = 6
+	UNAVAILABLE = 7
+}
+
+// ServiceError is thrown for every failure of a call. Internal and
+// unavailable errors only carry the name of their code as message.
+exception ServiceError {
+	1: ErrorCode code
+	2: string message
+}
+
+// Banner is an ad creative. Schedules are not exposed over Thrift.
+struct Banner {
+	1: i64 id
+	2: i64 group_id
+	3: string name
+	4: string language
+	5: string size
+	6: string url
+	7: i32 status
+	8: i32 weight
+	9: i32 priority
+	10: list<string> tags
+	11: list<string> landing_tags
+	12: i64 campaign_id
+	13: i64 daily_target
+	14: i64 version
+}
+
+// Ad is a banner chosen to be served, with the impression it was logged
+// under. click_url is empty without click tracking, locale is the language
+// the banner was chosen for.
+struct Ad {
+	1: Banner banner
+	2: string impression_id
+	3: string click_url
+	4: string locale
+}
+
+// GetBannersRequest names the client or website, the slot size and the
+// targeting options. Either client_id or website must be set.
+struct GetBannersRequest {
+	1: i64 client_id
+	2: string website
+	3: string size
+	4: string match
+	5: string lang
+	6: string accept_language
+	7: i32 count
+	8: string tags
+}
+
+struct GetBannersReply {
+	1: list<Ad> banners
+	2: string locale
+}
+
+// AdService chooses banners for clients and websites and tracks clicks on
+// them. Every call takes the UUID of the visitor it is made for as its last
+// parameter, empty when there is none.
+service AdService {
+	GetBannersReply GetBanners(1: GetBannersRequest req, 2: string visitor) throws (1: ServiceError err)
+	Banner GetBanner(1: i64 id, 2: string visitor) throws (1: ServiceError err)
+	string RecordClick(1: string token, 2: string visitor) throws (1: ServiceError err)
+}
@@ -0,0 +1,10 @@
+#!/usr/bin/env sh
+
+# The Go code is generated with thriftgo, a Thrift compiler written in Go
+# that targets the github.com/apache/thrift v0.13 library:
+#
+#   go get github.com/cloudwego/thriftgo
+#
+# See also https://thrift.apache.org/tutorial/go.
+
+thriftgo -o gen-go -g "go:naming_style=apache,package_prefix=jf/adservice/pkg/thrift/gen-go/" adservice.thrift